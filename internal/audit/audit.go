@@ -0,0 +1,207 @@
+// Package audit checks stored assets for integrity problems: unreadable or
+// empty files, extensions that do not match the content, broken or
+// oversized images, SVGs with active content and awkward key characters,
+// plus the rules of an optional lint policy.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF for Decode
+	_ "image/jpeg" // register JPEG for Decode
+	_ "image/png"  // register PNG for Decode
+	"path"
+	"strings"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/imageinfo"
+	"codlocker-assets/internal/lint"
+	"codlocker-assets/internal/storage"
+)
+
+// Rule IDs reported in findings.
+const (
+	RuleUnreadable        = "unreadable"
+	RuleZeroByte          = "zero-byte"
+	RuleKeyCharacters     = "key-characters"
+	RuleExtensionMismatch = "extension-mismatch"
+	RuleInvalidImage      = "invalid-image"
+	RuleOversizedBytes    = "oversized-bytes"
+	RuleOversizedPixels   = "oversized-pixels"
+	RuleSVGActiveContent  = "svg-active-content"
+)
+
+// Default limits.
+const (
+	DefaultMaxBytes  = api.MaxUploadBytes
+	DefaultMaxPixels = imageinfo.MaxPixels
+)
+
+// Source is storage that can be walked.
+type Source interface {
+	storage.Storage
+	storage.Lister
+}
+
+// Options configures a run.
+type Options struct {
+	// Prefix limits the run to keys under it.
+	Prefix string
+	// MaxBytes and MaxPixels flag larger files and images; zero means
+	// the defaults.
+	MaxBytes  int64
+	MaxPixels int
+	// Policy, when set, adds its violations as findings, with the
+	// policy's rule IDs.
+	Policy *lint.Policy
+}
+
+// Finding is one problem with one key.
+type Finding struct {
+	Key     string `json:"key"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Report is the result of a run.
+type Report struct {
+	Scanned  int       `json:"scanned"`
+	Findings []Finding `json:"findings"`
+}
+
+// Run checks every key under opts.Prefix in src.
+func Run(ctx context.Context, src Source, opts Options) (Report, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxPixels <= 0 {
+		opts.MaxPixels = DefaultMaxPixels
+	}
+	keys, err := src.List(opts.Prefix)
+	if err != nil {
+		return Report{}, fmt.Errorf("list assets: %w", err)
+	}
+	rep := Report{Findings: []Finding{}}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return rep, err
+		}
+		rep.Scanned++
+		rep.Findings = append(rep.Findings, Check(src, key, opts)...)
+	}
+	return rep, nil
+}
+
+// Check runs every check on one key.
+func Check(src storage.Storage, key string, opts Options) []Finding {
+	var out []Finding
+	add := func(rule, format string, args ...any) {
+		out = append(out, Finding{Key: key, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if bad := keyCharacters(key); bad != "" {
+		add(RuleKeyCharacters, "key contains %s", bad)
+	}
+	data, err := src.Get(key)
+	if err != nil {
+		add(RuleUnreadable, "%v", err)
+		return out
+	}
+	if len(data) == 0 {
+		add(RuleZeroByte, "file is empty")
+		return out
+	}
+	if int64(len(data)) > opts.MaxBytes {
+		add(RuleOversizedBytes, "%d bytes exceeds the %d byte limit", len(data), opts.MaxBytes)
+	}
+	if opts.Policy != nil {
+		for _, v := range opts.Policy.Lint(key, data) {
+			add(v.Rule, "%s", v.Message)
+		}
+	}
+
+	declared := extensionFormat(key)
+	actual := imageinfo.Sniff(data)
+	switch {
+	case declared != "" && actual == "":
+		add(RuleInvalidImage, "extension %s but content is not a %s image", path.Ext(key), declared)
+		return out
+	case declared != actual:
+		ext := path.Ext(key)
+		if ext == "" {
+			ext = "(none)"
+		}
+		add(RuleExtensionMismatch, "extension %s but content is %s", ext, actual)
+	}
+
+	switch actual {
+	case "":
+	case "svg":
+		if err := wellFormed(data); err != nil {
+			add(RuleInvalidImage, "malformed SVG: %v", err)
+			break
+		}
+		for _, what := range ActiveContent(data) {
+			add(RuleSVGActiveContent, "%s", what)
+		}
+	case "webp":
+		// No decoder is linked in; sniffing the header is all we check.
+	default:
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			add(RuleInvalidImage, "%v", err)
+			break
+		}
+		if cfg.Width*cfg.Height > opts.MaxPixels {
+			add(RuleOversizedPixels, "%dx%d exceeds the %d pixel limit", cfg.Width, cfg.Height, opts.MaxPixels)
+			break // too big to decode safely
+		}
+		if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			add(RuleInvalidImage, "%v", err)
+		}
+	}
+	return out
+}
+
+// extensionFormat is the image format named by key's extension, or "".
+func extensionFormat(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".gif":
+		return "gif"
+	case ".webp":
+		return "webp"
+	case ".svg":
+		return "svg"
+	}
+	return ""
+}
+
+// keyCharacters describes the characters of key outside the URL-safe set
+// [A-Za-z0-9._@/-], or returns "" when there are none. Such keys work but
+// need escaping in every URL and are easy to mistype.
+func keyCharacters(key string) string {
+	if _, err := storage.NormalizeKey(key); err != nil {
+		return err.Error()
+	}
+	var bad []string
+	seen := make(map[rune]bool)
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '_', r == '@', r == '/', r == '-':
+		case !seen[r]:
+			seen[r] = true
+			bad = append(bad, fmt.Sprintf("%q", r))
+		}
+	}
+	if len(bad) == 0 {
+		return ""
+	}
+	return "disallowed characters " + strings.Join(bad, ", ")
+}
@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codlocker-assets/internal/imagetest"
+	"codlocker-assets/internal/lint"
+	"codlocker-assets/internal/storage"
+)
+
+func newStore(t *testing.T, files map[string][]byte) *storage.LocalStorage {
+	t.Helper()
+	root := t.TempDir()
+	for key, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return storage.NewLocalStorage(root)
+}
+
+func TestRun(t *testing.T) {
+	good := imagetest.PNG(t, 10, 10)
+	store := newStore(t, map[string][]byte{
+		"ok/logo.svg":            []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`),
+		"ok/photo.png":           good,
+		"ok/photo@640w.png":      good,
+		"products/p-001.jpg":     []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`),
+		"broken/truncated.png":   good[:len(good)/2],
+		"broken/not-image.jpg":   []byte("hello"),
+		"broken/empty.gif":       nil,
+		"broken/big.png":         imagetest.PNG(t, 100, 100),
+		"broken/script.svg":      []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="x()"><script>x()</script><a href=" javascript:x()"/></svg>`),
+		"broken/malformed.svg":   []byte(`<svg xmlns="http://www.w3.org/2000/svg"><g></svg>`),
+		"broken/Spring Sale.png": good,
+		"docs/readme":            []byte("plain"),
+	})
+
+	rep, err := Run(context.Background(), store, Options{MaxPixels: 50 * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Scanned != 12 {
+		t.Errorf("scanned %d, want 12", rep.Scanned)
+	}
+	got := make(map[string][]string)
+	for _, f := range rep.Findings {
+		got[f.Key] = append(got[f.Key], f.Rule)
+	}
+	want := map[string][]string{
+		"products/p-001.jpg":     {RuleExtensionMismatch},
+		"broken/truncated.png":   {RuleInvalidImage},
+		"broken/not-image.jpg":   {RuleInvalidImage},
+		"broken/empty.gif":       {RuleZeroByte},
+		"broken/big.png":         {RuleOversizedPixels},
+		"broken/script.svg":      {RuleSVGActiveContent, RuleSVGActiveContent, RuleSVGActiveContent},
+		"broken/malformed.svg":   {RuleInvalidImage},
+		"broken/Spring Sale.png": {RuleKeyCharacters},
+	}
+	for key, rules := range want {
+		if strings.Join(got[key], ",") != strings.Join(rules, ",") {
+			t.Errorf("%s: rules = %v, want %v", key, got[key], rules)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("unexpected findings for %s: %v", key, got[key])
+		}
+	}
+
+	rep, _ = Run(context.Background(), store, Options{Prefix: "ok/", MaxBytes: 10})
+	if rep.Scanned != 3 || len(rep.Findings) != 3 || rep.Findings[0].Rule != RuleOversizedBytes {
+		t.Errorf("prefix run = %+v", rep)
+	}
+}
+
+func TestRunPolicy(t *testing.T) {
+	store := newStore(t, map[string][]byte{
+		"products/p-001.png": imagetest.PNG(t, 40, 20),
+		"products/p-002.png": imagetest.PNG(t, 40, 40),
+	})
+	policy, err := lint.Parse([]byte("rules:\n  - {id: products, match: 'products/**', square: true}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, _ := Run(context.Background(), store, Options{Policy: policy})
+	if len(rep.Findings) != 1 || rep.Findings[0].Key != "products/p-001.png" || rep.Findings[0].Rule != "products.square" {
+		t.Errorf("findings = %+v", rep.Findings)
+	}
+}
+
+func TestActiveContent(t *testing.T) {
+	svg := `<!DOCTYPE svg [<!ENTITY x SYSTEM "file:///etc/passwd">]>
+<svg xmlns="http://www.w3.org/2000/svg"><foreignObject/><image href="data:image/svg+xml;base64,AAAA"/><g onclick="a()" onClick="b()"/></svg>`
+	got := strings.Join(ActiveContent([]byte(svg)), "; ")
+	want := "entity declaration; <foreignObject> element; embedded document in href; onclick event handler"
+	if got != want {
+		t.Errorf("ActiveContent = %q, want %q", got, want)
+	}
+	if got := ActiveContent([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><a href="/x"/></svg>`)); len(got) != 0 {
+		t.Errorf("clean SVG reported %v", got)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	store := newStore(t, map[string][]byte{
+		"ok/photo.png":       imagetest.PNG(t, 4, 4),
+		"products/p-001.jpg": []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`),
+	})
+	var out, errOut bytes.Buffer
+
+	if code := Command([]string{"-prefix", "ok/"}, store, &out, &errOut); code != ExitOK {
+		t.Errorf("clean run exit = %d: %s", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), "audited 1 assets, 0 findings") {
+		t.Errorf("text output = %q", out.String())
+	}
+
+	out.Reset()
+	if code := Command([]string{"-json"}, store, &out, &errOut); code != ExitViolations {
+		t.Errorf("violations exit = %d", code)
+	}
+	var rep Report
+	if err := json.Unmarshal(out.Bytes(), &rep); err != nil || rep.Scanned != 2 || len(rep.Findings) != 1 {
+		t.Errorf("json report = %+v, %v", rep, err)
+	}
+
+	if code := Command([]string{"-archive", filepath.Join(t.TempDir(), "missing.zip")}, store, &out, &errOut); code != ExitError {
+		t.Errorf("missing archive exit = %d, want %d", code, ExitError)
+	}
+}
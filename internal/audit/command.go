@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"codlocker-assets/internal/lint"
+	"codlocker-assets/internal/storage"
+)
+
+// Exit codes of Command.
+const (
+	ExitOK         = 0
+	ExitViolations = 1
+	ExitError      = 2
+)
+
+// Command implements "codlocker-assets audit". It audits local, the
+// configured asset roots, unless -archive or -git names another source,
+// writes a report to stdout and returns the process exit code: non-zero
+// when there are findings, so pipelines can gate on it.
+func Command(args []string, local Source, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	prefix := fs.String("prefix", "", "only audit keys under this prefix")
+	maxBytes := fs.Int64("max-bytes", DefaultMaxBytes, "flag files larger than this")
+	maxPixels := fs.Int("max-pixels", DefaultMaxPixels, "flag images with more pixels than this")
+	archive := fs.String("archive", "", "audit this .zip, .tar or .tar.gz instead of the asset roots")
+	gitRepo := fs.String("git", "", "audit this git repository instead of the asset roots")
+	gitRef := fs.String("ref", "HEAD", "branch, tag or commit to audit with -git")
+	policyFile := fs.String("policy", os.Getenv("ASSETS_LINT_POLICY"), "lint policy (YAML or JSON) to apply; defaults to $ASSETS_LINT_POLICY")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: codlocker-assets audit [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	} else if err != nil {
+		return ExitError
+	}
+
+	opts := Options{Prefix: *prefix, MaxBytes: *maxBytes, MaxPixels: *maxPixels}
+	if *policyFile != "" {
+		p, err := lint.Load(*policyFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "audit: %v\n", err)
+			return ExitError
+		}
+		opts.Policy = p
+	}
+
+	src := local
+	switch {
+	case *archive != "":
+		a, err := storage.OpenArchive(*archive)
+		if err != nil {
+			fmt.Fprintf(stderr, "audit: %v\n", err)
+			return ExitError
+		}
+		defer a.Close()
+		src = a
+	case *gitRepo != "":
+		g, err := storage.OpenGit(*gitRepo, *gitRef)
+		if err != nil {
+			fmt.Fprintf(stderr, "audit: %v\n", err)
+			return ExitError
+		}
+		src = g
+	}
+
+	rep, err := Run(context.Background(), src, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "audit: %v\n", err)
+		return ExitError
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	} else {
+		WriteText(stdout, rep)
+	}
+	if len(rep.Findings) > 0 {
+		return ExitViolations
+	}
+	return ExitOK
+}
+
+// WriteText writes rep for people: one line per finding, then a count per
+// rule.
+func WriteText(w io.Writer, rep Report) {
+	counts := make(map[string]int)
+	for _, f := range rep.Findings {
+		fmt.Fprintf(w, "%s: %s: %s\n", f.Key, f.Rule, f.Message)
+		counts[f.Rule]++
+	}
+	if len(rep.Findings) > 0 {
+		fmt.Fprintln(w)
+	}
+	rules := make([]string, 0, len(counts))
+	for rule := range counts {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		fmt.Fprintf(w, "%6d %s\n", counts[rule], rule)
+	}
+	fmt.Fprintf(w, "audited %d assets, %d findings\n", rep.Scanned, len(rep.Findings))
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// activeElements can run script or embed other documents.
+var activeElements = map[string]bool{
+	"script":        true,
+	"foreignObject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true, // SVG Tiny 1.2
+}
+
+// ActiveContent lists the scriptable constructs in an SVG: script and
+// embedding elements, on* event attributes, javascript: and HTML data:
+// URLs, and entity declarations. Each entry names the construct and,
+// for repeated ones, only the first occurrence is reported.
+func ActiveContent(data []byte) []string {
+	var out []string
+	seen := make(map[string]bool)
+	report := func(what string) {
+		if !seen[what] {
+			seen[what] = true
+			out = append(out, what)
+		}
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return out
+		}
+		switch t := tok.(type) {
+		case xml.Directive:
+			if bytes.Contains(t, []byte("ENTITY")) {
+				report("entity declaration")
+			}
+		case xml.StartElement:
+			if activeElements[t.Name.Local] {
+				report(fmt.Sprintf("<%s> element", t.Name.Local))
+			}
+			for _, a := range t.Attr {
+				name := strings.ToLower(a.Name.Local)
+				value := strings.ToLower(strings.Join(strings.Fields(a.Value), ""))
+				switch {
+				case strings.HasPrefix(name, "on"):
+					report(fmt.Sprintf("%s event handler", name))
+				case strings.HasPrefix(value, "javascript:"):
+					report(fmt.Sprintf("javascript: URL in %s", name))
+				case strings.HasPrefix(value, "data:text/html"), strings.HasPrefix(value, "data:image/svg+xml"):
+					report(fmt.Sprintf("embedded document in %s", name))
+				}
+			}
+		}
+	}
+}
+
+// wellFormed reports XML syntax errors in an SVG.
+func wellFormed(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
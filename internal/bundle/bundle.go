@@ -0,0 +1,226 @@
+// Package bundle streams zip downloads of assets, either everything under
+// a prefix or an explicit selection. The zip is built on the fly from
+// storage, one object at a time, and ends with a manifest listing what it
+// holds.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/storage"
+)
+
+// ManifestName is the manifest's path inside every bundle.
+const ManifestName = "manifest.json"
+
+// Default limits.
+const (
+	DefaultMaxFiles = 1000
+	DefaultMaxBytes = 1 << 30
+)
+
+// Limits cap the size of one bundle. Zero fields mean the defaults.
+type Limits struct {
+	MaxFiles int
+	MaxBytes int64
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxFiles
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultMaxBytes
+	}
+	return l
+}
+
+// Plan is what a bundle will hold, settled and checked against the limits
+// before anything is sent.
+type Plan struct {
+	Prefix  string
+	Files   []storage.Info
+	Missing []string
+	Bytes   int64
+}
+
+// Manifest describes a bundle. It is written as its last entry, since
+// the hashes are only known once every object has been streamed.
+type Manifest struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	CreatedBy  string    `json:"createdBy"`
+	Prefix     string    `json:"prefix,omitempty"`
+	Files      []File    `json:"files"`
+	Missing    []string  `json:"missing,omitempty"`
+	TotalBytes int64     `json:"totalBytes"`
+}
+
+// File is one object in a manifest.
+type File struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType"`
+	ModTime     time.Time `json:"modTime,omitzero"`
+}
+
+// PlanPrefix plans a bundle of every key under prefix, which must be
+// non-empty so a typo cannot ask for the whole store.
+func PlanPrefix(src storage.Storage, prefix string, lim Limits) (Plan, error) {
+	prefix = strings.TrimLeft(prefix, "/")
+	if prefix == "" {
+		return Plan{}, fmt.Errorf("%w: prefix is required", api.ErrBadRequest)
+	}
+	l, ok := src.(storage.Lister)
+	if !ok {
+		return Plan{}, fmt.Errorf("%w: storage cannot list keys", api.ErrBadRequest)
+	}
+	keys, err := l.List(prefix)
+	if err != nil {
+		return Plan{}, fmt.Errorf("list %s: %w", prefix, err)
+	}
+	lim = lim.withDefaults()
+	if len(keys) > lim.MaxFiles {
+		return Plan{}, fmt.Errorf("%w: %d assets under %s, the limit is %d", api.ErrTooLarge, len(keys), prefix, lim.MaxFiles)
+	}
+	p, err := plan(src, keys, lim)
+	p.Prefix = prefix
+	return p, err
+}
+
+// PlanKeys plans a bundle of the given keys. Keys that do not exist are
+// listed as missing in the manifest rather than failing the bundle.
+func PlanKeys(src storage.Storage, keys []string, lim Limits) (Plan, error) {
+	lim = lim.withDefaults()
+	if len(keys) == 0 {
+		return Plan{}, fmt.Errorf("%w: no keys", api.ErrBadRequest)
+	}
+	if len(keys) > lim.MaxFiles {
+		return Plan{}, fmt.Errorf("%w: %d keys, the limit is %d", api.ErrTooLarge, len(keys), lim.MaxFiles)
+	}
+	seen := make(map[string]bool, len(keys))
+	clean := make([]string, 0, len(keys))
+	for _, key := range keys {
+		k, err := storage.NormalizeKey(key)
+		if err != nil {
+			return Plan{}, fmt.Errorf("%w: %q", err, key)
+		}
+		if !seen[k] {
+			seen[k] = true
+			clean = append(clean, k)
+		}
+	}
+	return plan(src, clean, lim)
+}
+
+func plan(src storage.Storage, keys []string, lim Limits) (Plan, error) {
+	var p Plan
+	for _, key := range keys {
+		if key == ManifestName {
+			return Plan{}, fmt.Errorf("%w: %s clashes with the bundle manifest", api.ErrBadRequest, key)
+		}
+		info, err := storage.Stat(src, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			p.Missing = append(p.Missing, key)
+			continue
+		}
+		if err != nil {
+			return Plan{}, fmt.Errorf("stat %s: %w", key, err)
+		}
+		info.Key = key
+		p.Files = append(p.Files, info)
+		p.Bytes += info.Size
+		if p.Bytes > lim.MaxBytes {
+			return Plan{}, fmt.Errorf("%w: bundle exceeds the %d byte limit", api.ErrTooLarge, lim.MaxBytes)
+		}
+	}
+	return p, nil
+}
+
+// Write streams the zip for p to w and returns its manifest. Objects that
+// disappear between planning and writing are listed as missing. Once
+// bytes have been written an error leaves w holding a truncated zip, so
+// callers should abort the response.
+func Write(w io.Writer, src storage.Storage, p Plan, m Manifest) (Manifest, error) {
+	zw := zip.NewWriter(w)
+	m.Prefix = p.Prefix
+	m.Files = make([]File, 0, len(p.Files))
+	m.Missing = append([]string(nil), p.Missing...)
+	for _, info := range p.Files {
+		f, err := writeFile(zw, src, info)
+		if errors.Is(err, storage.ErrNotFound) {
+			m.Missing = append(m.Missing, info.Key)
+			continue
+		}
+		if err != nil {
+			return m, fmt.Errorf("%s: %w", info.Key, err)
+		}
+		m.Files = append(m.Files, f)
+		m.TotalBytes += f.Size
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: m.CreatedAt})
+	if err != nil {
+		return m, err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return m, err
+	}
+	return m, zw.Close()
+}
+
+func writeFile(zw *zip.Writer, src storage.Storage, info storage.Info) (File, error) {
+	r, err := storage.Open(src, info.Key)
+	if err != nil {
+		return File{}, err
+	}
+	defer r.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return File{}, err
+	}
+	head = head[:n]
+
+	hdr := &zip.FileHeader{Name: info.Key, Method: method(info.Key), Modified: info.ModTime}
+	fw, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return File{}, err
+	}
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(fw, sum), io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return File{}, err
+	}
+	return File{
+		Key:         info.Key,
+		Size:        size,
+		SHA256:      hex.EncodeToString(sum.Sum(nil)),
+		ContentType: storage.DetectContentType(info.Key, head),
+		ModTime:     info.ModTime,
+	}, nil
+}
+
+// method stores formats that are already compressed and deflates the rest
+// (SVG, JSON, text).
+func method(key string) uint16 {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".zip", ".gz":
+		return zip.Store
+	}
+	return zip.Deflate
+}
@@ -0,0 +1,130 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/storage"
+)
+
+func newTestService(t *testing.T, limits Limits) (*mux.Router, *storage.LocalStorage) {
+	t.Helper()
+	store := storage.NewLocalStorage(t.TempDir())
+	_ = store.Put("products/frozen/product-001.png", []byte("\x89PNG\r\n\x1a\nfrozen-1"))
+	_ = store.Put("products/frozen/product-002.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`))
+	_ = store.Put("products/shellfish/product-003.png", []byte("\x89PNG\r\n\x1a\nshellfish"))
+	r := mux.NewRouter()
+	New(func(*http.Request) storage.Storage { return store }, limits).Register(r)
+	return r, store
+}
+
+// unzip returns the files in a bundle and its decoded manifest.
+func unzip(t *testing.T, body []byte) (map[string][]byte, Manifest) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	var m Manifest
+	if err := json.Unmarshal(files[ManifestName], &m); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	return files, m
+}
+
+func TestPrefix(t *testing.T) {
+	r, _ := newTestService(t, Limits{})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/archive?prefix=products/frozen/", nil)
+	req.Header.Set("X-User", "marketing")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("status = %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename=products-frozen.zip` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	files, m := unzip(t, rec.Body.Bytes())
+	if len(files) != 3 || string(files["products/frozen/product-001.png"]) != "\x89PNG\r\n\x1a\nfrozen-1" {
+		t.Errorf("files = %v", files)
+	}
+	if m.Prefix != "products/frozen/" || m.CreatedBy != "marketing" || len(m.Files) != 2 || m.TotalBytes != 57 {
+		t.Errorf("manifest = %+v", m)
+	}
+	if f := m.Files[1]; f.ContentType != "image/svg+xml" || len(f.SHA256) != 64 {
+		t.Errorf("manifest file = %+v", f)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	r, _ := newTestService(t, Limits{})
+	do := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/archive", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("application/json", `{"keys":["products/shellfish/product-003.png","products/frozen/missing.png","/products/shellfish/product-003.png"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSON status = %d: %s", rec.Code, rec.Body)
+	}
+	files, m := unzip(t, rec.Body.Bytes())
+	if len(files) != 2 || len(m.Files) != 1 || len(m.Missing) != 1 || m.Missing[0] != "products/frozen/missing.png" {
+		t.Errorf("bundle = %v, manifest %+v", files, m)
+	}
+
+	form := url.Values{"keys": {"products/frozen/product-001.png\nproducts/frozen/product-002.svg\n"}}
+	rec = do("application/x-www-form-urlencoded", form.Encode())
+	if _, m := unzip(t, rec.Body.Bytes()); len(m.Files) != 2 {
+		t.Errorf("form manifest = %+v", m)
+	}
+
+	if rec := do("application/json", `{"keys":["../etc/passwd"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid key status = %d, want 400", rec.Code)
+	}
+	if rec := do("application/json", `{"keys":[]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty list status = %d, want 400", rec.Code)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	r, _ := newTestService(t, Limits{MaxFiles: 2, MaxBytes: 40})
+	get := func(query string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/archive"+query, nil))
+		return rec.Code
+	}
+	if code := get("?prefix=products/"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("too many files status = %d, want 413", code)
+	}
+	if code := get("?prefix=products/frozen/"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("too many bytes status = %d, want 413", code)
+	}
+	if code := get("?prefix=products/shellfish/"); code != http.StatusOK {
+		t.Errorf("within limits status = %d", code)
+	}
+	if code := get(""); code != http.StatusBadRequest {
+		t.Errorf("no prefix status = %d, want 400", code)
+	}
+}
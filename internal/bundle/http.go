@@ -0,0 +1,109 @@
+package bundle
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// Service serves bundles from the store picked per request.
+type Service struct {
+	store  func(r *http.Request) storage.Storage
+	limits Limits
+	now    func() time.Time
+}
+
+// New returns a Service reading from store, which is called on every
+// request so feature-flag flips take effect without a restart.
+func New(store func(r *http.Request) storage.Storage, limits Limits) *Service {
+	return &Service{store: store, limits: limits.withDefaults(), now: time.Now}
+}
+
+// Register mounts the bundle endpoints:
+//
+//	GET  /api/v1/archive?prefix=products/frozen/   zip of a prefix
+//	POST /api/v1/archive                           zip of a key list
+//
+// POST takes {"keys": [...]} as JSON, or a form with one or more "keys"
+// fields holding a key per line. Requests over the limits are refused
+// with 413 before anything is streamed.
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/archive", s.handlePrefix).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/archive", s.handleKeys).Methods(http.MethodPost)
+}
+
+func (s *Service) handlePrefix(w http.ResponseWriter, r *http.Request) {
+	src := s.store(r)
+	p, err := PlanPrefix(src, r.URL.Query().Get("prefix"), s.limits)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	s.serve(w, r, src, p, strings.ReplaceAll(strings.Trim(p.Prefix, "/"), "/", "-"))
+}
+
+func (s *Service) handleKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := readKeys(w, r)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	src := s.store(r)
+	p, err := PlanKeys(src, keys, s.limits)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	s.serve(w, r, src, p, "assets")
+}
+
+// readKeys reads the key list of a POST as JSON or form data.
+func readKeys(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/json" {
+		var req struct {
+			Keys []string `json:"keys"`
+		}
+		if err := api.DecodeJSON(w, r, &req); err != nil {
+			return nil, err
+		}
+		return req.Keys, nil
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, api.MaxUploadBytes)
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("%w: %v", api.ErrBadRequest, err)
+	}
+	var keys []string
+	for _, v := range r.PostForm["keys"] {
+		for _, line := range strings.Split(v, "\n") {
+			if key := strings.TrimSpace(line); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (s *Service) serve(w http.ResponseWriter, r *http.Request, src storage.Storage, p Plan, name string) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	m, err := Write(w, src, p, Manifest{CreatedAt: s.now().UTC(), CreatedBy: api.Actor(r)})
+	if err != nil {
+		// The status is gone; abort so the client sees a broken
+		// download rather than a short zip.
+		logger.Errorf("[bundle] %s after %d files: %v", name, len(m.Files), err)
+		panic(http.ErrAbortHandler)
+	}
+	logger.Infof("[bundle] %s for %s: %d files, %d bytes, %d missing", name, api.Actor(r), len(m.Files), m.TotalBytes, len(m.Missing))
+}
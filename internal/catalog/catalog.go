@@ -0,0 +1,196 @@
+// Package catalog keeps a searchable metadata row for every live asset:
+// content hash, size, type, pixel dimensions and loading placeholders,
+// plus editorial fields such as alt text and tags.
+package catalog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/imageinfo"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/lqip"
+	"codlocker-assets/internal/storage"
+)
+
+// Index stores catalog rows. *db.MetaRepo implements it.
+type Index interface {
+	Upsert(ctx context.Context, m db.AssetMeta) error
+	Get(ctx context.Context, key string) (db.AssetMeta, error)
+	SetDetails(ctx context.Context, key, altText string, tags []string, actor string, at time.Time) error
+	Delete(ctx context.Context, key string) error
+	Search(ctx context.Context, q db.MetaQuery) ([]db.AssetMeta, error)
+}
+
+// Source is storage the catalog can be rebuilt from.
+type Source interface {
+	storage.Storage
+	storage.Lister
+}
+
+// Service maintains the catalog. It implements storage.Observer so the
+// services that write live storage keep it in sync.
+type Service struct {
+	index         Index
+	text          TextIndex
+	defaultLocale string
+	now           func() time.Time
+}
+
+// New returns a Service. defaultLocale (e.g. "en") is preferred when no
+// localised text matches a request.
+func New(index Index, text TextIndex, defaultLocale string) *Service {
+	if loc, err := ParseLocale(defaultLocale); err == nil {
+		defaultLocale = loc
+	}
+	return &Service{index: index, text: text, defaultLocale: defaultLocale, now: time.Now}
+}
+
+// Describe computes the content fields of a catalog row for data. Images
+// also get a BlurHash and LQIP when lqip can make one.
+func Describe(key string, data []byte) db.AssetMeta {
+	sum := sha256.Sum256(data)
+	info := imageinfo.Inspect(data)
+	m := db.AssetMeta{
+		Key:         key,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Size:        int64(len(data)),
+		ContentType: storage.DetectContentType(key, data),
+		Width:       info.Width,
+		Height:      info.Height,
+	}
+	if strings.HasPrefix(m.ContentType, "image/") {
+		if p, err := lqip.Generate(data); err == nil {
+			m.BlurHash, m.LQIP = p.BlurHash, p.LQIP
+		} else {
+			logger.Debugf("[catalog] no placeholder for %s: %v", key, err)
+		}
+	}
+	return m
+}
+
+// BlurHash returns the BlurHash of key, or "" when it has none.
+func (s *Service) BlurHash(ctx context.Context, key string) string {
+	m, err := s.index.Get(ctx, key)
+	if err != nil {
+		return ""
+	}
+	return m.BlurHash
+}
+
+func (s *Service) record(ctx context.Context, key string, data []byte, actor string) error {
+	m := Describe(key, data)
+	m.UpdatedAt, m.UpdatedBy = s.now().UTC(), actor
+	return s.index.Upsert(ctx, m)
+}
+
+// Written implements storage.Observer. Catalog failures are logged rather
+// than failing the write that triggered them; Backfill repairs gaps.
+func (s *Service) Written(ctx context.Context, key string, data []byte, actor string) {
+	if err := s.record(ctx, key, data, actor); err != nil {
+		logger.Errorf("[catalog] record %s: %v", key, err)
+	}
+}
+
+// Removed implements storage.Observer.
+func (s *Service) Removed(ctx context.Context, key string) {
+	if err := s.index.Delete(ctx, key); err != nil {
+		logger.Errorf("[catalog] remove %s: %v", key, err)
+	}
+}
+
+// Get returns the catalog row for key.
+func (s *Service) Get(ctx context.Context, key string) (db.AssetMeta, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return db.AssetMeta{}, err
+	}
+	return s.index.Get(ctx, key)
+}
+
+// Details are the editorial fields of a catalog row. Nil fields are left
+// unchanged by Update.
+type Details struct {
+	AltText *string   `json:"altText"`
+	Tags    *[]string `json:"tags"`
+}
+
+// Update applies d to the row for key and returns the result.
+func (s *Service) Update(ctx context.Context, key string, d Details, actor string) (db.AssetMeta, error) {
+	m, err := s.Get(ctx, key)
+	if err != nil {
+		return db.AssetMeta{}, err
+	}
+	if d.AltText != nil {
+		m.AltText = strings.TrimSpace(*d.AltText)
+	}
+	if d.Tags != nil {
+		m.Tags = normalizeTags(*d.Tags)
+	}
+	m.UpdatedAt, m.UpdatedBy = s.now().UTC(), actor
+	if err := s.index.SetDetails(ctx, m.Key, m.AltText, m.Tags, actor, m.UpdatedAt); err != nil {
+		return db.AssetMeta{}, err
+	}
+	return m, nil
+}
+
+// normalizeTags lower-cases, trims, de-duplicates and sorts tags.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Backfill records every key in src that has no catalog row yet, e.g.
+// assets that were on disk before the catalog existed, and adds
+// placeholders to image rows recorded before they were computed. It
+// returns the number of rows added or updated.
+func (s *Service) Backfill(ctx context.Context, src Source) (int, error) {
+	keys, err := src.List("")
+	if err != nil {
+		return 0, fmt.Errorf("list assets: %w", err)
+	}
+	n := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		m, err := s.index.Get(ctx, key)
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+		case err != nil:
+			return n, err
+		case m.BlurHash != "" || !strings.HasPrefix(m.ContentType, "image/"):
+			continue
+		}
+		data, err := src.Get(key)
+		if err != nil {
+			logger.Warnf("[catalog] backfill %s: %v", key, err)
+			continue
+		}
+		if m.Key != "" && Describe(key, data).BlurHash == "" {
+			continue // still no placeholder; leave the row untouched
+		}
+		if err := s.record(ctx, key, data, "system"); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,202 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/imagetest"
+	"codlocker-assets/internal/storage"
+)
+
+// memIndex is an in-memory Index.
+type memIndex struct {
+	mu   sync.Mutex
+	rows map[string]db.AssetMeta
+}
+
+func newMemIndex() *memIndex { return &memIndex{rows: make(map[string]db.AssetMeta)} }
+
+func (m *memIndex) Upsert(_ context.Context, meta db.AssetMeta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.rows[meta.Key]; ok {
+		meta.AltText, meta.Tags = old.AltText, old.Tags
+		meta.CreatedAt, meta.CreatedBy = old.CreatedAt, old.CreatedBy
+	} else {
+		meta.Tags = []string{}
+		meta.CreatedAt, meta.CreatedBy = meta.UpdatedAt, meta.UpdatedBy
+	}
+	m.rows[meta.Key] = meta
+	return nil
+}
+
+func (m *memIndex) Get(_ context.Context, key string) (db.AssetMeta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta, ok := m.rows[key]
+	if !ok {
+		return db.AssetMeta{}, db.ErrNotFound
+	}
+	return meta, nil
+}
+
+func (m *memIndex) SetDetails(_ context.Context, key, altText string, tags []string, actor string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta, ok := m.rows[key]
+	if !ok {
+		return db.ErrNotFound
+	}
+	meta.AltText, meta.Tags, meta.UpdatedBy, meta.UpdatedAt = altText, tags, actor, at
+	m.rows[key] = meta
+	return nil
+}
+
+func (m *memIndex) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rows, key)
+	return nil
+}
+
+// Search supports the subset of db.MetaQuery the tests use.
+func (m *memIndex) Search(_ context.Context, q db.MetaQuery) ([]db.AssetMeta, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.AssetMeta
+	for _, meta := range m.rows {
+		if meta.Key <= q.After || !strings.HasPrefix(meta.Key, q.Prefix) {
+			continue
+		}
+		if slices.Contains(q.Missing, db.MissingAlt) && meta.AltText != "" {
+			continue
+		}
+		if !containsAll(meta.Tags, q.Tags) || !containsAll(strings.Split(meta.Key, "/"), q.Segments) {
+			continue
+		}
+		out = append(out, meta)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	if len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWrittenAndRemoved(t *testing.T) {
+	ctx := context.Background()
+	s := New(newMemIndex(), newMemText(), "en")
+	key := "products/frozen/product-003.png"
+
+	s.Written(ctx, key, imagetest.PNG(t, 40, 30), "alice")
+	m, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if m.ContentType != "image/png" || m.Width != 40 || m.Height != 30 || m.CreatedBy != "alice" || len(m.SHA256) != 64 {
+		t.Errorf("unexpected meta: %+v", m)
+	}
+
+	alt, tags := "Frozen squid rings", []string{" Frozen", "squid", "frozen", ""}
+	if _, err := s.Update(ctx, key, Details{AltText: &alt, Tags: &tags}, "bob"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// Rewriting content keeps editorial fields and the creator.
+	s.Written(ctx, key, imagetest.PNG(t, 80, 60), "carol")
+	m, _ = s.Get(ctx, key)
+	if m.Width != 80 || m.AltText != alt || !reflect.DeepEqual(m.Tags, []string{"frozen", "squid"}) ||
+		m.CreatedBy != "alice" || m.UpdatedBy != "carol" {
+		t.Errorf("meta after rewrite: %+v", m)
+	}
+
+	s.Removed(ctx, key)
+	if _, err := s.Get(ctx, key); err != db.ErrNotFound {
+		t.Errorf("Get after Removed err = %v, want db.ErrNotFound", err)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewLocalStorage(t.TempDir())
+	_ = store.Put("logo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 120 40"></svg>`))
+	_ = store.Put("banners/spring.png", imagetest.PNG(t, 10, 5))
+	_ = store.Put("banners/old.png", imagetest.PNG(t, 8, 8))
+
+	index := newMemIndex()
+	s := New(index, newMemText(), "en")
+	s.Written(ctx, "banners/spring.png", imagetest.PNG(t, 10, 5), "alice")
+	// A row recorded before placeholders existed.
+	_ = index.Upsert(ctx, db.AssetMeta{Key: "banners/old.png", ContentType: "image/png", UpdatedBy: "bob"})
+
+	n, err := s.Backfill(ctx, store)
+	if err != nil || n != 2 {
+		t.Fatalf("Backfill = %d, %v; want 2, nil", n, err)
+	}
+	if m, _ := s.Get(ctx, "banners/old.png"); m.BlurHash == "" || m.LQIP == "" || m.CreatedBy != "bob" {
+		t.Errorf("placeholder backfill = %+v", m)
+	}
+	if n, _ := s.Backfill(ctx, store); n != 0 {
+		t.Errorf("second Backfill = %d, want 0", n)
+	}
+	m, err := s.Get(ctx, "logo.svg")
+	if err != nil || m.Width != 120 || m.Height != 40 || m.CreatedBy != "system" {
+		t.Errorf("backfilled meta = %+v, %v", m, err)
+	}
+	if m, _ := s.Get(ctx, "banners/spring.png"); m.CreatedBy != "alice" || m.BlurHash == "" {
+		t.Errorf("existing row should be left alone: %+v", m)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	s := New(newMemIndex(), newMemText(), "en")
+	s.Written(context.Background(), "logo.svg", []byte(`<svg width="64" height="64"/>`), "alice")
+	r := mux.NewRouter()
+	s.Register(r)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", "carol")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/assets/logo.svg/meta", "")
+	var m db.AssetMeta
+	if err := json.NewDecoder(rec.Body).Decode(&m); err != nil || rec.Code != http.StatusOK || m.Width != 64 {
+		t.Fatalf("GET meta = %d %+v, %v", rec.Code, m, err)
+	}
+
+	rec = do(http.MethodPatch, "/api/v1/assets/logo.svg/meta", `{"altText":"Codlocker logo"}`)
+	if err := json.NewDecoder(rec.Body).Decode(&m); err != nil || m.AltText != "Codlocker logo" || m.UpdatedBy != "carol" {
+		t.Errorf("PATCH meta = %d %+v, %v", rec.Code, m, err)
+	}
+	if rec := do(http.MethodPatch, "/api/v1/assets/logo.svg/meta", `{"colour":"red"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH with unknown field status = %d, want 400", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/v1/assets/missing.svg/meta", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing meta status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the catalog endpoints:
+//
+//	GET   /api/v1/assets/{key}/meta   catalog row for an asset
+//	PATCH /api/v1/assets/{key}/meta   update altText and/or tags
+//	GET   /api/v1/search              search the catalog (see ParseSearch)
+//	GET   /api/v1/assets/{key}/text   best alt text/caption for Accept-Language
+//	                                  (?lang= overrides, ?all=true lists all)
+//	PATCH /api/v1/assets/{key}/text   {"fr-FR": {"altText": ..., "caption": ...}, "de": null}
+//	GET   /api/v1/text/export         CSV of localised text (?prefix=)
+//	POST  /api/v1/text/import         apply a CSV in the export layout
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/text/export", s.handleExportText).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/text/import", s.handleImportText).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/assets/{key:.+}/text", s.handleGetText).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/assets/{key:.+}/text", s.handlePatchText).Methods(http.MethodPatch)
+	r.HandleFunc("/api/v1/search", s.handleSearch).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/assets/{key:.+}/meta", s.handleGet).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/assets/{key:.+}/meta", s.handleUpdate).Methods(http.MethodPatch)
+}
+
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, err := s.Get(r.Context(), key)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, m)
+}
+
+func (s *Service) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var d Details
+	if err := api.DecodeJSON(w, r, &d); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	m, err := s.Update(r.Context(), key, d, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, m)
+}
+
+func (s *Service) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q, err := ParseSearch(r.URL.Query())
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	results, next, err := s.Search(r.Context(), q)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	if results == nil {
+		results = []db.AssetMeta{}
+	}
+	resp := map[string]any{"results": results}
+	if next != "" {
+		resp["nextCursor"] = next
+	}
+	api.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (s *Service) handleGetText(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if all, _ := strconv.ParseBool(r.URL.Query().Get("all")); all {
+		texts, err := s.Texts(r.Context(), key)
+		if err != nil {
+			api.Error(w, api.StatusFor(err), err.Error())
+			return
+		}
+		if texts == nil {
+			texts = []db.AssetText{}
+		}
+		api.WriteJSON(w, http.StatusOK, map[string]any{"key": key, "texts": texts})
+		return
+	}
+
+	accept := r.Header.Get("Accept-Language")
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		accept = lang
+	}
+	t, err := s.BestText(r.Context(), key, accept)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	if t.Locale != "" {
+		w.Header().Set("Content-Language", t.Locale)
+	}
+	api.WriteJSON(w, http.StatusOK, t)
+}
+
+func (s *Service) handlePatchText(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var p TextPatch
+	if err := api.DecodeJSON(w, r, &p); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	texts, err := s.PatchTexts(r.Context(), key, p, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	if texts == nil {
+		texts = []db.AssetText{}
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"key": key, "texts": texts})
+}
+
+func (s *Service) handleExportText(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := s.ExportText(r.Context(), &buf, r.URL.Query().Get("prefix")); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="asset-text.csv"`)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (s *Service) handleImportText(w http.ResponseWriter, r *http.Request) {
+	data, err := api.ReadBody(w, r)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	n, err := s.ImportText(r.Context(), bytes.NewReader(data), api.Actor(r))
+	var invalid *ImportError
+	if errors.As(err, &invalid) {
+		api.WriteJSON(w, http.StatusBadRequest, map[string]any{"error": "import rejected", "rows": invalid.Rows})
+		return
+	}
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"applied": n})
+}
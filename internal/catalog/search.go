@@ -0,0 +1,172 @@
+package catalog
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// Search returns one page of catalog rows matching q and the cursor for
+// the next page, which is empty on the last page.
+func (s *Service) Search(ctx context.Context, q db.MetaQuery) ([]db.AssetMeta, string, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultSearchLimit
+	}
+	limit := q.Limit
+	q.Limit++ // one extra row tells us whether there is another page
+	rows, err := s.index.Search(ctx, q)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(rows) <= limit {
+		return rows, "", nil
+	}
+	rows = rows[:limit]
+	return rows, encodeCursor(rows[limit-1].Key), nil
+}
+
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(c string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil || len(b) == 0 {
+		return "", fmt.Errorf("%w: invalid cursor", api.ErrBadRequest)
+	}
+	return string(b), nil
+}
+
+// ParseSearch builds a query from search parameters. The q parameter holds
+// space-separated terms that must all match; "AND" between terms is
+// accepted for readability. Terms are either free text or field:value with
+// field one of tag, category (any key path segment), prefix, type or
+// missing, e.g. "tag:hero AND category:smoked" or "squid missing:alt".
+//
+// The remaining parameters are prefix, type, tag (repeatable), missing
+// (comma-separated alt, tags, dimensions), minSize/maxSize,
+// minWidth/maxWidth, minHeight/maxHeight, createdAfter/createdBefore,
+// updatedAfter/updatedBefore (RFC 3339 or YYYY-MM-DD), limit and cursor.
+func ParseSearch(v url.Values) (db.MetaQuery, error) {
+	q := db.MetaQuery{
+		Prefix:      strings.TrimLeft(v.Get("prefix"), "/"),
+		ContentType: v.Get("type"),
+		Tags:        normalizeTags(v["tag"]),
+	}
+	if m := v.Get("missing"); m != "" {
+		q.Missing = strings.Split(m, ",")
+	}
+
+	var words []string
+	for _, term := range strings.Fields(v.Get("q")) {
+		if term == "AND" {
+			continue
+		}
+		field, value, ok := strings.Cut(term, ":")
+		if !ok {
+			words = append(words, term)
+			continue
+		}
+		switch field {
+		case "tag":
+			q.Tags = normalizeTags(append(q.Tags, value))
+		case "category":
+			q.Segments = append(q.Segments, value)
+		case "prefix":
+			q.Prefix = strings.TrimLeft(value, "/")
+		case "type":
+			q.ContentType = value
+		case "missing":
+			q.Missing = append(q.Missing, value)
+		default:
+			return db.MetaQuery{}, fmt.Errorf("%w: unknown search field %q", api.ErrBadRequest, field)
+		}
+	}
+	q.Text = strings.Join(words, " ")
+
+	for _, m := range q.Missing {
+		switch m {
+		case db.MissingAlt, db.MissingTags, db.MissingDimensions:
+		default:
+			return db.MetaQuery{}, fmt.Errorf("%w: missing must be alt, tags or dimensions", api.ErrBadRequest)
+		}
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"minWidth", &q.MinWidth}, {"maxWidth", &q.MaxWidth},
+		{"minHeight", &q.MinHeight}, {"maxHeight", &q.MaxHeight},
+		{"limit", &q.Limit},
+	}
+	for _, f := range ints {
+		if s := v.Get(f.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return db.MetaQuery{}, fmt.Errorf("%w: %s must be a non-negative integer", api.ErrBadRequest, f.name)
+			}
+			*f.dst = n
+		}
+	}
+	if q.Limit > maxSearchLimit || (v.Has("limit") && q.Limit == 0) {
+		return db.MetaQuery{}, fmt.Errorf("%w: limit must be between 1 and %d", api.ErrBadRequest, maxSearchLimit)
+	}
+	for _, f := range []struct {
+		name string
+		dst  *int64
+	}{{"minSize", &q.MinSize}, {"maxSize", &q.MaxSize}} {
+		if s := v.Get(f.name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n < 0 {
+				return db.MetaQuery{}, fmt.Errorf("%w: %s must be a non-negative integer", api.ErrBadRequest, f.name)
+			}
+			*f.dst = n
+		}
+	}
+
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"createdAfter", &q.CreatedAfter}, {"createdBefore", &q.CreatedBefore},
+		{"updatedAfter", &q.UpdatedAfter}, {"updatedBefore", &q.UpdatedBefore},
+	}
+	for _, f := range times {
+		if s := v.Get(f.name); s != "" {
+			t, err := parseTime(s)
+			if err != nil {
+				return db.MetaQuery{}, fmt.Errorf("%w: %s must be RFC 3339 or YYYY-MM-DD", api.ErrBadRequest, f.name)
+			}
+			*f.dst = t
+		}
+	}
+
+	if c := v.Get("cursor"); c != "" {
+		after, err := decodeCursor(c)
+		if err != nil {
+			return db.MetaQuery{}, err
+		}
+		q.After = after
+	}
+	return q, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
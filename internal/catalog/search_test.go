@@ -0,0 +1,120 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  db.MetaQuery
+		err   bool
+	}{
+		{
+			query: "q=tag:hero+AND+category:smoked",
+			want:  db.MetaQuery{Tags: []string{"hero"}, Segments: []string{"smoked"}},
+		},
+		{
+			query: "q=squid+rings+missing:alt&prefix=/products/&type=image/*&tag=Frozen",
+			want: db.MetaQuery{Text: "squid rings", Prefix: "products/", ContentType: "image/*",
+				Tags: []string{"frozen"}, Missing: []string{"alt"}},
+		},
+		{
+			query: "minWidth=800&maxSize=200000&updatedAfter=2026-03-01&limit=20&missing=tags,dimensions",
+			want: db.MetaQuery{MinWidth: 800, MaxSize: 200000, Limit: 20,
+				UpdatedAfter: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+				Missing:      []string{"tags", "dimensions"}},
+		},
+		{query: "cursor=" + encodeCursor("a/b.svg"), want: db.MetaQuery{After: "a/b.svg"}},
+		{query: "q=colour:red", err: true},
+		{query: "missing=caption", err: true},
+		{query: "minWidth=-1", err: true},
+		{query: "limit=0", err: true},
+		{query: "limit=501", err: true},
+		{query: "createdBefore=yesterday", err: true},
+		{query: "cursor=!!", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			got, err := ParseSearch(v)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseSearch = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSearch: %v", err)
+			}
+			if got.Tags == nil {
+				got.Tags = []string{}
+			}
+			if tt.want.Tags == nil {
+				tt.want.Tags = []string{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSearch =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHTTP(t *testing.T) {
+	ctx := context.Background()
+	s := New(newMemIndex(), newMemText(), "en")
+	for _, key := range []string{
+		"products/shellfish/product-001.jpg",
+		"products/shellfish/product-002.jpg",
+		"products/shellfish/product-003.jpg",
+		"products/smoked/product-001.jpg",
+	} {
+		s.Written(ctx, key, []byte(key), "alice")
+	}
+	alt := "Lobster on ice"
+	_, _ = s.Update(ctx, "products/shellfish/product-002.jpg", Details{AltText: &alt}, "alice")
+
+	r := mux.NewRouter()
+	s.Register(r)
+	search := func(query string) (int, []string, string) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil))
+		var resp struct {
+			Results    []db.AssetMeta `json:"results"`
+			NextCursor string         `json:"nextCursor"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		var keys []string
+		for _, m := range resp.Results {
+			keys = append(keys, m.Key)
+		}
+		return rec.Code, keys, resp.NextCursor
+	}
+
+	_, keys, next := search("q=category:shellfish+missing:alt&limit=1")
+	if !reflect.DeepEqual(keys, []string{"products/shellfish/product-001.jpg"}) || next == "" {
+		t.Fatalf("page 1 = %v, cursor %q", keys, next)
+	}
+	_, keys, next = search("q=category:shellfish+missing:alt&limit=1&cursor=" + next)
+	if !reflect.DeepEqual(keys, []string{"products/shellfish/product-003.jpg"}) || next != "" {
+		t.Errorf("page 2 = %v, cursor %q", keys, next)
+	}
+
+	if code, keys, _ := search("prefix=products/none/"); code != http.StatusOK || keys != nil {
+		t.Errorf("empty search = %d %v", code, keys)
+	}
+	if code, _, _ := search("q=colour:red"); code != http.StatusBadRequest {
+		t.Errorf("unknown field status = %d, want 400", code)
+	}
+}
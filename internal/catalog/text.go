@@ -0,0 +1,205 @@
+package catalog
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/storage"
+)
+
+// TextIndex stores localised alt text and captions. *db.TextRepo
+// implements it.
+type TextIndex interface {
+	List(ctx context.Context, key string) ([]db.AssetText, error)
+	Export(ctx context.Context, prefix string) ([]db.AssetText, error)
+	Apply(ctx context.Context, set, del []db.AssetText) error
+}
+
+// CSVHeader is the column layout of text import and export.
+var CSVHeader = []string{"key", "locale", "alt_text", "caption"}
+
+// ParseLocale canonicalises a BCP 47 tag such as "en_gb" to "en-GB".
+func ParseLocale(s string) (string, error) {
+	tag, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"))
+	if err != nil || tag == language.Und {
+		return "", fmt.Errorf("%w: invalid locale %q", api.ErrBadRequest, s)
+	}
+	return tag.String(), nil
+}
+
+// Texts returns every locale of key.
+func (s *Service) Texts(ctx context.Context, key string) ([]db.AssetText, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.text.List(ctx, key)
+}
+
+// BestText picks the locale of key that best matches an Accept-Language
+// value. Ties and unmatched requests fall back to the default locale,
+// then to the alphabetically first locale, and finally to the
+// locale-neutral alt text of the catalog row (returned with an empty
+// Locale).
+func (s *Service) BestText(ctx context.Context, key, acceptLanguage string) (db.AssetText, error) {
+	texts, err := s.Texts(ctx, key)
+	if err != nil {
+		return db.AssetText{}, err
+	}
+	if len(texts) == 0 {
+		m, err := s.Get(ctx, key)
+		if err != nil {
+			return db.AssetText{}, err
+		}
+		return db.AssetText{Key: m.Key, AltText: m.AltText, UpdatedAt: m.UpdatedAt, UpdatedBy: m.UpdatedBy}, nil
+	}
+
+	// The matcher falls back to the first supported tag, so the default
+	// locale goes first when present.
+	supported := make([]language.Tag, 0, len(texts))
+	order := make([]int, 0, len(texts))
+	for i, t := range texts {
+		if t.Locale == s.defaultLocale {
+			supported = append([]language.Tag{language.Make(t.Locale)}, supported...)
+			order = append([]int{i}, order...)
+			continue
+		}
+		supported = append(supported, language.Make(t.Locale))
+		order = append(order, i)
+	}
+	desired, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, idx, _ := language.NewMatcher(supported).Match(desired...)
+	return texts[order[idx]], nil
+}
+
+// TextPatch maps locales to new text; a nil entry deletes that locale.
+type TextPatch map[string]*struct {
+	AltText string `json:"altText"`
+	Caption string `json:"caption"`
+}
+
+// PatchTexts applies p to key and returns the resulting locales.
+func (s *Service) PatchTexts(ctx context.Context, key string, p TextPatch, actor string) ([]db.AssetText, error) {
+	m, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	var set, del []db.AssetText
+	for loc, v := range p {
+		locale, err := ParseLocale(loc)
+		if err != nil {
+			return nil, err
+		}
+		t := db.AssetText{Key: m.Key, Locale: locale}
+		if v == nil {
+			del = append(del, t)
+			continue
+		}
+		t.AltText, t.Caption = strings.TrimSpace(v.AltText), strings.TrimSpace(v.Caption)
+		t.UpdatedAt, t.UpdatedBy = now, actor
+		set = append(set, t)
+	}
+	if err := s.text.Apply(ctx, set, del); err != nil {
+		return nil, err
+	}
+	return s.text.List(ctx, m.Key)
+}
+
+// ExportText writes the text of every key under prefix as CSV.
+func (s *Service) ExportText(ctx context.Context, w io.Writer, prefix string) error {
+	texts, err := s.text.Export(ctx, strings.TrimLeft(prefix, "/"))
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write(CSVHeader)
+	for _, t := range texts {
+		_ = cw.Write([]string{t.Key, t.Locale, t.AltText, t.Caption})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportError lists the rows of a rejected import.
+type ImportError struct {
+	Rows []string
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%d invalid rows: %s", len(e.Rows), strings.Join(e.Rows, "; "))
+}
+
+func (e *ImportError) Unwrap() error { return api.ErrBadRequest }
+
+// ImportText applies a CSV in the ExportText layout. A row with empty alt
+// text and caption deletes that locale. Nothing is written unless every
+// row is valid. It returns the number of rows applied.
+func (s *Service) ImportText(ctx context.Context, r io.Reader, actor string) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(CSVHeader)
+	header, err := cr.Read()
+	if err != nil {
+		return 0, fmt.Errorf("%w: read CSV header: %v", api.ErrBadRequest, err)
+	}
+	if strings.Join(header, ",") != strings.Join(CSVHeader, ",") {
+		return 0, fmt.Errorf("%w: CSV header must be %s", api.ErrBadRequest, strings.Join(CSVHeader, ","))
+	}
+
+	now := s.now().UTC()
+	known := make(map[string]bool)
+	var set, del []db.AssetText
+	var bad []string
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", api.ErrBadRequest, err)
+		}
+		key, err := storage.NormalizeKey(rec[0])
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		locale, err := ParseLocale(rec[1])
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("line %d: invalid locale %q", line, rec[1]))
+			continue
+		}
+		if _, ok := known[key]; !ok {
+			_, err := s.index.Get(ctx, key)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				return 0, err
+			}
+			known[key] = err == nil
+		}
+		if !known[key] {
+			bad = append(bad, fmt.Sprintf("line %d: unknown asset %s", line, key))
+			continue
+		}
+		t := db.AssetText{Key: key, Locale: locale, AltText: strings.TrimSpace(rec[2]), Caption: strings.TrimSpace(rec[3])}
+		if t.AltText == "" && t.Caption == "" {
+			del = append(del, t)
+			continue
+		}
+		t.UpdatedAt, t.UpdatedBy = now, actor
+		set = append(set, t)
+	}
+	if len(bad) > 0 {
+		return 0, &ImportError{Rows: bad}
+	}
+	if err := s.text.Apply(ctx, set, del); err != nil {
+		return 0, err
+	}
+	return len(set) + len(del), nil
+}
@@ -0,0 +1,207 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+)
+
+// memText is an in-memory TextIndex.
+type memText struct {
+	mu   sync.Mutex
+	rows map[[2]string]db.AssetText
+}
+
+func newMemText() *memText { return &memText{rows: make(map[[2]string]db.AssetText)} }
+
+func (m *memText) List(_ context.Context, key string) ([]db.AssetText, error) {
+	return m.filter(func(t db.AssetText) bool { return t.Key == key }), nil
+}
+
+func (m *memText) Export(_ context.Context, prefix string) ([]db.AssetText, error) {
+	return m.filter(func(t db.AssetText) bool { return strings.HasPrefix(t.Key, prefix) }), nil
+}
+
+func (m *memText) filter(keep func(db.AssetText) bool) []db.AssetText {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.AssetText
+	for _, t := range m.rows {
+		if keep(t) {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Key != out[j].Key {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].Locale < out[j].Locale
+	})
+	return out
+}
+
+func (m *memText) Apply(_ context.Context, set, del []db.AssetText) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range set {
+		m.rows[[2]string{t.Key, t.Locale}] = t
+	}
+	for _, t := range del {
+		delete(m.rows, [2]string{t.Key, t.Locale})
+	}
+	return nil
+}
+
+func newTextService(t *testing.T) *Service {
+	t.Helper()
+	s := New(newMemIndex(), newMemText(), "en")
+	ctx := context.Background()
+	s.Written(ctx, "banners/spring.svg", []byte(`<svg width="10" height="10"/>`), "alice")
+	s.Written(ctx, "logo.svg", []byte(`<svg width="10" height="10"/>`), "alice")
+	return s
+}
+
+func TestBestText(t *testing.T) {
+	ctx := context.Background()
+	s := newTextService(t)
+	key := "banners/spring.svg"
+
+	alt := "Spring sale"
+	_, _ = s.Update(ctx, key, Details{AltText: &alt}, "alice")
+	if got, err := s.BestText(ctx, key, "fr"); err != nil || got.AltText != alt || got.Locale != "" {
+		t.Errorf("without localised text = %+v, %v; want catalog alt text", got, err)
+	}
+
+	_, err := s.PatchTexts(ctx, key, TextPatch{
+		"de":    {AltText: "Frühlingsangebot"},
+		"en":    {AltText: "Spring sale", Caption: "Up to 30% off"},
+		"fr_FR": {AltText: "Soldes de printemps"},
+	}, "alice")
+	if err != nil {
+		t.Fatalf("PatchTexts: %v", err)
+	}
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"fr-CA,fr;q=0.9,en;q=0.5", "fr-FR"},
+		{"de-AT", "de"},
+		{"en-GB,en;q=0.9", "en"},
+		{"ja", "en"}, // no match: default locale
+		{"", "en"},
+		{"not a header", "en"},
+	}
+	for _, tt := range tests {
+		got, err := s.BestText(ctx, key, tt.accept)
+		if err != nil || got.Locale != tt.want {
+			t.Errorf("BestText(%q) = %q, %v; want %q", tt.accept, got.Locale, err, tt.want)
+		}
+	}
+
+	if _, err := s.PatchTexts(ctx, key, TextPatch{"en": nil, "xx-!!": {}}, "alice"); err == nil {
+		t.Errorf("invalid locale should fail")
+	}
+	texts, _ := s.PatchTexts(ctx, key, TextPatch{"en": nil}, "alice")
+	if len(texts) != 2 {
+		t.Errorf("after deleting en: %+v", texts)
+	}
+	// Without the default locale the alphabetically first one wins.
+	if got, _ := s.BestText(ctx, key, "ja"); got.Locale != "de" {
+		t.Errorf("fallback without default locale = %q, want de", got.Locale)
+	}
+}
+
+func TestTextCSV(t *testing.T) {
+	ctx := context.Background()
+	s := newTextService(t)
+
+	in := "key,locale,alt_text,caption\n" +
+		"banners/spring.svg,en,Spring sale,\"Up to 30% off, this week\"\n" +
+		"banners/spring.svg,fr-fr,Soldes de printemps,\n" +
+		"logo.svg,en,Codlocker logo,\n"
+	n, err := s.ImportText(ctx, strings.NewReader(in), "alice")
+	if err != nil || n != 3 {
+		t.Fatalf("ImportText = %d, %v", n, err)
+	}
+
+	var out strings.Builder
+	if err := s.ExportText(ctx, &out, "banners/"); err != nil {
+		t.Fatal(err)
+	}
+	want := "key,locale,alt_text,caption\n" +
+		"banners/spring.svg,en,Spring sale,\"Up to 30% off, this week\"\n" +
+		"banners/spring.svg,fr-FR,Soldes de printemps,\n"
+	if out.String() != want {
+		t.Errorf("export =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// Empty text deletes; one bad row rejects the whole file.
+	_, err = s.ImportText(ctx, strings.NewReader("key,locale,alt_text,caption\n"+
+		"banners/spring.svg,fr-FR,,\n"+
+		"missing.svg,en,Nope,\n"+
+		"logo.svg,??,Nope,\n"), "alice")
+	var invalid *ImportError
+	if !errors.As(err, &invalid) || len(invalid.Rows) != 2 {
+		t.Fatalf("ImportText err = %v, want 2 invalid rows", err)
+	}
+	if texts, _ := s.Texts(ctx, "banners/spring.svg"); len(texts) != 2 {
+		t.Errorf("rejected import should change nothing: %+v", texts)
+	}
+
+	if _, err := s.ImportText(ctx, strings.NewReader("key,alt\n"), "alice"); err == nil {
+		t.Errorf("bad header should fail")
+	}
+}
+
+func TestTextHTTP(t *testing.T) {
+	s := newTextService(t)
+	r := mux.NewRouter()
+	s.Register(r)
+
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPatch, "/api/v1/assets/logo.svg/text", `{"en":{"altText":"Logo"},"fr":{"altText":"Logo (fr)"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/assets/logo.svg/text", "", "Accept-Language", "fr-BE, en;q=0.5")
+	var got db.AssetText
+	_ = json.NewDecoder(rec.Body).Decode(&got)
+	if got.AltText != "Logo (fr)" || rec.Header().Get("Content-Language") != "fr" || rec.Header().Get("Vary") != "Accept-Language" {
+		t.Errorf("GET text = %+v, headers %v", got, rec.Header())
+	}
+	rec = do(http.MethodGet, "/api/v1/assets/logo.svg/text?lang=en", "", "Accept-Language", "fr")
+	_ = json.NewDecoder(rec.Body).Decode(&got)
+	if got.Locale != "en" {
+		t.Errorf("?lang=en returned %q", got.Locale)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/text/export", "")
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") || strings.Count(rec.Body.String(), "\n") != 3 {
+		t.Errorf("export = %q", rec.Body)
+	}
+	rec = do(http.MethodPost, "/api/v1/text/import", "key,locale,alt_text,caption\nnope.svg,en,x,\n")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unknown asset") {
+		t.Errorf("bad import = %d %s", rec.Code, rec.Body)
+	}
+}
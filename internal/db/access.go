@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AssetAccess is a batch of reads of one asset: Count reads, the last at
+// At.
+type AssetAccess struct {
+	Key   string    `json:"key"`
+	At    time.Time `json:"lastAccessAt"`
+	Count int64     `json:"accessCount"`
+}
+
+// AccessRepo stores read statistics in assets.asset_access.
+type AccessRepo struct {
+	db *sql.DB
+}
+
+func NewAccessRepo(db *sql.DB) *AccessRepo {
+	return &AccessRepo{db: db}
+}
+
+// Record adds hits to the statistics in one transaction.
+func (r *AccessRepo) Record(ctx context.Context, hits []AssetAccess) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, h := range hits {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO assets.asset_access (asset_key, last_access_at, access_count)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (asset_key) DO UPDATE SET
+					last_access_at = GREATEST(asset_access.last_access_at, EXCLUDED.last_access_at),
+					access_count = asset_access.access_count + EXCLUDED.access_count`,
+				h.Key, h.At, h.Count); err != nil {
+				return fmt.Errorf("record access: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// LastUsed returns, for every catalogued key starting with prefix, when
+// it was last read or written, whichever is later. Keys without a
+// catalog row are missing from the result.
+func (r *AccessRepo) LastUsed(ctx context.Context, prefix string) (map[string]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.asset_key, GREATEST(m.updated_at, a.last_access_at)
+		FROM assets.asset_meta m LEFT JOIN assets.asset_access a ON a.asset_key = m.asset_key
+		WHERE starts_with(m.asset_key, $1)`, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("last used: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]time.Time)
+	for rows.Next() {
+		var key string
+		var at time.Time
+		if err := rows.Scan(&key, &at); err != nil {
+			return nil, fmt.Errorf("scan last used: %w", err)
+		}
+		out[key] = at
+	}
+	return out, rows.Err()
+}
+
+// Delete forgets the statistics of key; a missing row is not an error.
+func (r *AccessRepo) Delete(ctx context.Context, key string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM assets.asset_access WHERE asset_key = $1`, key); err != nil {
+		return fmt.Errorf("delete asset access: %w", err)
+	}
+	return nil
+}
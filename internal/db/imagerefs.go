@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ImageRef is a product's reference to an image: an asset URL or key.
+type ImageRef struct {
+	ProductID string `json:"productId"`
+	URL       string `json:"url"`
+}
+
+// GalleryRefsQuery lists the images of the product galleries kept in
+// assets.product_images, for use as a catalog query.
+const GalleryRefsQuery = `SELECT product_id, asset_key FROM assets.product_images`
+
+// QueryImageRefs runs query in a read-only transaction. The query must
+// return two text columns: the product ID and the image URL or key.
+func QueryImageRefs(ctx context.Context, db *sql.DB, query string) ([]ImageRef, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("begin read-only: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // read-only: nothing to commit
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("catalog query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ImageRef
+	for rows.Next() {
+		var ref ImageRef
+		var url sql.NullString
+		if err := rows.Scan(&ref.ProductID, &url); err != nil {
+			return nil, fmt.Errorf("scan catalog row (want product id, image url): %w", err)
+		}
+		if url.Valid && url.String != "" {
+			ref.URL = url.String
+			out = append(out, ref)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("catalog query: %w", err)
+	}
+	return out, nil
+}
+
+// OpenReadOnly opens another database, such as the product catalog's,
+// from a postgres:// or jdbc:postgresql:// URL. Its sessions default to
+// read-only transactions.
+func OpenReadOnly(ctx context.Context, raw string) (*sql.DB, error) {
+	dsn, err := normalizeDSN(raw, "", "")
+	if err != nil {
+		return nil, err
+	}
+	dsn += "&default_transaction_read_only=on"
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open(pgx): %w", err)
+	}
+	db.SetMaxOpenConns(2)
+	ctxPing, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctxPing); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("ping %s: %w", redactedDSN(dsn), err)
+	}
+	return db, nil
+}
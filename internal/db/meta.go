@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AssetMeta is the catalog row for a live asset. Width and Height are zero
+// when the dimensions are unknown (non-images, unsized SVGs); BlurHash and
+// LQIP are empty when no placeholder could be computed.
+type AssetMeta struct {
+	Key         string    `json:"key"`
+	SHA256      string    `json:"sha256"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	BlurHash    string    `json:"blurhash,omitempty"`
+	LQIP        string    `json:"lqip,omitempty"`
+	AltText     string    `json:"altText"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CreatedBy   string    `json:"createdBy"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	UpdatedBy   string    `json:"updatedBy"`
+}
+
+// MetaRepo stores the asset catalog in assets.asset_meta.
+type MetaRepo struct {
+	db   *sql.DB
+	tags *pgtype.Map
+}
+
+func NewMetaRepo(db *sql.DB) *MetaRepo {
+	return &MetaRepo{db: db, tags: pgtype.NewMap()}
+}
+
+const metaColumns = `asset_key, sha256, size_bytes, content_type, width, height, blurhash, lqip, alt_text, tags,
+	created_at, created_by, updated_at, updated_by`
+
+func (r *MetaRepo) scanMeta(row interface{ Scan(...any) error }) (AssetMeta, error) {
+	var m AssetMeta
+	var width, height sql.NullInt32
+	err := row.Scan(&m.Key, &m.SHA256, &m.Size, &m.ContentType, &width, &height, &m.BlurHash, &m.LQIP, &m.AltText,
+		r.tags.SQLScanner(&m.Tags), &m.CreatedAt, &m.CreatedBy, &m.UpdatedAt, &m.UpdatedBy)
+	m.Width, m.Height = int(width.Int32), int(height.Int32)
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	return m, err
+}
+
+// nullDim stores unknown dimensions as NULL.
+func nullDim(n int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(n), Valid: n > 0}
+}
+
+// Upsert records the content fields of m (hash, size, type, dimensions,
+// placeholders).
+// Editorial fields and the created_* audit columns of an existing row are
+// kept.
+func (r *MetaRepo) Upsert(ctx context.Context, m AssetMeta) error {
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO assets.asset_meta
+			(asset_key, sha256, size_bytes, content_type, width, height, blurhash, lqip, created_at, created_by, updated_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $9, $10)
+		ON CONFLICT (asset_key) DO UPDATE SET
+			sha256 = EXCLUDED.sha256,
+			size_bytes = EXCLUDED.size_bytes,
+			content_type = EXCLUDED.content_type,
+			width = EXCLUDED.width,
+			height = EXCLUDED.height,
+			blurhash = EXCLUDED.blurhash,
+			lqip = EXCLUDED.lqip,
+			updated_at = EXCLUDED.updated_at,
+			updated_by = EXCLUDED.updated_by`,
+		m.Key, m.SHA256, m.Size, m.ContentType, nullDim(m.Width), nullDim(m.Height),
+		m.BlurHash, m.LQIP, m.UpdatedAt, m.UpdatedBy); err != nil {
+		return fmt.Errorf("upsert asset meta: %w", err)
+	}
+	return nil
+}
+
+// SetDetails replaces the alt text and tags of key.
+func (r *MetaRepo) SetDetails(ctx context.Context, key, altText string, tags []string, actor string, at time.Time) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE assets.asset_meta SET alt_text = $2, tags = $3, updated_at = $4, updated_by = $5 WHERE asset_key = $1`,
+		key, altText, tags, at, actor)
+	if err != nil {
+		return fmt.Errorf("update asset meta: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MetaRepo) Get(ctx context.Context, key string) (AssetMeta, error) {
+	m, err := r.scanMeta(r.db.QueryRowContext(ctx,
+		`SELECT `+metaColumns+` FROM assets.asset_meta WHERE asset_key = $1`, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return AssetMeta{}, ErrNotFound
+	}
+	if err != nil {
+		return AssetMeta{}, fmt.Errorf("get asset meta: %w", err)
+	}
+	return m, nil
+}
+
+// Delete removes the row for key; a missing row is not an error.
+func (r *MetaRepo) Delete(ctx context.Context, key string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM assets.asset_meta WHERE asset_key = $1`, key); err != nil {
+		return fmt.Errorf("delete asset meta: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Metadata that a search can ask to be missing.
+const (
+	MissingAlt        = "alt"
+	MissingTags       = "tags"
+	MissingDimensions = "dimensions"
+)
+
+// MetaQuery filters the asset catalog. Zero values mean "no constraint";
+// all constraints must hold. Results are ordered by key and start after
+// the After key, which makes it a stable pagination cursor.
+type MetaQuery struct {
+	Text     string   // full-text words over key segments, alt text and tags
+	Tags     []string // every tag must be present
+	Segments []string // every value must be a path segment of the key
+	Prefix   string
+
+	// ContentType matches exactly, or by major type when it ends in "/*".
+	ContentType string
+
+	MinSize, MaxSize     int64
+	MinWidth, MaxWidth   int
+	MinHeight, MaxHeight int
+
+	CreatedAfter, CreatedBefore time.Time
+	UpdatedAfter, UpdatedBefore time.Time
+
+	Missing []string // MissingAlt, MissingTags, MissingDimensions
+
+	After string
+	Limit int
+}
+
+// likePrefix escapes s for use as a LIKE prefix pattern.
+func likePrefix(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
+
+// buildSearch renders q as a SELECT over assets.asset_meta.
+func buildSearch(q MetaQuery) (string, []any, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.Text != "" {
+		where = append(where, `assets.asset_meta_document(asset_key, alt_text, tags) @@ plainto_tsquery('simple', `+arg(q.Text)+`)`)
+	}
+	if len(q.Tags) > 0 {
+		where = append(where, `tags @> `+arg(q.Tags))
+	}
+	for _, seg := range q.Segments {
+		where = append(where, arg(seg)+` = ANY(string_to_array(asset_key, '/'))`)
+	}
+	if q.Prefix != "" {
+		where = append(where, `asset_key LIKE `+arg(likePrefix(q.Prefix))+` ESCAPE '\'`)
+	}
+	if major, ok := strings.CutSuffix(q.ContentType, "/*"); ok {
+		where = append(where, `content_type LIKE `+arg(likePrefix(major+"/"))+` ESCAPE '\'`)
+	} else if q.ContentType != "" {
+		where = append(where, `content_type = `+arg(q.ContentType))
+	}
+
+	ranges := []struct {
+		column   string
+		min, max int64
+	}{
+		{"size_bytes", q.MinSize, q.MaxSize},
+		{"width", int64(q.MinWidth), int64(q.MaxWidth)},
+		{"height", int64(q.MinHeight), int64(q.MaxHeight)},
+	}
+	for _, r := range ranges {
+		if r.min > 0 {
+			where = append(where, r.column+` >= `+arg(r.min))
+		}
+		if r.max > 0 {
+			where = append(where, r.column+` <= `+arg(r.max))
+		}
+	}
+
+	times := []struct {
+		column        string
+		after, before time.Time
+	}{
+		{"created_at", q.CreatedAfter, q.CreatedBefore},
+		{"updated_at", q.UpdatedAfter, q.UpdatedBefore},
+	}
+	for _, t := range times {
+		if !t.after.IsZero() {
+			where = append(where, t.column+` >= `+arg(t.after))
+		}
+		if !t.before.IsZero() {
+			where = append(where, t.column+` < `+arg(t.before))
+		}
+	}
+
+	for _, m := range q.Missing {
+		switch m {
+		case MissingAlt:
+			where = append(where, `alt_text = ''`)
+		case MissingTags:
+			where = append(where, `cardinality(tags) = 0`)
+		case MissingDimensions:
+			where = append(where, `(width IS NULL OR height IS NULL)`)
+		default:
+			return "", nil, fmt.Errorf("unknown missing predicate %q", m)
+		}
+	}
+
+	if q.After != "" {
+		where = append(where, `asset_key > `+arg(q.After))
+	}
+
+	sql := `SELECT ` + metaColumns + ` FROM assets.asset_meta`
+	if len(where) > 0 {
+		sql += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	sql += ` ORDER BY asset_key LIMIT ` + arg(q.Limit)
+	return sql, args, nil
+}
+
+// Search returns catalog rows matching q in key order.
+func (r *MetaRepo) Search(ctx context.Context, q MetaQuery) ([]AssetMeta, error) {
+	query, args, err := buildSearch(q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search asset meta: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AssetMeta
+	for rows.Next() {
+		m, err := r.scanMeta(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan asset meta: %w", err)
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildSearch(t *testing.T) {
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	sql, args, err := buildSearch(MetaQuery{
+		Text:         "squid rings",
+		Tags:         []string{"hero"},
+		Segments:     []string{"smoked"},
+		Prefix:       "products/100%_",
+		ContentType:  "image/*",
+		MinWidth:     800,
+		UpdatedAfter: since,
+		Missing:      []string{MissingAlt},
+		After:        "products/smoked/product-001.jpg",
+		Limit:        51,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frag := range []string{
+		`asset_meta_document(asset_key, alt_text, tags) @@ plainto_tsquery('simple', $1)`,
+		`tags @> $2`,
+		`$3 = ANY(string_to_array(asset_key, '/'))`,
+		`asset_key LIKE $4 ESCAPE '\'`,
+		`content_type LIKE $5`,
+		`width >= $6`,
+		`updated_at >= $7`,
+		`alt_text = ''`,
+		`asset_key > $8`,
+		`ORDER BY asset_key LIMIT $9`,
+	} {
+		if !strings.Contains(sql, frag) {
+			t.Errorf("query missing %q:\n%s", frag, sql)
+		}
+	}
+	want := []any{"squid rings", []string{"hero"}, "smoked", `products/100\%\_%`, "image/%", int64(800), since,
+		"products/smoked/product-001.jpg", 51}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v\nwant %#v", args, want)
+	}
+
+	if _, _, err := buildSearch(MetaQuery{Missing: []string{"colour"}}); err == nil {
+		t.Errorf("unknown missing predicate should fail")
+	}
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Moderation statuses.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// ModerationItem is an upload held for moderation. Label and Score are
+// the automatic classifier's, if one ran.
+type ModerationItem struct {
+	ID          string     `json:"id"`
+	Key         string     `json:"key"`
+	Size        int64      `json:"size"`
+	SHA256      string     `json:"sha256"`
+	ContentType string     `json:"contentType"`
+	Status      string     `json:"status"`
+	Label       string     `json:"label,omitempty"`
+	Score       float64    `json:"score,omitempty"`
+	SubmittedAt time.Time  `json:"submittedAt"`
+	SubmittedBy string     `json:"submittedBy"`
+	DecidedAt   *time.Time `json:"decidedAt,omitempty"`
+	DecidedBy   string     `json:"decidedBy,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+}
+
+// ModerationEvent is one entry in the audit trail of an item.
+type ModerationEvent struct {
+	ItemID string    `json:"itemId"`
+	At     time.Time `json:"at"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Note   string    `json:"note,omitempty"`
+}
+
+// ModerationRepo stores moderation items in assets.moderation_items and
+// their audit trail in assets.moderation_events. Every change to an item
+// is recorded as an event in the same transaction.
+type ModerationRepo struct {
+	db *sql.DB
+}
+
+func NewModerationRepo(db *sql.DB) *ModerationRepo {
+	return &ModerationRepo{db: db}
+}
+
+const moderationColumns = `item_id, asset_key, size_bytes, sha256, content_type, status, label, score,
+	submitted_at, submitted_by, decided_at, decided_by, reason`
+
+func scanModeration(row interface{ Scan(...any) error }) (ModerationItem, error) {
+	var m ModerationItem
+	var decidedAt sql.NullTime
+	err := row.Scan(&m.ID, &m.Key, &m.Size, &m.SHA256, &m.ContentType, &m.Status, &m.Label, &m.Score,
+		&m.SubmittedAt, &m.SubmittedBy, &decidedAt, &m.DecidedBy, &m.Reason)
+	if decidedAt.Valid {
+		m.DecidedAt = &decidedAt.Time
+	}
+	return m, err
+}
+
+func addEvent(ctx context.Context, tx *sql.Tx, e ModerationEvent) error {
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO assets.moderation_events (item_id, at, actor, action, note) VALUES ($1, $2, $3, $4, $5)`,
+		e.ItemID, e.At, e.Actor, e.Action, e.Note); err != nil {
+		return fmt.Errorf("insert moderation event: %w", err)
+	}
+	return nil
+}
+
+// Add records a new pending item with its first events.
+func (r *ModerationRepo) Add(ctx context.Context, m ModerationItem, events ...ModerationEvent) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO assets.moderation_items
+				(item_id, asset_key, size_bytes, sha256, content_type, status, label, score, submitted_at, submitted_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			m.ID, m.Key, m.Size, m.SHA256, m.ContentType, m.Status, m.Label, m.Score, m.SubmittedAt, m.SubmittedBy); err != nil {
+			return fmt.Errorf("insert moderation item: %w", err)
+		}
+		for _, e := range events {
+			if err := addEvent(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ModerationRepo) Get(ctx context.Context, id string) (ModerationItem, error) {
+	m, err := scanModeration(r.db.QueryRowContext(ctx,
+		`SELECT `+moderationColumns+` FROM assets.moderation_items WHERE item_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ModerationItem{}, ErrNotFound
+	}
+	if err != nil {
+		return ModerationItem{}, fmt.Errorf("get moderation item: %w", err)
+	}
+	return m, nil
+}
+
+// List returns items with status ("" for any) whose key starts with
+// prefix, oldest first.
+func (r *ModerationRepo) List(ctx context.Context, status, prefix string, limit int) ([]ModerationItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+moderationColumns+` FROM assets.moderation_items
+		WHERE ($1 = '' OR status = $1) AND starts_with(asset_key, $2)
+		ORDER BY submitted_at, item_id LIMIT $3`,
+		status, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list moderation items: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ModerationItem
+	for rows.Next() {
+		m, err := scanModeration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan moderation item: %w", err)
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// Decide moves a pending item to status, recording e.Actor and e.Note as
+// the decision. It returns ErrNotFound unless the item is pending.
+func (r *ModerationRepo) Decide(ctx context.Context, id, status string, e ModerationEvent) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE assets.moderation_items SET status = $2, decided_at = $3, decided_by = $4, reason = $5
+			WHERE item_id = $1 AND status = 'pending'`,
+			id, status, e.At, e.Actor, e.Note)
+		if err != nil {
+			return fmt.Errorf("decide moderation item: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return addEvent(ctx, tx, e)
+	})
+}
+
+// Reopen puts a decided item back to pending.
+func (r *ModerationRepo) Reopen(ctx context.Context, id string, e ModerationEvent) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE assets.moderation_items SET status = 'pending', decided_at = NULL, decided_by = '', reason = ''
+			WHERE item_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("reopen moderation item: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return addEvent(ctx, tx, e)
+	})
+}
+
+// Events returns the audit trail of an item, oldest first.
+func (r *ModerationRepo) Events(ctx context.Context, id string) ([]ModerationEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT item_id, at, actor, action, note FROM assets.moderation_events WHERE item_id = $1 ORDER BY event_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list moderation events: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ModerationEvent
+	for rows.Next() {
+		var e ModerationEvent
+		if err := rows.Scan(&e.ItemID, &e.At, &e.Actor, &e.Action, &e.Note); err != nil {
+			return nil, fmt.Errorf("scan moderation event: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ProductImage is one slot in a product's gallery.
+type ProductImage struct {
+	Key     string `json:"key"`
+	Role    string `json:"role"`
+	Primary bool   `json:"primary"`
+}
+
+// Gallery is the ordered image list of a product.
+type Gallery struct {
+	ProductID string         `json:"productId"`
+	Images    []ProductImage `json:"images"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	UpdatedBy string         `json:"updatedBy"`
+}
+
+// GalleryRepo stores product galleries in assets.product_images, one row
+// per image with its position.
+type GalleryRepo struct {
+	db *sql.DB
+}
+
+func NewGalleryRepo(db *sql.DB) *GalleryRepo {
+	return &GalleryRepo{db: db}
+}
+
+// Get returns the gallery of productID in display order.
+func (r *GalleryRepo) Get(ctx context.Context, productID string) (Gallery, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT asset_key, role, is_primary, updated_at, updated_by
+		   FROM assets.product_images WHERE product_id = $1 ORDER BY position`, productID)
+	if err != nil {
+		return Gallery{}, fmt.Errorf("get gallery: %w", err)
+	}
+	defer rows.Close()
+
+	g := Gallery{ProductID: productID}
+	for rows.Next() {
+		var img ProductImage
+		if err := rows.Scan(&img.Key, &img.Role, &img.Primary, &g.UpdatedAt, &g.UpdatedBy); err != nil {
+			return Gallery{}, fmt.Errorf("scan gallery: %w", err)
+		}
+		g.Images = append(g.Images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return Gallery{}, fmt.Errorf("get gallery: %w", err)
+	}
+	if len(g.Images) == 0 {
+		return Gallery{}, ErrNotFound
+	}
+	return g, nil
+}
+
+// Replace sets the whole gallery of g.ProductID.
+func (r *GalleryRepo) Replace(ctx context.Context, g Gallery) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM assets.product_images WHERE product_id = $1`, g.ProductID); err != nil {
+			return fmt.Errorf("clear gallery: %w", err)
+		}
+		for i, img := range g.Images {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO assets.product_images
+					(product_id, position, asset_key, role, is_primary, updated_at, updated_by)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				g.ProductID, i, img.Key, img.Role, img.Primary, g.UpdatedAt, g.UpdatedBy); err != nil {
+				return fmt.Errorf("insert gallery image: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes the gallery of productID.
+func (r *GalleryRepo) Delete(ctx context.Context, productID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM assets.product_images WHERE product_id = $1`, productID)
+	if err != nil {
+		return fmt.Errorf("delete gallery: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Redirect sends requests for Source to Target. A Source ending in "*"
+// matches by prefix and its Target must end in "*" too. Status is 200 for a
+// transparent alias, or 301/308 for a redirect.
+type Redirect struct {
+	Source     string     `json:"source"`
+	Target     string     `json:"target"`
+	Status     int        `json:"status"`
+	Deprecated *time.Time `json:"deprecated,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	UpdatedBy  string     `json:"updatedBy"`
+}
+
+// RedirectRepo stores redirects in assets.asset_redirects.
+type RedirectRepo struct {
+	db *sql.DB
+}
+
+func NewRedirectRepo(db *sql.DB) *RedirectRepo {
+	return &RedirectRepo{db: db}
+}
+
+const redirectColumns = `source, target, status, deprecated_at, updated_at, updated_by`
+
+func scanRedirect(row interface{ Scan(...any) error }) (Redirect, error) {
+	var rd Redirect
+	var deprecated sql.NullTime
+	err := row.Scan(&rd.Source, &rd.Target, &rd.Status, &deprecated, &rd.UpdatedAt, &rd.UpdatedBy)
+	if deprecated.Valid {
+		rd.Deprecated = &deprecated.Time
+	}
+	return rd, err
+}
+
+// List returns every redirect, ordered by source.
+func (r *RedirectRepo) List(ctx context.Context) ([]Redirect, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+redirectColumns+` FROM assets.asset_redirects ORDER BY source`)
+	if err != nil {
+		return nil, fmt.Errorf("list redirects: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Redirect
+	for rows.Next() {
+		rd, err := scanRedirect(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan redirect: %w", err)
+		}
+		out = append(out, rd)
+	}
+	return out, rows.Err()
+}
+
+func (r *RedirectRepo) Get(ctx context.Context, source string) (Redirect, error) {
+	rd, err := scanRedirect(r.db.QueryRowContext(ctx,
+		`SELECT `+redirectColumns+` FROM assets.asset_redirects WHERE source = $1`, source))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Redirect{}, ErrNotFound
+	}
+	if err != nil {
+		return Redirect{}, fmt.Errorf("get redirect: %w", err)
+	}
+	return rd, nil
+}
+
+// Put creates or replaces the redirect for rd.Source.
+func (r *RedirectRepo) Put(ctx context.Context, rd Redirect) error {
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO assets.asset_redirects (`+redirectColumns+`) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (source) DO UPDATE SET
+			target = EXCLUDED.target,
+			status = EXCLUDED.status,
+			deprecated_at = EXCLUDED.deprecated_at,
+			updated_at = EXCLUDED.updated_at,
+			updated_by = EXCLUDED.updated_by`,
+		rd.Source, rd.Target, rd.Status, rd.Deprecated, rd.UpdatedAt, rd.UpdatedBy); err != nil {
+		return fmt.Errorf("put redirect: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the redirect for source.
+func (r *RedirectRepo) Delete(ctx context.Context, source string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM assets.asset_redirects WHERE source = $1`, source)
+	if err != nil {
+		return fmt.Errorf("delete redirect: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AssetText is the alt text and caption of an asset in one locale.
+type AssetText struct {
+	Key       string    `json:"key"`
+	Locale    string    `json:"locale"`
+	AltText   string    `json:"altText"`
+	Caption   string    `json:"caption"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+// TextRepo stores localised text in assets.asset_text.
+type TextRepo struct {
+	db *sql.DB
+}
+
+func NewTextRepo(db *sql.DB) *TextRepo {
+	return &TextRepo{db: db}
+}
+
+const textColumns = `asset_key, locale, alt_text, caption, updated_at, updated_by`
+
+// List returns every locale of key, ordered by locale.
+func (r *TextRepo) List(ctx context.Context, key string) ([]AssetText, error) {
+	return r.query(ctx, `SELECT `+textColumns+` FROM assets.asset_text WHERE asset_key = $1 ORDER BY locale`, key)
+}
+
+// Export returns the text of every key starting with prefix.
+func (r *TextRepo) Export(ctx context.Context, prefix string) ([]AssetText, error) {
+	return r.query(ctx,
+		`SELECT `+textColumns+` FROM assets.asset_text WHERE starts_with(asset_key, $1) ORDER BY asset_key, locale`,
+		prefix)
+}
+
+// Apply upserts set and deletes the (key, locale) pairs in del, all in one
+// transaction.
+func (r *TextRepo) Apply(ctx context.Context, set, del []AssetText) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, t := range set {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO assets.asset_text (`+textColumns+`) VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (asset_key, locale) DO UPDATE SET
+					alt_text = EXCLUDED.alt_text,
+					caption = EXCLUDED.caption,
+					updated_at = EXCLUDED.updated_at,
+					updated_by = EXCLUDED.updated_by`,
+				t.Key, t.Locale, t.AltText, t.Caption, t.UpdatedAt, t.UpdatedBy); err != nil {
+				return fmt.Errorf("upsert asset text: %w", err)
+			}
+		}
+		for _, t := range del {
+			if _, err := tx.ExecContext(ctx,
+				`DELETE FROM assets.asset_text WHERE asset_key = $1 AND locale = $2`, t.Key, t.Locale); err != nil {
+				return fmt.Errorf("delete asset text: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *TextRepo) query(ctx context.Context, q string, args ...any) ([]AssetText, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list asset text: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AssetText
+	for rows.Next() {
+		var t AssetText
+		if err := rows.Scan(&t.Key, &t.Locale, &t.AltText, &t.Caption, &t.UpdatedAt, &t.UpdatedBy); err != nil {
+			return nil, fmt.Errorf("scan asset text: %w", err)
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
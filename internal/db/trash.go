@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TrashEntry is a soft-deleted object awaiting restore or purge.
+type TrashEntry struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType"`
+	DeletedAt   time.Time `json:"deletedAt"`
+	DeletedBy   string    `json:"deletedBy"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// TrashRepo stores trash metadata in assets.trash.
+type TrashRepo struct {
+	db *sql.DB
+}
+
+func NewTrashRepo(db *sql.DB) *TrashRepo {
+	return &TrashRepo{db: db}
+}
+
+const trashColumns = `trash_id, asset_key, size_bytes, content_type, deleted_at, deleted_by, expires_at`
+
+func scanTrash(row interface{ Scan(...any) error }) (TrashEntry, error) {
+	var e TrashEntry
+	err := row.Scan(&e.ID, &e.Key, &e.Size, &e.ContentType, &e.DeletedAt, &e.DeletedBy, &e.ExpiresAt)
+	return e, err
+}
+
+func (r *TrashRepo) Add(ctx context.Context, e TrashEntry) error {
+	if _, err := r.db.ExecContext(ctx,
+		`INSERT INTO assets.trash (`+trashColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.ID, e.Key, e.Size, e.ContentType, e.DeletedAt, e.DeletedBy, e.ExpiresAt); err != nil {
+		return fmt.Errorf("insert trash entry: %w", err)
+	}
+	return nil
+}
+
+// List returns trash entries whose key starts with prefix, newest first.
+func (r *TrashRepo) List(ctx context.Context, prefix string, limit int) ([]TrashEntry, error) {
+	return r.query(ctx,
+		`SELECT `+trashColumns+` FROM assets.trash WHERE starts_with(asset_key, $1) ORDER BY deleted_at DESC LIMIT $2`,
+		prefix, limit)
+}
+
+// Expired returns entries whose retention ended before now, oldest first.
+func (r *TrashRepo) Expired(ctx context.Context, now time.Time, limit int) ([]TrashEntry, error) {
+	return r.query(ctx,
+		`SELECT `+trashColumns+` FROM assets.trash WHERE expires_at <= $1 ORDER BY expires_at LIMIT $2`,
+		now, limit)
+}
+
+func (r *TrashRepo) Get(ctx context.Context, id string) (TrashEntry, error) {
+	e, err := scanTrash(r.db.QueryRowContext(ctx,
+		`SELECT `+trashColumns+` FROM assets.trash WHERE trash_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return TrashEntry{}, ErrNotFound
+	}
+	if err != nil {
+		return TrashEntry{}, fmt.Errorf("get trash entry: %w", err)
+	}
+	return e, nil
+}
+
+func (r *TrashRepo) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM assets.trash WHERE trash_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete trash entry: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *TrashRepo) query(ctx context.Context, q string, args ...any) ([]TrashEntry, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	defer rows.Close()
+
+	var out []TrashEntry
+	for rows.Next() {
+		e, err := scanTrash(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan trash entry: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when no row matches.
+var ErrNotFound = errors.New("not found")
+
+// AssetVersion is one immutable revision of an asset.
+type AssetVersion struct {
+	Key         string    `json:"key"`
+	ID          string    `json:"id"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CreatedBy   string    `json:"createdBy"`
+	Current     bool      `json:"current"`
+}
+
+// VersionRepo stores version metadata in assets.asset_versions.
+type VersionRepo struct {
+	db *sql.DB
+}
+
+func NewVersionRepo(db *sql.DB) *VersionRepo {
+	return &VersionRepo{db: db}
+}
+
+const versionColumns = `asset_key, version_id, size_bytes, sha256, content_type, created_at, created_by, is_current`
+
+func scanVersion(row interface{ Scan(...any) error }) (AssetVersion, error) {
+	var v AssetVersion
+	err := row.Scan(&v.Key, &v.ID, &v.Size, &v.SHA256, &v.ContentType, &v.CreatedAt, &v.CreatedBy, &v.Current)
+	return v, err
+}
+
+// Add records v as the current version of its key.
+func (r *VersionRepo) Add(ctx context.Context, v AssetVersion) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE assets.asset_versions SET is_current = false WHERE asset_key = $1 AND is_current`,
+			v.Key); err != nil {
+			return fmt.Errorf("clear current version: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO assets.asset_versions (`+versionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, true)`,
+			v.Key, v.ID, v.Size, v.SHA256, v.ContentType, v.CreatedAt, v.CreatedBy); err != nil {
+			return fmt.Errorf("insert version: %w", err)
+		}
+		return nil
+	})
+}
+
+// List returns the versions of key, newest first.
+func (r *VersionRepo) List(ctx context.Context, key string) ([]AssetVersion, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+versionColumns+` FROM assets.asset_versions WHERE asset_key = $1 ORDER BY created_at DESC, version_id DESC`,
+		key)
+	if err != nil {
+		return nil, fmt.Errorf("list versions: %w", err)
+	}
+	defer rows.Close()
+
+	var out []AssetVersion
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan version: %w", err)
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Get returns a single version.
+func (r *VersionRepo) Get(ctx context.Context, key, id string) (AssetVersion, error) {
+	v, err := scanVersion(r.db.QueryRowContext(ctx,
+		`SELECT `+versionColumns+` FROM assets.asset_versions WHERE asset_key = $1 AND version_id = $2`,
+		key, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return AssetVersion{}, ErrNotFound
+	}
+	if err != nil {
+		return AssetVersion{}, fmt.Errorf("get version: %w", err)
+	}
+	return v, nil
+}
+
+// SetCurrent marks id as the current version of key.
+func (r *VersionRepo) SetCurrent(ctx context.Context, key, id string) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE assets.asset_versions SET is_current = false WHERE asset_key = $1 AND is_current`,
+			key); err != nil {
+			return fmt.Errorf("clear current version: %w", err)
+		}
+		res, err := tx.ExecContext(ctx,
+			`UPDATE assets.asset_versions SET is_current = true WHERE asset_key = $1 AND version_id = $2`,
+			key, id)
+		if err != nil {
+			return fmt.Errorf("set current version: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Delete removes a version row.
+func (r *VersionRepo) Delete(ctx context.Context, key, id string) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM assets.asset_versions WHERE asset_key = $1 AND version_id = $2`,
+		key, id); err != nil {
+		return fmt.Errorf("delete version: %w", err)
+	}
+	return nil
+}
+
+// withTx runs fn in a transaction, committing on success.
+func withTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
@@ -24,15 +24,19 @@ type Flags struct {
 	// Boolean "kill-switch" to put the API in offline mode
 	Offline server.RoxFlag
 
-	// Image storage location: "local" or "bucket"
+	// Image storage location: "local", "archive", "git" or "bucket"
 	ImageStorageLocation server.RoxString
+
+	// Branch, tag or commit served by git storage ("" keeps ASSETS_GIT_REF)
+	AssetsGitRef server.RoxString
 }
 
 var (
 	flags = &Flags{
 		LogLevel:             server.NewRoxString("info", []string{"debug", "info", "warn", "error"}),
 		Offline:              server.NewRoxFlag(false),
-		ImageStorageLocation: server.NewRoxString("local", []string{"local", "bucket"}),
+		ImageStorageLocation: server.NewRoxString("local", []string{"local", "archive", "git", "bucket"}),
+		AssetsGitRef:         server.NewRoxString("", nil),
 	}
 
 	rox *server.Rox
@@ -0,0 +1,192 @@
+// Package gallery maps products to their ordered images, so clam-catalog
+// can ask for a product's images instead of building URLs by convention.
+package gallery
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/responsive"
+	"codlocker-assets/internal/storage"
+)
+
+// Image roles.
+const (
+	RoleHero      = "hero"
+	RoleThumbnail = "thumbnail"
+	RoleLifestyle = "lifestyle"
+)
+
+// MaxImages caps the size of a gallery.
+const MaxImages = 100
+
+var productIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Index stores galleries. *db.GalleryRepo implements it.
+type Index interface {
+	Get(ctx context.Context, productID string) (db.Gallery, error)
+	Replace(ctx context.Context, g db.Gallery) error
+	Delete(ctx context.Context, productID string) error
+}
+
+// MetaSource supplies dimensions and alt text. *catalog.Service implements it.
+type MetaSource interface {
+	Get(ctx context.Context, key string) (db.AssetMeta, error)
+}
+
+// Service manages product galleries.
+type Service struct {
+	index  Index
+	store  storage.Storage
+	keys   storage.Lister
+	meta   MetaSource
+	urlFor func(key string) string
+	now    func() time.Time
+}
+
+// New returns a Service for the assets in store. Width variants are
+// looked up in keys, an index of store such as a storage.KeyIndex, as a
+// gallery resolves up to MaxImages keys per request. meta may be nil, in
+// which case resolved images carry no dimensions or alt text. urlFor maps
+// a key to its public URL.
+func New(index Index, store storage.Storage, keys storage.Lister, meta MetaSource, urlFor func(key string) string) *Service {
+	return &Service{index: index, store: store, keys: keys, meta: meta, urlFor: urlFor, now: time.Now}
+}
+
+func checkProductID(id string) error {
+	if !productIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: product id must be 1-128 characters of A-Z a-z 0-9 . _ -", api.ErrBadRequest)
+	}
+	return nil
+}
+
+// Get returns the stored gallery of productID.
+func (s *Service) Get(ctx context.Context, productID string) (db.Gallery, error) {
+	if err := checkProductID(productID); err != nil {
+		return db.Gallery{}, err
+	}
+	return s.index.Get(ctx, productID)
+}
+
+// Put replaces the gallery of productID with images, in order. Empty
+// roles default to lifestyle; when no image is marked primary the first
+// one is. Every key must exist in storage.
+func (s *Service) Put(ctx context.Context, productID string, images []db.ProductImage, actor string) (db.Gallery, error) {
+	if err := checkProductID(productID); err != nil {
+		return db.Gallery{}, err
+	}
+	if len(images) == 0 || len(images) > MaxImages {
+		return db.Gallery{}, fmt.Errorf("%w: a gallery needs 1-%d images", api.ErrBadRequest, MaxImages)
+	}
+
+	seen := make(map[string]bool, len(images))
+	primary := -1
+	out := make([]db.ProductImage, len(images))
+	for i, img := range images {
+		key, err := storage.NormalizeKey(img.Key)
+		if err != nil {
+			return db.Gallery{}, fmt.Errorf("%w: image %d: %v", api.ErrBadRequest, i, err)
+		}
+		if seen[key] {
+			return db.Gallery{}, fmt.Errorf("%w: %s is listed twice", api.ErrBadRequest, key)
+		}
+		seen[key] = true
+		if !s.store.Exists(key) {
+			return db.Gallery{}, fmt.Errorf("%w: %s does not exist", api.ErrBadRequest, key)
+		}
+
+		switch img.Role {
+		case "":
+			img.Role = RoleLifestyle
+		case RoleHero, RoleThumbnail, RoleLifestyle:
+		default:
+			return db.Gallery{}, fmt.Errorf("%w: role must be hero, thumbnail or lifestyle", api.ErrBadRequest)
+		}
+		if img.Primary {
+			if primary >= 0 {
+				return db.Gallery{}, fmt.Errorf("%w: only one image can be primary", api.ErrBadRequest)
+			}
+			primary = i
+		}
+		img.Key = key
+		out[i] = img
+	}
+	if primary < 0 {
+		out[0].Primary = true
+	}
+
+	g := db.Gallery{ProductID: productID, Images: out, UpdatedAt: s.now().UTC(), UpdatedBy: actor}
+	if err := s.index.Replace(ctx, g); err != nil {
+		return db.Gallery{}, err
+	}
+	return g, nil
+}
+
+// Delete removes the gallery of productID.
+func (s *Service) Delete(ctx context.Context, productID string) error {
+	if err := checkProductID(productID); err != nil {
+		return err
+	}
+	return s.index.Delete(ctx, productID)
+}
+
+// Image is a gallery entry resolved for rendering.
+type Image struct {
+	db.ProductImage
+	URL      string        `json:"url"`
+	Width    int           `json:"width,omitempty"`
+	Height   int           `json:"height,omitempty"`
+	AltText  string        `json:"altText"`
+	Srcset   string        `json:"srcset,omitempty"`
+	Variants []VariantLink `json:"variants,omitempty"`
+}
+
+// VariantLink is a width variant with its URL.
+type VariantLink struct {
+	URL   string `json:"url"`
+	Width int    `json:"width"`
+}
+
+// Images resolves the gallery of productID to URLs, dimensions and srcset
+// variants.
+func (s *Service) Images(ctx context.Context, productID string) ([]Image, error) {
+	g, err := s.Get(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Image, 0, len(g.Images))
+	for _, img := range g.Images {
+		out = append(out, s.resolve(ctx, img))
+	}
+	return out, nil
+}
+
+func (s *Service) resolve(ctx context.Context, img db.ProductImage) Image {
+	r := Image{ProductImage: img, URL: s.urlFor(img.Key)}
+	if s.meta != nil {
+		if m, err := s.meta.Get(ctx, img.Key); err == nil {
+			r.Width, r.Height, r.AltText = m.Width, m.Height, m.AltText
+		}
+	}
+
+	variants, err := responsive.Find(s.keys, img.Key)
+	if err != nil {
+		logger.Warnf("[gallery] variants of %s: %v", img.Key, err)
+	}
+	// The original is the widest rendition when its width is known.
+	if r.Width > 0 && (len(variants) == 0 || variants[len(variants)-1].Width < r.Width) {
+		variants = append(variants, responsive.Variant{Key: img.Key, Width: r.Width})
+	}
+	if len(variants) > 1 || (len(variants) == 1 && variants[0].Key != img.Key) {
+		r.Srcset = responsive.Srcset(variants, s.urlFor)
+		for _, v := range variants {
+			r.Variants = append(r.Variants, VariantLink{URL: s.urlFor(v.Key), Width: v.Width})
+		}
+	}
+	return r
+}
@@ -0,0 +1,178 @@
+package gallery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/responsive"
+	"codlocker-assets/internal/storage"
+)
+
+// memIndex is an in-memory Index.
+type memIndex struct {
+	mu        sync.Mutex
+	galleries map[string]db.Gallery
+}
+
+func (m *memIndex) Get(_ context.Context, id string) (db.Gallery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.galleries[id]
+	if !ok {
+		return db.Gallery{}, db.ErrNotFound
+	}
+	return g, nil
+}
+
+func (m *memIndex) Replace(_ context.Context, g db.Gallery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.galleries[g.ProductID] = g
+	return nil
+}
+
+func (m *memIndex) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.galleries[id]; !ok {
+		return db.ErrNotFound
+	}
+	delete(m.galleries, id)
+	return nil
+}
+
+// memMeta serves fixed catalog rows.
+type memMeta map[string]db.AssetMeta
+
+func (m memMeta) Get(_ context.Context, key string) (db.AssetMeta, error) {
+	meta, ok := m[key]
+	if !ok {
+		return db.AssetMeta{}, db.ErrNotFound
+	}
+	return meta, nil
+}
+
+// countingStore counts how often it is listed.
+type countingStore struct {
+	*storage.LocalStorage
+	lists int
+}
+
+func (c *countingStore) List(prefix string) ([]string, error) {
+	c.lists++
+	return c.LocalStorage.List(prefix)
+}
+
+func newTestService(t *testing.T) (*Service, *countingStore) {
+	t.Helper()
+	store := &countingStore{LocalStorage: storage.NewLocalStorage(t.TempDir())}
+	for _, k := range []string{
+		"products/smoked/product-001.jpg",
+		"products/smoked/product-001@320w.jpg",
+		"products/smoked/product-001@640w.jpg",
+		"products/smoked/product-002.jpg",
+	} {
+		if err := store.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta := memMeta{
+		"products/smoked/product-001.jpg": {Width: 1200, Height: 800, AltText: "Smoked salmon side"},
+	}
+	return New(&memIndex{galleries: make(map[string]db.Gallery)}, store, storage.NewKeyIndex(store, 0), meta, responsive.URLFor("https://cdn.example.com")), store
+}
+
+func TestPut(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+
+	g, err := s.Put(ctx, "SKU-1001", []db.ProductImage{
+		{Key: "/products/smoked/product-002.jpg", Role: RoleLifestyle},
+		{Key: "products/smoked/product-001.jpg", Role: RoleHero},
+	}, "alice")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if g.Images[0].Key != "products/smoked/product-002.jpg" || !g.Images[0].Primary || g.Images[1].Primary {
+		t.Errorf("first image should be normalised and primary by default: %+v", g.Images)
+	}
+
+	bad := []struct {
+		name   string
+		id     string
+		images []db.ProductImage
+	}{
+		{"bad id", "sku/1", []db.ProductImage{{Key: "products/smoked/product-001.jpg"}}},
+		{"empty", "SKU-1", nil},
+		{"missing asset", "SKU-1", []db.ProductImage{{Key: "products/smoked/nope.jpg"}}},
+		{"duplicate", "SKU-1", []db.ProductImage{{Key: "products/smoked/product-001.jpg"}, {Key: "products/smoked/product-001.jpg"}}},
+		{"bad role", "SKU-1", []db.ProductImage{{Key: "products/smoked/product-001.jpg", Role: "banner"}}},
+		{"two primaries", "SKU-1", []db.ProductImage{
+			{Key: "products/smoked/product-001.jpg", Primary: true},
+			{Key: "products/smoked/product-002.jpg", Primary: true},
+		}},
+	}
+	for _, tt := range bad {
+		if _, err := s.Put(ctx, tt.id, tt.images, "alice"); !errors.Is(err, api.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", tt.name, err)
+		}
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	s, store := newTestService(t)
+	r := mux.NewRouter()
+	s.Register(r)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/api/v1/products/SKU-1001/gallery",
+		`{"images":[{"key":"products/smoked/product-001.jpg","role":"hero"},{"key":"products/smoked/product-002.jpg","role":"thumbnail","primary":true}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/products/SKU-1001/images", "")
+	var resp struct {
+		Primary Image   `json:"primary"`
+		Images  []Image `json:"images"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Images) != 2 {
+		t.Fatalf("images = %d %+v, %v", rec.Code, resp, err)
+	}
+	hero := resp.Images[0]
+	wantSrcset := "https://cdn.example.com/assets/products/smoked/product-001@320w.jpg 320w, " +
+		"https://cdn.example.com/assets/products/smoked/product-001@640w.jpg 640w, " +
+		"https://cdn.example.com/assets/products/smoked/product-001.jpg 1200w"
+	if hero.URL != "https://cdn.example.com/assets/products/smoked/product-001.jpg" || hero.Srcset != wantSrcset ||
+		hero.Width != 1200 || hero.AltText != "Smoked salmon side" {
+		t.Errorf("hero = %+v", hero)
+	}
+	if resp.Primary.Key != "products/smoked/product-002.jpg" || resp.Images[1].Srcset != "" {
+		t.Errorf("primary = %+v, second = %+v", resp.Primary, resp.Images[1])
+	}
+	if store.lists != 1 {
+		t.Errorf("store listed %d times, want once for the key index", store.lists)
+	}
+
+	if rec := do(http.MethodDelete, "/api/v1/products/SKU-1001/gallery", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/v1/products/SKU-1001/images", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("images after delete status = %d, want 404", rec.Code)
+	}
+}
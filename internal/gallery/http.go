@@ -0,0 +1,72 @@
+package gallery
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the gallery endpoints:
+//
+//	GET    /api/v1/products/{id}/gallery   stored gallery
+//	PUT    /api/v1/products/{id}/gallery   replace the gallery {"images":[...]}
+//	DELETE /api/v1/products/{id}/gallery   remove the gallery
+//	GET    /api/v1/products/{id}/images    resolved URLs, dimensions and srcset
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/products/{id}/gallery", s.handleGet).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/products/{id}/gallery", s.handlePut).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/products/{id}/gallery", s.handleDelete).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/products/{id}/images", s.handleImages).Methods(http.MethodGet)
+}
+
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	g, err := s.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, g)
+}
+
+func (s *Service) handlePut(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Images []db.ProductImage `json:"images"`
+	}
+	if err := api.DecodeJSON(w, r, &body); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	g, err := s.Put(r.Context(), mux.Vars(r)["id"], body.Images, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, g)
+}
+
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.Delete(r.Context(), mux.Vars(r)["id"]); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleImages(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	images, err := s.Images(r.Context(), id)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	resp := map[string]any{"productId": id, "images": images}
+	for _, img := range images {
+		if img.Primary {
+			resp["primary"] = img
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, resp)
+}
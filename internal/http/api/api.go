@@ -0,0 +1,93 @@
+// Package api holds helpers shared by the /api/v1 handlers.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/storage"
+)
+
+// MaxUploadBytes caps request bodies accepted by write endpoints.
+const MaxUploadBytes = 20 << 20
+
+var (
+	// ErrTooLarge is returned by ReadBody when the body exceeds the cap.
+	ErrTooLarge = errors.New("request body too large")
+	// ErrBadRequest wraps errors caused by malformed requests.
+	ErrBadRequest = errors.New("bad request")
+	// ErrRejected wraps errors for well-formed content refused by policy.
+	ErrRejected = errors.New("rejected by policy")
+)
+
+// WriteJSON writes v as a JSON response with the given status.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Error writes {"error": msg} with the given status.
+func Error(w http.ResponseWriter, status int, msg string) {
+	WriteJSON(w, status, map[string]string{"error": msg})
+}
+
+// Actor identifies who made a request, from the X-User header set by the
+// gateway. It falls back to "anonymous".
+func Actor(r *http.Request) string {
+	if u := strings.TrimSpace(r.Header.Get("X-User")); u != "" {
+		return u
+	}
+	return "anonymous"
+}
+
+// Key returns the normalised {key} route variable.
+func Key(r *http.Request) (string, error) {
+	return storage.NormalizeKey(mux.Vars(r)["key"])
+}
+
+// ReadBody reads the request body, failing if it exceeds MaxUploadBytes.
+func ReadBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxUploadBytes))
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return nil, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, tooBig.Limit)
+		}
+		return nil, fmt.Errorf("%w: read body: %v", ErrBadRequest, err)
+	}
+	return data, nil
+}
+
+// DecodeJSON decodes the request body into v.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxUploadBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid JSON: %v", ErrBadRequest, err)
+	}
+	return nil
+}
+
+// StatusFor maps storage and repository errors to an HTTP status.
+func StatusFor(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrInvalidKey), errors.Is(err, ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrRejected):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,156 @@
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/storage"
+)
+
+// maxBatchKeys caps the keys in one batch check.
+const maxBatchKeys = 10000
+
+// DefaultBatchWorkers is how many keys a batch check looks at in parallel
+// unless told otherwise.
+const DefaultBatchWorkers = 16
+
+// BatchResult describes one key of a batch check. Size, ContentType and
+// SHA256 are those a GET of the key would serve.
+type BatchResult struct {
+	Key         string    `json:"key"`
+	Exists      bool      `json:"exists"`
+	Size        int64     `json:"size,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"`
+	ModTime     time.Time `json:"modTime,omitzero"`
+	// Error is set when the key is invalid or could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// Batch checks many keys at once, so clients verifying a catalog's images
+// need not send a request per image.
+type Batch struct {
+	store   func(r *http.Request) storage.Storage
+	workers int
+}
+
+// NewBatch returns a Batch reading from store, which is called on every
+// request like Handler.Store, with at most workers keys in flight.
+func NewBatch(store func(r *http.Request) storage.Storage, workers int) *Batch {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	return &Batch{store: store, workers: workers}
+}
+
+// Check looks up keys in src and returns their results in the same order.
+func (b *Batch) Check(ctx context.Context, src storage.Storage, keys []string) []BatchResult {
+	out := make([]BatchResult, len(keys))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(b.workers, len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				out[i] = checkKey(src, keys[i])
+			}
+		}()
+	}
+	for i := range keys {
+		if ctx.Err() != nil {
+			out[i] = BatchResult{Key: keys[i], Error: ctx.Err().Error()}
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return out
+}
+
+func checkKey(src storage.Storage, key string) BatchResult {
+	res := BatchResult{Key: key}
+	clean, err := storage.NormalizeKey(key)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	info, err := storage.Stat(src, clean)
+	if errors.Is(err, storage.ErrNotFound) {
+		return res
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Exists, res.Size, res.ModTime = true, info.Size, info.ModTime
+
+	r, err := storage.Open(src, clean)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer r.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		res.Error = err.Error()
+		return res
+	}
+	sum := sha256.New()
+	sum.Write(head[:n])
+	if _, err := io.Copy(sum, r); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.ContentType = storage.DetectContentType(clean, head[:n])
+	res.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return res
+}
+
+// Register mounts the batch endpoint:
+//
+//	POST /api/v1/assets/_batch   {"keys": [...]}
+//
+// The response lists a result per key, in request order, with counts of
+// the keys found and missing.
+func (b *Batch) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/assets/_batch", b.handleCheck).Methods(http.MethodPost)
+}
+
+func (b *Batch) handleCheck(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := api.DecodeJSON(w, r, &req); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	if len(req.Keys) > maxBatchKeys {
+		api.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("%d keys, the limit is %d", len(req.Keys), maxBatchKeys))
+		return
+	}
+	results := b.Check(r.Context(), b.store(r), req.Keys)
+	found := 0
+	for _, res := range results {
+		if res.Exists {
+			found++
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{
+		"results": results,
+		"found":   found,
+		"missing": len(results) - found,
+	})
+}
@@ -0,0 +1,65 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBatch(t *testing.T) {
+	logo := `<svg xmlns="http://www.w3.org/2000/svg"/>`
+	h := newTestHandler(t, map[string]string{
+		"ui/logo.svg":                        logo,
+		"products/shellfish/product-001.jpg": "jpeg bytes",
+	})
+	r := mux.NewRouter()
+	NewBatch(h.Store, 2).Register(r)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/assets/_batch", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post(`{"keys":["ui/logo.svg","products/shellfish/missing.jpg","../secret","products/shellfish/product-001.jpg"]}`)
+	var resp struct {
+		Results []BatchResult `json:"results"`
+		Found   int           `json:"found"`
+		Missing int           `json:"missing"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST = %d, %v", rec.Code, err)
+	}
+	if len(resp.Results) != 4 || resp.Found != 2 || resp.Missing != 2 {
+		t.Fatalf("response = %+v", resp)
+	}
+	sum := sha256.Sum256([]byte(logo))
+	if got := resp.Results[0]; !got.Exists || got.Size != int64(len(logo)) || got.ContentType != "image/svg+xml" || got.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("logo = %+v", got)
+	}
+	if got := resp.Results[1]; got.Exists || got.Error != "" {
+		t.Errorf("missing = %+v", got)
+	}
+	if got := resp.Results[2]; got.Exists || got.Error == "" {
+		t.Errorf("invalid key = %+v", got)
+	}
+	if got := resp.Results[3]; got.Key != "products/shellfish/product-001.jpg" || got.ContentType != "image/jpeg" {
+		t.Errorf("result order or content = %+v", got)
+	}
+
+	keys := make([]string, maxBatchKeys+1)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+	}
+	body, _ := json.Marshal(map[string][]string{"keys": keys})
+	if rec := post(string(body)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch status = %d, want 413", rec.Code)
+	}
+}
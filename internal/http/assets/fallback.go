@@ -0,0 +1,219 @@
+package assets
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// maxFallbackKeys bounds the number of distinct missing keys counted, so
+// requests for random paths cannot grow the table without limit. Hits on
+// further keys are only added to Dropped.
+const maxFallbackKeys = 10000
+
+// fallbackMaxAge is the Cache-Control max-age of fallback responses; it is
+// short so a missing asset shows up soon after it is uploaded.
+const fallbackMaxAge = 300
+
+// FallbackRule maps missing keys under Prefix to the Target key.
+type FallbackRule struct {
+	Prefix string
+	Target string
+	// Exact makes Prefix match only the key itself.
+	Exact bool
+}
+
+func (r FallbackRule) matches(key string) bool {
+	if r.Exact {
+		return key == r.Prefix
+	}
+	return strings.HasPrefix(key, r.Prefix)
+}
+
+// ParseFallbackRules parses comma-separated pattern=target pairs, e.g.
+// "products/shellfish/*=placeholders/shellfish.svg,*=placeholders/default.svg".
+// A pattern ending in "*" or "/" matches keys by prefix; any other pattern
+// matches one key.
+func ParseFallbackRules(s string) ([]FallbackRule, error) {
+	var rules []FallbackRule
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		pattern, target, ok := strings.Cut(pair, "=")
+		pattern, target = strings.TrimSpace(pattern), strings.TrimSpace(target)
+		if !ok || target == "" {
+			return nil, fmt.Errorf("fallback rule %q: want pattern=target", pair)
+		}
+		target, err := storage.NormalizeKey(target)
+		if err != nil {
+			return nil, fmt.Errorf("fallback rule %q: %w", pair, err)
+		}
+		rule := FallbackRule{Prefix: strings.TrimSuffix(pattern, "*"), Target: target}
+		rule.Exact = rule.Prefix == pattern && !strings.HasSuffix(pattern, "/")
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// FallbackHit counts the requests for one missing key.
+type FallbackHit struct {
+	Key       string    `json:"key"`
+	Fallback  string    `json:"fallback"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Fallbacks serves a stand-in image for missing assets and counts the
+// misses so the assets can be fixed.
+type Fallbacks struct {
+	rules []FallbackRule
+	// status is 200 or 404; both carry X-Asset-Fallback: true.
+	status int
+
+	mu      sync.Mutex
+	hits    map[string]*FallbackHit
+	dropped int64
+	now     func() time.Time
+}
+
+// NewFallbacks returns Fallbacks for rules, answering with status
+// (http.StatusOK or http.StatusNotFound). The most specific matching rule
+// wins.
+func NewFallbacks(rules []FallbackRule, status int) *Fallbacks {
+	rules = append([]FallbackRule(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Exact != rules[j].Exact {
+			return rules[i].Exact
+		}
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+	return &Fallbacks{rules: rules, status: status, hits: make(map[string]*FallbackHit), now: time.Now}
+}
+
+// target returns the fallback key for a missing key.
+func (f *Fallbacks) target(key string) (string, bool) {
+	for _, r := range f.rules {
+		if r.matches(key) {
+			return r.Target, true
+		}
+	}
+	return "", false
+}
+
+// record counts a hit. The first hit on a key is logged as a warning.
+func (f *Fallbacks) record(key, target string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now().UTC()
+	h, ok := f.hits[key]
+	switch {
+	case ok:
+		h.Count++
+		h.LastSeen = now
+		logger.Debugf("[fallback] %s missing, served %s", key, target)
+	case len(f.hits) >= maxFallbackKeys:
+		f.dropped++
+	default:
+		f.hits[key] = &FallbackHit{Key: key, Fallback: target, Count: 1, FirstSeen: now, LastSeen: now}
+		logger.Warnf("[fallback] %s missing, served %s", key, target)
+	}
+}
+
+// serve writes the fallback for a missing key. It reports false when no
+// rule matches or the fallback itself is missing.
+func (f *Fallbacks) serve(w http.ResponseWriter, r *http.Request, store storage.Storage, key string) bool {
+	target, ok := f.target(key)
+	if !ok {
+		return false
+	}
+	obj, err := storage.Open(store, target)
+	if err != nil {
+		logger.Errorf("[fallback] %s for %s: %v", target, key, err)
+		return false
+	}
+	defer obj.Close()
+	f.record(key, target)
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(obj, head)
+	if _, err := obj.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", storage.DetectContentType(target, head[:n]))
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(fallbackMaxAge))
+	w.Header().Set("X-Asset-Fallback", "true")
+	if f.status == http.StatusOK {
+		info, _ := storage.Stat(store, target)
+		http.ServeContent(w, r, target, info.ModTime, obj)
+		return true
+	}
+	w.WriteHeader(f.status)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, obj)
+	}
+	return true
+}
+
+// Hits returns the counted misses, most requested first.
+func (f *Fallbacks) Hits() ([]FallbackHit, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]FallbackHit, 0, len(f.hits))
+	for _, h := range f.hits {
+		out = append(out, *h)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out, f.dropped
+}
+
+// Reset clears the counters, e.g. after the missing assets were fixed.
+func (f *Fallbacks) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hits = make(map[string]*FallbackHit)
+	f.dropped = 0
+}
+
+// Register mounts the fallback report:
+//
+//	GET    /api/v1/fallbacks?prefix=products/   missing keys served a fallback
+//	DELETE /api/v1/fallbacks                    reset the counters
+func (f *Fallbacks) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/fallbacks", f.handleList).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/fallbacks", f.handleReset).Methods(http.MethodDelete)
+}
+
+func (f *Fallbacks) handleList(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	all, dropped := f.Hits()
+	hits := all[:0]
+	for _, h := range all {
+		if strings.HasPrefix(h.Key, prefix) {
+			hits = append(hits, h)
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"hits": hits, "dropped": dropped})
+}
+
+func (f *Fallbacks) handleReset(w http.ResponseWriter, _ *http.Request) {
+	f.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
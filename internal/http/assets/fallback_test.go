@@ -0,0 +1,98 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestParseFallbackRules(t *testing.T) {
+	rules, err := ParseFallbackRules("products/shellfish/*=placeholders/shellfish.svg, ui/logo.svg=placeholders/logo.svg,*=placeholders/default.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FallbackRule{
+		{Prefix: "products/shellfish/", Target: "placeholders/shellfish.svg"},
+		{Prefix: "ui/logo.svg", Target: "placeholders/logo.svg", Exact: true},
+		{Prefix: "", Target: "placeholders/default.svg"},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("rules = %+v", rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"products/*", "products/*=", "x=../etc/passwd"} {
+		if _, err := ParseFallbackRules(bad); err == nil {
+			t.Errorf("ParseFallbackRules(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	h := newTestHandler(t, map[string]string{
+		"placeholders/shellfish.svg":         `<svg xmlns="http://www.w3.org/2000/svg"><title>shellfish</title></svg>`,
+		"placeholders/default.svg":           `<svg xmlns="http://www.w3.org/2000/svg"><title>default</title></svg>`,
+		"products/shellfish/product-001.jpg": "real",
+	})
+	rules, _ := ParseFallbackRules("products/shellfish/*=placeholders/shellfish.svg,products/*=placeholders/default.svg,docs/*=docs/missing.svg")
+	h.Fallbacks = NewFallbacks(rules, http.StatusNotFound)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/assets/products/shellfish/product-404.jpg")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("X-Asset-Fallback") != "true" ||
+		rec.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(rec.Body.String(), "shellfish") {
+		t.Errorf("shellfish miss = %d %v %q", rec.Code, rec.Header(), rec.Body)
+	}
+	get("/assets/products/shellfish/product-404.jpg")
+	if rec := get("/assets/products/frozen/product-404.jpg"); !strings.Contains(rec.Body.String(), "default") {
+		t.Errorf("frozen miss served %q, want the default fallback", rec.Body)
+	}
+	if rec := get("/assets/products/shellfish/product-001.jpg"); rec.Code != http.StatusOK || rec.Header().Get("X-Asset-Fallback") != "" {
+		t.Errorf("existing asset = %d %v", rec.Code, rec.Header())
+	}
+	// No rule, or a missing fallback target: a bare 404.
+	for _, path := range []string{"/assets/banners/nope.png", "/assets/docs/nope.svg", "/assets/../main.go"} {
+		if rec := get(path); rec.Code != http.StatusNotFound || rec.Header().Get("X-Asset-Fallback") != "" {
+			t.Errorf("%s = %d %v", path, rec.Code, rec.Header())
+		}
+	}
+
+	h.Fallbacks.status = http.StatusOK
+	if rec := get("/assets/products/smoked/product-404.jpg"); rec.Code != http.StatusOK || rec.Header().Get("X-Asset-Fallback") != "true" {
+		t.Errorf("200 mode = %d %v", rec.Code, rec.Header())
+	}
+
+	r := mux.NewRouter()
+	h.Fallbacks.Register(r)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/fallbacks?prefix=products/shellfish/", nil))
+	var report struct {
+		Hits    []FallbackHit `json:"hits"`
+		Dropped int64         `json:"dropped"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Hits) != 1 || report.Hits[0].Count != 2 || report.Hits[0].Fallback != "placeholders/shellfish.svg" {
+		t.Errorf("report = %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/fallbacks", nil))
+	if hits, _ := h.Fallbacks.Hits(); rec.Code != http.StatusNoContent || len(hits) != 0 {
+		t.Errorf("reset = %d, %d hits left", rec.Code, len(hits))
+	}
+}
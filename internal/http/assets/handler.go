@@ -0,0 +1,143 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+	"codlocker-assets/internal/transform"
+)
+
+// Handler serves GET requests for stored assets.
+type Handler struct {
+	// Prefix is stripped from the URL path to obtain the storage key.
+	Prefix string
+
+	// Store picks the backend for a request. It is called on every request
+	// so feature-flag flips take effect without a restart.
+	Store func(r *http.Request) storage.Storage
+
+	// Versions, when set, serves ?version= reads of historical versions.
+	Versions VersionOpener
+
+	// VariantPrefixes lists key prefixes (e.g. "banners/") whose assets
+	// have locale and theme variants to negotiate; see negotiate.
+	VariantPrefixes []string
+
+	// Keys, when set, answers the sibling lookups of variant negotiation
+	// and sizing for the store it covers, instead of listing the store on
+	// every request.
+	Keys *storage.KeyIndex
+
+	// Resizer, when set, generates downscaled or converted PNG and JPEG
+	// renditions for Client Hints, ?w= and ?fm= requests; see fit.
+	Resizer *transform.Resizer
+
+	// BlurHash, when set, looks up the BlurHash of a live asset for the
+	// X-Blurhash response header; "" omits the header.
+	BlurHash func(ctx context.Context, key string) string
+
+	// Fallbacks, when set, serves a stand-in image for missing assets
+	// instead of a bare 404.
+	Fallbacks *Fallbacks
+
+	// Redirects, when set, is checked before storage for moved or
+	// renamed keys.
+	Redirects Redirector
+
+	// Accessed, when set, is told the key of every asset served from
+	// storage on a GET, e.g. to keep read statistics. It must not block.
+	Accessed func(key string)
+}
+
+// VersionOpener opens a historical version of an asset.
+type VersionOpener interface {
+	OpenVersion(ctx context.Context, key, id string) (io.ReadSeekCloser, storage.Info, error)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Extract path after the prefix
+	key := strings.TrimPrefix(r.URL.Path, h.Prefix)
+
+	if h.Redirects != nil {
+		var done bool
+		if key, done = h.redirect(w, r, key); done {
+			return
+		}
+	}
+
+	obj, info, store, key, err := h.open(w, r, key)
+	if err != nil && store != nil && h.Fallbacks != nil && errors.Is(err, storage.ErrNotFound) && h.Fallbacks.serve(w, r, store, key) {
+		return
+	}
+	if err != nil {
+		logger.Debugf("asset not found: %s (%v)", key, err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	defer func() { obj.Close() }()
+
+	// Sniff the first bytes for content type, then rewind for serving.
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(obj, head)
+	if _, err := obj.Seek(0, io.SeekStart); err != nil {
+		logger.Errorf("asset seek failed: %s (%v)", key, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	contentType := storage.DetectContentType(key, head[:n])
+
+	if store != nil {
+		if sized, sizedInfo, sizedType, ok := h.fit(w, r, store, key, contentType, obj, info); ok {
+			obj.Close()
+			obj, info, contentType = sized, sizedInfo, sizedType
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000") // 1 year cache
+	if info.ETag != "" {
+		w.Header().Set("ETag", `"`+info.ETag+`"`)
+	}
+	if h.BlurHash != nil && store != nil {
+		if hash := h.BlurHash(r.Context(), key); hash != "" {
+			w.Header().Set("X-Blurhash", hash)
+		}
+	}
+	if h.Accessed != nil && store != nil && r.Method == http.MethodGet {
+		h.Accessed(key)
+	}
+	http.ServeContent(w, r, key, info.ModTime, obj)
+}
+
+// lister returns what lists the keys of store: the key index when it
+// covers store, otherwise store itself if it can list.
+func (h *Handler) lister(store storage.Storage) (storage.Lister, bool) {
+	if h.Keys != nil && h.Keys.Covers(store) {
+		return h.Keys, true
+	}
+	l, ok := store.(storage.Lister)
+	return l, ok
+}
+
+// open resolves the object for a request: a pinned version when ?version=
+// is given, otherwise the negotiated variant from the selected store. It
+// returns the store (nil for versions), also on error, and the resolved key.
+func (h *Handler) open(w http.ResponseWriter, r *http.Request, key string) (io.ReadSeekCloser, storage.Info, storage.Storage, string, error) {
+	if id := r.URL.Query().Get("version"); id != "" && h.Versions != nil {
+		obj, info, err := h.Versions.OpenVersion(r.Context(), key, id)
+		return obj, info, nil, key, err
+	}
+	store := h.Store(r)
+	key = h.negotiate(w, r, store, key)
+	obj, err := storage.Open(store, key)
+	if err != nil {
+		return nil, storage.Info{}, store, key, err
+	}
+	info, _ := storage.Stat(store, key)
+	return obj, info, store, key, nil
+}
@@ -0,0 +1,152 @@
+package assets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codlocker-assets/internal/storage"
+)
+
+func newTestHandler(t *testing.T, files map[string]string) *Handler {
+	t.Helper()
+	root := t.TempDir()
+	for key, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := storage.NewLocalStorage(root)
+	return &Handler{
+		Prefix: "/assets/",
+		Store:  func(*http.Request) storage.Storage { return store },
+	}
+}
+
+func TestHandlerServe(t *testing.T) {
+	h := newTestHandler(t, map[string]string{
+		"products/frozen/product-001.jpg": `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"banners/spring.png":              "\x89PNG\r\n\x1a\nrest-of-png",
+		"docs/readme":                     "plain",
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantType    string
+		wantBodyLen int
+	}{
+		{"svg named jpg", "/assets/products/frozen/product-001.jpg", http.StatusOK, "image/svg+xml", 46},
+		{"png by extension", "/assets/banners/spring.png", http.StatusOK, "image/png", 19},
+		{"unknown extension", "/assets/docs/readme", http.StatusOK, "application/octet-stream", 5},
+		{"missing", "/assets/products/frozen/product-404.jpg", http.StatusNotFound, "", -1},
+		{"traversal", "/assets/../main.go", http.StatusNotFound, "", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantType != "" && rec.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantType)
+			}
+			if tt.wantBodyLen >= 0 && rec.Body.Len() != tt.wantBodyLen {
+				t.Errorf("body length = %d, want %d", rec.Body.Len(), tt.wantBodyLen)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Header().Get("Cache-Control") == "" {
+				t.Errorf("Cache-Control should be set")
+			}
+		})
+	}
+}
+
+func TestHandlerRange(t *testing.T) {
+	h := newTestHandler(t, map[string]string{"banners/spring.png": "0123456789"})
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/banners/spring.png", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusPartialContent)
+	}
+	if rec.Body.String() != "2345" {
+		t.Errorf("body = %q, want %q", rec.Body.String(), "2345")
+	}
+}
+
+// etagStore is a single-object store that reports an ETag.
+type etagStore struct{}
+
+func (etagStore) Get(string) ([]byte, error) { return []byte("<svg/>"), nil }
+func (etagStore) Exists(string) bool         { return true }
+func (etagStore) Stat(key string) (storage.Info, error) {
+	return storage.Info{Key: key, Size: 6, ETag: "3b18e512dba79e4c8300dd08aeb37f8e728b8dad"}, nil
+}
+
+func TestHandlerETag(t *testing.T) {
+	h := &Handler{Prefix: "/assets/", Store: func(*http.Request) storage.Storage { return etagStore{} }}
+
+	req := httptest.NewRequest(http.MethodGet, "/assets/ui/logo.svg", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+	if etag != `"3b18e512dba79e4c8300dd08aeb37f8e728b8dad"` {
+		t.Fatalf("ETag = %q", etag)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/assets/ui/logo.svg", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+}
+
+func TestHandlerBlurHash(t *testing.T) {
+	h := newTestHandler(t, map[string]string{"banners/spring.png": "png"})
+	h.BlurHash = func(_ context.Context, key string) string {
+		if key == "banners/spring.png" {
+			return "L00000fQfQfQfQfQfQfQfQfQfQfQ"
+		}
+		return ""
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/banners/spring.png", nil))
+	if got := rec.Header().Get("X-Blurhash"); got != "L00000fQfQfQfQfQfQfQfQfQfQfQ" {
+		t.Errorf("X-Blurhash = %q", got)
+	}
+}
+
+func TestHandlerAccessed(t *testing.T) {
+	h := newTestHandler(t, map[string]string{"reviews/r-1.jpg": "jpeg"})
+	var got []string
+	h.Accessed = func(key string) { got = append(got, key) }
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/assets/reviews/r-1.jpg", nil),
+		httptest.NewRequest(http.MethodHead, "/assets/reviews/r-1.jpg", nil),
+		httptest.NewRequest(http.MethodGet, "/assets/reviews/missing.jpg", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(got) != 1 || got[0] != "reviews/r-1.jpg" {
+		t.Errorf("accessed = %q, want one GET of reviews/r-1.jpg", got)
+	}
+}
@@ -0,0 +1,185 @@
+package assets
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"codlocker-assets/internal/storage"
+)
+
+// Variant naming: a logical key such as banners/spring.svg may have
+// siblings that add a locale and/or theme before the extension, in either
+// order:
+//
+//	banners/spring.fr-FR.svg
+//	banners/spring.dark.svg
+//	banners/spring.fr-FR.dark.svg
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// colorSchemeHint is the client hint carrying the preferred theme.
+const colorSchemeHint = "Sec-CH-Prefers-Color-Scheme"
+
+type variant struct {
+	key    string
+	locale string
+	theme  string
+}
+
+// parseVariant splits a sibling of stem+ext into its locale and theme.
+func parseVariant(stem, ext, key string) (variant, bool) {
+	if !strings.HasPrefix(key, stem+".") || !strings.HasSuffix(key, ext) || len(key) <= len(stem)+1+len(ext) {
+		return variant{}, false
+	}
+	v := variant{key: key}
+	for _, part := range strings.Split(key[len(stem)+1:len(key)-len(ext)], ".") {
+		switch {
+		case (part == ThemeLight || part == ThemeDark) && v.theme == "":
+			v.theme = part
+		case v.locale == "":
+			tag, err := language.Parse(part)
+			if err != nil || tag.String() != part {
+				return variant{}, false
+			}
+			v.locale = part
+		default:
+			return variant{}, false
+		}
+	}
+	return v, true
+}
+
+// negotiable reports whether key lives under one of the variant prefixes.
+func (h *Handler) negotiable(key string) bool {
+	key = strings.TrimLeft(key, "/")
+	for _, p := range h.VariantPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate resolves a logical key to its best locale/theme variant and
+// sets the Vary, Accept-CH and Content-Language headers that go with it.
+// Preference is locale+theme, then locale, then theme, then the logical
+// key itself; when none of those exist the first variant by key is used.
+func (h *Handler) negotiate(w http.ResponseWriter, r *http.Request, store storage.Storage, key string) string {
+	lister, ok := h.lister(store)
+	if !ok || !h.negotiable(key) {
+		return key
+	}
+	clean, err := storage.NormalizeKey(key)
+	if err != nil {
+		return key
+	}
+	ext := path.Ext(clean)
+	stem := strings.TrimSuffix(clean, ext)
+	keys, err := lister.List(stem + ".")
+	if err != nil {
+		return key
+	}
+
+	byLocaleTheme := make(map[[2]string]string)
+	var locales []string
+	var themed bool
+	for _, k := range keys {
+		v, ok := parseVariant(stem, ext, k)
+		if !ok {
+			continue
+		}
+		if _, dup := byLocaleTheme[[2]string{v.locale, v.theme}]; dup {
+			continue
+		}
+		byLocaleTheme[[2]string{v.locale, v.theme}] = v.key
+		if v.locale != "" && !slices.Contains(locales, v.locale) {
+			locales = append(locales, v.locale)
+		}
+		themed = themed || v.theme != ""
+	}
+	if len(byLocaleTheme) == 0 {
+		return key
+	}
+
+	var locale, theme string
+	if len(locales) > 0 {
+		w.Header().Add("Vary", "Accept-Language")
+		sort.Strings(locales)
+		locale = matchLocale(r, locales)
+	}
+	if themed {
+		w.Header().Add("Vary", colorSchemeHint)
+		w.Header().Add("Accept-CH", colorSchemeHint)
+		theme = preferredTheme(r)
+	}
+
+	chosen := ""
+	for _, c := range [][2]string{{locale, theme}, {locale, ""}, {"", theme}} {
+		if k, ok := byLocaleTheme[c]; ok && (c[0] != "" || c[1] != "") {
+			chosen = k
+			locale = c[0]
+			break
+		}
+	}
+	if chosen == "" {
+		locale = ""
+		if store.Exists(clean) {
+			return clean
+		}
+		// No logical asset: fall back to the first variant by key.
+		for _, k := range byLocaleTheme {
+			if chosen == "" || k < chosen {
+				chosen = k
+			}
+		}
+		if v, ok := parseVariant(stem, ext, chosen); ok {
+			locale = v.locale
+		}
+	}
+	if locale != "" {
+		w.Header().Set("Content-Language", locale)
+	}
+	w.Header().Set("Content-Location", h.Prefix+chosen)
+	return chosen
+}
+
+// matchLocale picks the available locale for the ?lang= parameter or the
+// Accept-Language header, or "" when nothing matches.
+func matchLocale(r *http.Request, available []string) string {
+	accept := r.Header.Get("Accept-Language")
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		accept = lang
+	}
+	desired, _, err := language.ParseAcceptLanguage(accept)
+	if err != nil || len(desired) == 0 {
+		return ""
+	}
+	tags := make([]language.Tag, len(available))
+	for i, l := range available {
+		tags[i] = language.Make(l)
+	}
+	_, idx, conf := language.NewMatcher(tags).Match(desired...)
+	if conf == language.No {
+		return ""
+	}
+	return available[idx]
+}
+
+// preferredTheme reads ?theme= or the color-scheme client hint.
+func preferredTheme(r *http.Request) string {
+	theme := r.URL.Query().Get("theme")
+	if theme == "" {
+		theme = strings.Trim(r.Header.Get(colorSchemeHint), `" `)
+	}
+	if theme == ThemeLight || theme == ThemeDark {
+		return theme
+	}
+	return ""
+}
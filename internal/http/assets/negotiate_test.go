@@ -0,0 +1,122 @@
+package assets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"codlocker-assets/internal/storage"
+)
+
+func TestNegotiateVariants(t *testing.T) {
+	h := newTestHandler(t, map[string]string{
+		"banners/spring.svg":            "default",
+		"banners/spring.fr-FR.svg":      "fr-FR",
+		"banners/spring.de.svg":         "de",
+		"banners/spring.dark.svg":       "dark",
+		"banners/spring.fr-FR.dark.svg": "fr-FR dark",
+		"banners/spring.v2.svg":         "not a variant",
+		"banners/summer.de.svg":         "summer de",
+		"banners/summer.en.svg":         "summer en",
+		"products/fish.fr.jpg":          "ignored outside variant prefixes",
+		"products/fish.jpg":             "fish",
+	})
+	h.VariantPrefixes = []string{"banners/"}
+
+	tests := []struct {
+		name     string
+		path     string
+		header   map[string]string
+		wantBody string
+		wantLang string
+		wantVary []string
+	}{
+		{"no preference", "/assets/banners/spring.svg", nil, "default", "", []string{"Accept-Language", colorSchemeHint}},
+		{"locale", "/assets/banners/spring.svg", map[string]string{"Accept-Language": "fr-CA, fr;q=0.9"}, "fr-FR", "fr-FR", nil},
+		{"locale and theme", "/assets/banners/spring.svg",
+			map[string]string{"Accept-Language": "fr", colorSchemeHint: `"dark"`}, "fr-FR dark", "fr-FR", nil},
+		{"theme without locale variant", "/assets/banners/spring.svg",
+			map[string]string{"Accept-Language": "de", colorSchemeHint: "dark"}, "de", "de", nil},
+		{"theme only", "/assets/banners/spring.svg", map[string]string{colorSchemeHint: "dark"}, "dark", "", nil},
+		{"unmatched locale", "/assets/banners/spring.svg", map[string]string{"Accept-Language": "ja"}, "default", "", nil},
+		{"query overrides headers", "/assets/banners/spring.svg?lang=de&theme=light",
+			map[string]string{"Accept-Language": "fr"}, "de", "de", nil},
+		{"variant requested directly", "/assets/banners/spring.de.svg", map[string]string{"Accept-Language": "fr"}, "de", "", []string{}},
+		{"no logical asset", "/assets/banners/summer.svg", map[string]string{"Accept-Language": "ja"}, "summer de", "de", nil},
+		{"outside prefixes", "/assets/products/fish.jpg", map[string]string{"Accept-Language": "fr"}, "fish", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.wantBody {
+				t.Fatalf("got %d %q, want %q", rec.Code, rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Language"); got != tt.wantLang {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLang)
+			}
+			if tt.wantVary != nil {
+				got := rec.Header().Values("Vary")
+				if len(got) != len(tt.wantVary) {
+					t.Fatalf("Vary = %v, want %v", got, tt.wantVary)
+				}
+				for i := range got {
+					if got[i] != tt.wantVary[i] {
+						t.Errorf("Vary = %v, want %v", got, tt.wantVary)
+					}
+				}
+			}
+		})
+	}
+}
+
+// countingLister counts the listings of a store.
+type countingLister struct {
+	storage.Storage
+	lists int
+}
+
+func (c *countingLister) List(prefix string) ([]string, error) {
+	c.lists++
+	return c.Storage.(storage.Lister).List(prefix)
+}
+
+func TestNegotiateKeyIndex(t *testing.T) {
+	h := newTestHandler(t, map[string]string{
+		"banners/spring.svg":    "default",
+		"banners/spring.de.svg": "de",
+	})
+	store := &countingLister{Storage: h.Store(nil)}
+	h.Store = func(*http.Request) storage.Storage { return store }
+	h.Keys = storage.NewKeyIndex(store, 0)
+	h.VariantPrefixes = []string{"banners/"}
+	get := func(lang string) string {
+		req := httptest.NewRequest(http.MethodGet, "/assets/banners/spring.svg", nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if got := get("de"); got != "de" {
+		t.Errorf("de = %q", got)
+	}
+	// A variant written through an observed service is negotiated at once.
+	if err := store.Storage.(storage.Writer).Put("banners/spring.fr.svg", []byte("fr")); err != nil {
+		t.Fatal(err)
+	}
+	h.Keys.Written(context.Background(), "banners/spring.fr.svg", nil, "alice")
+	if got := get("fr"); got != "fr" {
+		t.Errorf("fr = %q", got)
+	}
+	if store.lists != 1 {
+		t.Errorf("store listed %d times, want once", store.lists)
+	}
+}
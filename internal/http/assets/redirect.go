@@ -0,0 +1,47 @@
+package assets
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"codlocker-assets/internal/storage"
+)
+
+// redirectMaxAge is the Cache-Control max-age of redirects. Browsers cache
+// 301s regardless; this keeps shared caches from holding them forever.
+const redirectMaxAge = 3600
+
+// Redirector resolves moved or renamed keys. Status is 200 for an alias
+// served under the requested URL, or 301/308 for a redirect; a non-zero
+// deprecated time is announced in a Deprecation header.
+type Redirector interface {
+	Resolve(key string) (target string, status int, deprecated time.Time, ok bool)
+}
+
+// redirect applies the redirect table to key. It returns the key to serve,
+// or done when a redirect response has been written.
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, key string) (string, bool) {
+	norm, err := storage.NormalizeKey(key)
+	if err != nil {
+		return key, false
+	}
+	target, status, deprecated, ok := h.Redirects.Resolve(norm)
+	if !ok {
+		return key, false
+	}
+	if !deprecated.IsZero() {
+		// RFC 9745 structured-field date.
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecated.Unix(), 10))
+	}
+	if status == http.StatusOK {
+		return target, false
+	}
+
+	loc := (&url.URL{Path: h.Prefix + target, RawQuery: r.URL.RawQuery}).String()
+	w.Header().Set("Location", loc)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(redirectMaxAge))
+	w.WriteHeader(status)
+	return "", true
+}
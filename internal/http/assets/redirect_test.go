@@ -0,0 +1,59 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapRedirector resolves exact keys and "prefix*" rules from a map.
+type mapRedirector map[string]struct {
+	target string
+	status int
+}
+
+func (m mapRedirector) Resolve(key string) (string, int, time.Time, bool) {
+	if rd, ok := m[key]; ok {
+		return rd.target, rd.status, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), true
+	}
+	for src, rd := range m {
+		if stem, ok := strings.CutSuffix(src, "*"); ok && strings.HasPrefix(key, stem) {
+			return strings.TrimSuffix(rd.target, "*") + key[len(stem):], rd.status, time.Time{}, true
+		}
+	}
+	return "", 0, time.Time{}, false
+}
+
+func TestHandlerRedirects(t *testing.T) {
+	h := newTestHandler(t, map[string]string{"ui/logo.svg": "<svg/>"})
+	h.Redirects = mapRedirector{
+		"logo.jpg":               {"ui/logo.svg", http.StatusOK},
+		"products/electronics/*": {"products/gadgets/*", http.StatusPermanentRedirect},
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/assets/logo.jpg")
+	if rec.Code != http.StatusOK || rec.Body.String() != "<svg/>" {
+		t.Errorf("alias = %d %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Deprecation"); got != "@1780272000" {
+		t.Errorf("Deprecation = %q", got)
+	}
+
+	rec = get("/assets/products/electronics/tv%20stand.jpg?w=320")
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("redirect status = %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/assets/products/gadgets/tv%20stand.jpg?w=320" {
+		t.Errorf("Location = %q", loc)
+	}
+	if rec.Header().Get("Deprecation") != "" {
+		t.Errorf("undeprecated rule sent Deprecation")
+	}
+}
@@ -0,0 +1,156 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"codlocker-assets/internal/imageinfo"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/responsive"
+	"codlocker-assets/internal/storage"
+	"codlocker-assets/internal/transform"
+)
+
+// sizingHints are the Client Hints used to size raster images, in both
+// their legacy and Sec-CH- spellings.
+var sizingHints = []string{"DPR", "Width", "Viewport-Width", "Sec-CH-DPR", "Sec-CH-Width", "Sec-CH-Viewport-Width"}
+
+// maxDPR bounds the device pixel ratio a client can claim.
+const maxDPR = 4
+
+// hint returns the first non-empty value among the legacy and Sec-CH-
+// spellings of a client hint.
+func hint(r *http.Request, name string) string {
+	if v := r.Header.Get("Sec-CH-" + name); v != "" {
+		return v
+	}
+	return r.Header.Get(name)
+}
+
+// targetWidth works out how many physical pixels wide the image should be
+// and how many CSS pixels it will occupy (0 when unknown). An explicit
+// ?w= wins over hints.
+func targetWidth(r *http.Request) (physical int, css float64) {
+	if v := r.URL.Query().Get("w"); v != "" {
+		n, _ := strconv.Atoi(v)
+		return max(n, 0), 0
+	}
+	dpr, err := strconv.ParseFloat(hint(r, "DPR"), 64)
+	if err != nil || dpr <= 0 || math.IsNaN(dpr) {
+		dpr = 1
+	}
+	dpr = min(dpr, maxDPR)
+	// Width is already in physical pixels; Viewport-Width is in CSS pixels.
+	if n, err := strconv.Atoi(hint(r, "Width")); err == nil && n > 0 {
+		return n, float64(n) / dpr
+	}
+	if n, err := strconv.Atoi(hint(r, "Viewport-Width")); err == nil && n > 0 {
+		return int(math.Ceil(float64(n) * dpr)), float64(n)
+	}
+	return 0, 0
+}
+
+// fit serves a narrower rendition of a PNG or JPEG when Client Hints or
+// ?w= ask for fewer pixels than the original has, and converts it when
+// ?fm= names another output format. An existing width variant (see
+// package responsive) is preferred; otherwise one is generated at a
+// snapped width. ok is false when the original should be served, in
+// which case obj has been rewound.
+func (h *Handler) fit(w http.ResponseWriter, r *http.Request, store storage.Storage, key, contentType string,
+	obj io.ReadSeekCloser, info storage.Info) (sized io.ReadSeekCloser, sizedInfo storage.Info, sizedType string, ok bool) {
+	if h.Resizer == nil || (contentType != "image/png" && contentType != "image/jpeg") {
+		return nil, storage.Info{}, "", false
+	}
+	q := r.URL.Query()
+	if !q.Has("w") {
+		w.Header().Add("Accept-CH", "DPR, Width, Viewport-Width")
+		w.Header().Add("Vary", strings.Join(sizingHints, ", "))
+	}
+	format := transform.NormalizeFormat(q.Get("fm"))
+	if !slices.Contains(transform.Formats, format) || transform.ContentType(format) == contentType {
+		format = ""
+	}
+	want, cssWidth := targetWidth(r)
+	if want <= 0 && format == "" {
+		return nil, storage.Info{}, "", false
+	}
+
+	data, err := io.ReadAll(obj)
+	if _, serr := obj.Seek(0, io.SeekStart); err != nil || serr != nil {
+		return nil, storage.Info{}, "", false
+	}
+	intrinsic := imageinfo.Inspect(data).Width
+	if intrinsic == 0 {
+		return nil, storage.Info{}, "", false
+	}
+	width := 0
+	if want > 0 && want < intrinsic {
+		width = transform.Snap(want)
+		if width >= intrinsic {
+			width = 0
+		}
+	}
+	if width == 0 && format == "" {
+		return nil, storage.Info{}, "", false
+	}
+
+	sizedType = contentType
+	if format == "" {
+		if obj, info, vw := h.pickVariant(store, key, want, intrinsic); obj != nil {
+			sized, sizedInfo, width = obj, info, vw
+		}
+	} else {
+		sizedType = transform.ContentType(format)
+	}
+	if sized == nil {
+		out, outInfo, err := h.Resizer.Resize(data, info, width, format)
+		if err != nil {
+			if !errors.Is(err, transform.ErrUnsupported) {
+				logger.Errorf("transform %s (w=%d fm=%s): %v", key, width, format, err)
+			}
+			return nil, storage.Info{}, "", false
+		}
+		sized, sizedInfo = storage.NopCloser(bytes.NewReader(out)), outInfo
+	}
+
+	if cssWidth > 0 {
+		if width == 0 {
+			width = intrinsic
+		}
+		dpr := math.Round(float64(width)/cssWidth*100) / 100
+		w.Header().Set("Content-DPR", strconv.FormatFloat(dpr, 'f', -1, 64))
+	}
+	return sized, sizedInfo, sizedType, true
+}
+
+// pickVariant opens the narrowest stored width variant of key that is at
+// least want pixels wide, no wider than the rendition that would be
+// generated instead, and narrower than the original.
+func (h *Handler) pickVariant(store storage.Storage, key string, want, intrinsic int) (io.ReadSeekCloser, storage.Info, int) {
+	lister, ok := h.lister(store)
+	if !ok {
+		return nil, storage.Info{}, 0
+	}
+	variants, err := responsive.Find(lister, key)
+	if err != nil {
+		return nil, storage.Info{}, 0
+	}
+	for _, v := range variants {
+		if v.Width < want || v.Width > transform.Snap(want) || v.Width >= intrinsic {
+			continue
+		}
+		obj, err := storage.Open(store, v.Key)
+		if err != nil {
+			return nil, storage.Info{}, 0
+		}
+		info, _ := storage.Stat(store, v.Key)
+		return obj, info, v.Width
+	}
+	return nil, storage.Info{}, 0
+}
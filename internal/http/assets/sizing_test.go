@@ -0,0 +1,104 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codlocker-assets/internal/imageinfo"
+	"codlocker-assets/internal/imagetest"
+	"codlocker-assets/internal/storage"
+	"codlocker-assets/internal/transform"
+)
+
+func TestClientHintsSizing(t *testing.T) {
+	root := t.TempDir()
+	files := map[string][]byte{
+		"banners/hero.png":      imagetest.PNG(t, 2000, 1000),
+		"banners/hero@800w.png": imagetest.PNG(t, 800, 400),
+		"banners/small.png":     imagetest.PNG(t, 200, 100),
+		"banners/huge.png":      imagetest.Oversized(60000, 60000),
+		"products/fish.jpg":     []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+	}
+	for key, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(key))
+		_ = os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := &countingLister{Storage: storage.NewLocalStorage(root)}
+	h := &Handler{
+		Prefix:  "/assets/",
+		Store:   func(*http.Request) storage.Storage { return store },
+		Resizer: transform.New(storage.NewLocalStorage(t.TempDir())),
+		Keys:    storage.NewKeyIndex(store, 0),
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		hints     map[string]string
+		wantWidth int
+		wantDPR   string
+	}{
+		{"no hints", "/assets/banners/hero.png", nil, 2000, ""},
+		{"stored variant", "/assets/banners/hero.png", map[string]string{"Width": "700"}, 800, "1.14"},
+		{"generated", "/assets/banners/hero.png", map[string]string{"Sec-CH-Viewport-Width": "400", "Sec-CH-DPR": "2"}, 800, "2"},
+		{"generated and snapped", "/assets/banners/hero.png", map[string]string{"Viewport-Width": "900"}, 1024, "1.14"},
+		{"wider than original", "/assets/banners/hero.png", map[string]string{"Width": "3000"}, 2000, ""},
+		{"explicit width", "/assets/banners/hero.png?w=300", nil, 320, ""},
+		{"small original", "/assets/banners/small.png", map[string]string{"Width": "160"}, 160, "1"},
+		{"snap reaches original", "/assets/banners/small.png", map[string]string{"Width": "190"}, 200, ""},
+		{"over the pixel limit", "/assets/banners/huge.png?w=300", nil, 60000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.hints {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := imageinfo.Inspect(rec.Body.Bytes()).Width; got != tt.wantWidth {
+				t.Errorf("served width = %d, want %d", got, tt.wantWidth)
+			}
+			if got := rec.Header().Get("Content-DPR"); got != tt.wantDPR {
+				t.Errorf("Content-DPR = %q, want %q", got, tt.wantDPR)
+			}
+		})
+	}
+
+	// ?fm= converts, with or without resizing.
+	for path, wantWidth := range map[string]int{"/assets/banners/hero.png?w=300&fm=jpg": 320, "/assets/banners/small.png?fm=jpeg": 200} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		info := imageinfo.Inspect(rec.Body.Bytes())
+		if rec.Header().Get("Content-Type") != "image/jpeg" || info.Format != "jpeg" || info.Width != wantWidth {
+			t.Errorf("%s: Content-Type %q, served %+v", path, rec.Header().Get("Content-Type"), info)
+		}
+	}
+
+	// Hinted responses vary on the hints and advertise them; non-raster
+	// assets are left alone.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/banners/hero.png", nil))
+	if rec.Header().Get("Accept-CH") != "DPR, Width, Viewport-Width" || rec.Header().Get("Vary") == "" {
+		t.Errorf("hint headers = %v", rec.Header())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/assets/products/fish.jpg", nil)
+	req.Header.Set("Width", "100")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Vary") != "" || rec.Body.Len() != len(files["products/fish.jpg"]) {
+		t.Errorf("svg response was sized: %v", rec.Header())
+	}
+	if store.lists != 1 {
+		t.Errorf("store listed %d times, want once for the key index", store.lists)
+	}
+}
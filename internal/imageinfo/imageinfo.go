@@ -0,0 +1,125 @@
+// Package imageinfo sniffs image formats and reads pixel dimensions
+// without decoding whole images.
+package imageinfo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	_ "image/gif"  // register GIF for DecodeConfig
+	_ "image/jpeg" // register JPEG for DecodeConfig
+	_ "image/png"  // register PNG for DecodeConfig
+	"strconv"
+	"strings"
+)
+
+// MaxPixels is the size, in pixels, beyond which images are not decoded:
+// a small file can declare dimensions that take gigabytes to decode.
+const MaxPixels = 40_000_000
+
+// Info describes an image.
+type Info struct {
+	Format string // "png", "jpeg", "gif", "svg" or "" when unknown
+	Width  int
+	Height int
+}
+
+// Inspect identifies data's format and reads its dimensions. Dimensions are
+// zero when the format is unknown or does not state them.
+func Inspect(data []byte) Info {
+	if IsSVG(data) {
+		w, h := svgSize(data)
+		return Info{Format: "svg", Width: w, Height: h}
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}
+	}
+	return Info{Format: format, Width: cfg.Width, Height: cfg.Height}
+}
+
+// Sniff returns the image format of data from its leading bytes: "png",
+// "jpeg", "gif", "webp", "svg", or "" for anything else.
+func Sniff(data []byte) string {
+	switch {
+	case IsSVG(data):
+		return "svg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	}
+	return ""
+}
+
+// IsSVG reports whether data looks like an SVG document.
+func IsSVG(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	head = bytes.TrimLeft(head, "\xef\xbb\xbf \t\r\n")
+	if bytes.HasPrefix(head, []byte("<svg")) {
+		return true
+	}
+	return (bytes.HasPrefix(head, []byte("<?xml")) || bytes.HasPrefix(head, []byte("<!--")) ||
+		bytes.HasPrefix(head, []byte("<!DOCTYPE"))) && bytes.Contains(head, []byte("<svg"))
+}
+
+// svgSize reads width/height from the root element, falling back to the
+// viewBox. Relative units (%, em) are ignored.
+func svgSize(data []byte) (int, int) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if el.Name.Local != "svg" {
+			return 0, 0
+		}
+		var w, h int
+		var viewBox string
+		for _, a := range el.Attr {
+			switch a.Name.Local {
+			case "width":
+				w = length(a.Value)
+			case "height":
+				h = length(a.Value)
+			case "viewBox":
+				viewBox = a.Value
+			}
+		}
+		if (w == 0 || h == 0) && viewBox != "" {
+			f := strings.FieldsFunc(viewBox, func(r rune) bool { return r == ' ' || r == ',' })
+			if len(f) == 4 {
+				if w == 0 {
+					w = length(f[2])
+				}
+				if h == 0 {
+					h = length(f[3])
+				}
+			}
+		}
+		return w, h
+	}
+}
+
+// length parses an absolute SVG length such as "800" or "800px".
+func length(s string) int {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "px")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	return int(f + 0.5)
+}
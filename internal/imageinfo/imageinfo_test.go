@@ -0,0 +1,32 @@
+package imageinfo
+
+import (
+	"testing"
+
+	"codlocker-assets/internal/imagetest"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Info
+	}{
+		{"png", imagetest.Encode(t, "png", 64, 32), Info{"png", 64, 32}},
+		{"jpeg", imagetest.Encode(t, "jpeg", 10, 20), Info{"jpeg", 10, 20}},
+		{"gif", imagetest.Encode(t, "gif", 3, 3), Info{"gif", 3, 3}},
+		{"svg width/height", []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="800" height="600"></svg>`), Info{"svg", 800, 600}},
+		{"svg px units", []byte(`<svg width="120px" height="80.4px"/>`), Info{"svg", 120, 80}},
+		{"svg viewBox", []byte(`<?xml version="1.0"?><svg viewBox="0 0 400 300"/>`), Info{"svg", 400, 300}},
+		{"svg percent", []byte(`<svg width="100%" height="100%"/>`), Info{"svg", 0, 0}},
+		{"unknown", []byte("hello"), Info{}},
+		{"empty", nil, Info{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Inspect(tt.data); got != tt.want {
+				t.Errorf("Inspect = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package imagetest builds encoded images for tests.
+package imagetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// Encode returns a blank w×h image encoded as format: "png", "jpeg" or
+// "gif".
+func Encode(t testing.TB, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		t.Fatalf("imagetest: unknown format %q", format)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// PNG returns a blank w×h PNG.
+func PNG(t testing.TB, w, h int) []byte {
+	t.Helper()
+	return Encode(t, "png", w, h)
+}
+
+// Oversized returns a PNG whose header claims w×h pixels but which holds
+// no pixel data, like a decompression bomb: image.DecodeConfig reads its
+// size, and decoding it would allocate for every claimed pixel.
+func Oversized(w, h int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(w))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(h))
+	ihdr[8], ihdr[9] = 8, 2 // 8-bit RGB
+	chunk(&buf, "IHDR", ihdr)
+	chunk(&buf, "IEND", nil)
+	return buf.Bytes()
+}
+
+func chunk(buf *bytes.Buffer, typ string, data []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	buf.WriteString(typ)
+	buf.Write(data)
+	_ = binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of Command.
+const (
+	ExitOK     = 0
+	ExitFailed = 1
+	ExitError  = 2
+)
+
+// Command implements "codlocker-assets import [flags] ARCHIVE". It imports
+// the archive through svc, writes the report to stdout and returns the
+// process exit code: ExitFailed when entries failed validation, so
+// nothing was written.
+func Command(args []string, svc *Service, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	mapFile := fs.String("map", "", "mapping file (YAML or JSON); required")
+	dryRun := fs.Bool("dry-run", false, "validate and report without writing")
+	replace := fs.Bool("replace", false, "overwrite assets that already exist")
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	actor := fs.String("as", os.Getenv("USER"), "actor recorded on the new versions")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: codlocker-assets import -map FILE [flags] ARCHIVE")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	} else if err != nil {
+		return ExitError
+	}
+	if *mapFile == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return ExitError
+	}
+
+	mapping, err := LoadMapping(*mapFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return ExitError
+	}
+	src, done, err := svc.openFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return ExitError
+	}
+	defer done()
+	if *actor == "" {
+		*actor = "cli"
+	}
+
+	rep, err := svc.Import(context.Background(), src, Options{
+		Mapping: mapping,
+		DryRun:  *dryRun,
+		Replace: *replace,
+		Actor:   *actor,
+	})
+	if err != nil && rep.ID == "" {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return ExitError
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	} else {
+		WriteText(stdout, rep)
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return ExitError
+	case len(rep.Failed) > 0:
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// WriteText writes rep for people: failed and skipped entries with their
+// reasons, then a summary.
+func WriteText(w io.Writer, rep Report) {
+	for _, e := range rep.Failed {
+		fmt.Fprintf(w, "failed  %s -> %s: %s\n", e.Name, e.Key, e.Reason)
+	}
+	for _, e := range rep.Skipped {
+		fmt.Fprintf(w, "skipped %s: %s\n", e.Name, e.Reason)
+	}
+	if len(rep.Failed)+len(rep.Skipped) > 0 {
+		fmt.Fprintln(w)
+	}
+	outcome := "committed"
+	switch {
+	case rep.DryRun:
+		outcome = "dry run, nothing written"
+	case rep.Error != "":
+		outcome = "not published"
+	case !rep.Committed:
+		outcome = "nothing written"
+	}
+	fmt.Fprintf(w, "batch %s: %d imported, %d skipped, %d failed (%s)\n",
+		rep.ID, len(rep.Imported), len(rep.Skipped), len(rep.Failed), outcome)
+}
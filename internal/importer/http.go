@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// maxMappingBytes caps the mapping part of an import request.
+const maxMappingBytes = 1 << 20
+
+// Register mounts the import endpoint:
+//
+//	POST /api/v1/imports?dryRun=true&replace=true   import an archive
+//
+// The body is multipart/form-data with a "mapping" part (YAML or JSON)
+// and an "archive" file part whose name says whether it is a .zip, .tar
+// or .tar.gz. The response is the report: 201 when the batch went live,
+// 200 for a dry run, 422 when entries failed validation and 500 when the
+// batch could not be published.
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/imports", s.handleImport).Methods(http.MethodPost)
+}
+
+func (s *Service) handleImport(w http.ResponseWriter, r *http.Request) {
+	opts := Options{
+		DryRun:  r.URL.Query().Get("dryRun") == "true",
+		Replace: r.URL.Query().Get("replace") == "true",
+		Actor:   api.Actor(r),
+	}
+	src, done, err := s.readUpload(w, r, &opts)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	defer done()
+
+	rep, err := s.Import(r.Context(), src, opts)
+	switch {
+	case err != nil && rep.ID == "":
+		api.Error(w, api.StatusFor(err), err.Error())
+	case err != nil:
+		api.WriteJSON(w, http.StatusInternalServerError, rep)
+	case len(rep.Failed) > 0:
+		api.WriteJSON(w, http.StatusUnprocessableEntity, rep)
+	case rep.Committed:
+		api.WriteJSON(w, http.StatusCreated, rep)
+	default:
+		api.WriteJSON(w, http.StatusOK, rep)
+	}
+}
+
+// readUpload reads the multipart parts of an import request, setting
+// opts.Mapping and returning the opened archive.
+func (s *Service) readUpload(w http.ResponseWriter, r *http.Request, opts *Options) (Source, func(), error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxArchiveBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", api.ErrBadRequest, err)
+	}
+	var (
+		src  Source
+		done = func() {}
+	)
+	fail := func(err error) (Source, func(), error) {
+		done()
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			return nil, nil, fmt.Errorf("%w: limit is %d bytes", api.ErrTooLarge, tooBig.Limit)
+		}
+		return nil, nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", api.ErrBadRequest, err))
+		}
+		switch part.FormName() {
+		case "mapping":
+			data, err := io.ReadAll(io.LimitReader(part, maxMappingBytes))
+			if err != nil {
+				return fail(fmt.Errorf("%w: read mapping: %v", api.ErrBadRequest, err))
+			}
+			if opts.Mapping, err = ParseMapping(data); err != nil {
+				return fail(fmt.Errorf("%w: %v", api.ErrBadRequest, err))
+			}
+		case "archive":
+			if src != nil {
+				return fail(fmt.Errorf("%w: more than one archive", api.ErrBadRequest))
+			}
+			a, closeArchive, err := s.openUpload(part.FileName(), part)
+			if err != nil {
+				var tooBig *http.MaxBytesError
+				if !errors.As(err, &tooBig) {
+					err = fmt.Errorf("%w: %v", api.ErrBadRequest, err)
+				}
+				return fail(err)
+			}
+			src, done = a, closeArchive
+		default:
+			return fail(fmt.Errorf("%w: unexpected part %q", api.ErrBadRequest, part.FormName()))
+		}
+	}
+	if src == nil || opts.Mapping == nil {
+		return fail(fmt.Errorf("%w: mapping and archive parts are required", api.ErrBadRequest))
+	}
+	return src, done, nil
+}
@@ -0,0 +1,551 @@
+// Package importer brings archive deliveries, such as a photo studio's
+// zip, into the asset store. Entries are mapped to keys by a Mapping,
+// vetted and staged as one batch where nothing is served; if any entry
+// fails validation the batch is dropped. A complete batch is published in
+// one step, by renaming it under PublishedDir, which live storage serves
+// on top of the asset roots (see storage.BatchOverlay), so it goes live
+// whole or not at all. Its entries are then written as new versions,
+// which folds them into the asset roots; a batch whose fold was cut short
+// stays served and is folded by Recover at the next start.
+package importer
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"codlocker-assets/internal/audit"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// MaxArchiveBytes caps the size of an uploaded archive, and of a .tar.gz
+// once unpacked.
+const MaxArchiveBytes = 2 << 30
+
+// Source is an opened archive; *storage.ArchiveStorage implements it.
+type Source interface {
+	storage.Storage
+	storage.Lister
+}
+
+// WriteFunc writes one published asset to live storage. It lets the
+// caller route imports through versioning.
+type WriteFunc func(ctx context.Context, key string, data []byte, actor string) error
+
+// Checker vets entries before a batch is written, with the same contract
+// as versions.Checker.
+type Checker interface {
+	Check(key string, data []byte) error
+}
+
+// Holder reports whether uploads to a key are held for moderation;
+// moderation.Prefixes implements it.
+type Holder interface {
+	Holds(key string) bool
+}
+
+// Service imports archives one batch at a time.
+type Service struct {
+	live    storage.ReadWriter
+	staging string
+	write   WriteFunc
+
+	checkers  []Checker
+	held      Holder
+	observers []storage.Observer
+
+	mu sync.Mutex // held for the whole of a batch
+}
+
+// New returns a Service importing into live, which must be a
+// storage.BatchOverlay serving PublishedDir(staging). Each batch is
+// staged in its own directory under staging. If write is nil, published
+// entries are written straight to live and observers are told; otherwise
+// write is responsible for both.
+func New(live storage.ReadWriter, staging string, write WriteFunc) *Service {
+	s := &Service{live: live, staging: staging, write: write}
+	if s.write == nil {
+		s.write = func(ctx context.Context, key string, data []byte, actor string) error {
+			if err := live.Put(key, data); err != nil {
+				return err
+			}
+			for _, o := range s.observers {
+				o.Written(ctx, key, data, actor)
+			}
+			return nil
+		}
+	}
+	return s
+}
+
+// Observe registers o to be told of the entries written when New was
+// given no WriteFunc.
+func (s *Service) Observe(o storage.Observer) {
+	s.observers = append(s.observers, o)
+}
+
+// Require registers c to vet every entry, on top of the audit checks.
+func (s *Service) Require(c Checker) {
+	s.checkers = append(s.checkers, c)
+}
+
+// RefuseHeld fails entries mapped to keys h holds. An import cannot be
+// held for review entry by entry, and must not put such keys live
+// without it.
+func (s *Service) RefuseHeld(h Holder) {
+	s.held = h
+}
+
+// Options configures one import.
+type Options struct {
+	Mapping *Mapping
+	// DryRun validates and reports without writing anything.
+	DryRun bool
+	// Replace overwrites keys that already exist; otherwise their entries
+	// are skipped.
+	Replace bool
+	Actor   string
+}
+
+// Entry is one archive entry in a report.
+type Entry struct {
+	Name     string `json:"name"`
+	Key      string `json:"key,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Replaced bool   `json:"replaced,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Report is the outcome of an import. Imported lists the entries of the
+// batch. Committed is true once they are all live; until then none of
+// them is served.
+type Report struct {
+	ID        string  `json:"id"`
+	DryRun    bool    `json:"dryRun"`
+	Committed bool    `json:"committed"`
+	Imported  []Entry `json:"imported"`
+	Skipped   []Entry `json:"skipped"`
+	Failed    []Entry `json:"failed"`
+	// Error is set when the batch could not be staged or published, so
+	// nothing was written.
+	Error string `json:"error,omitempty"`
+}
+
+// Import maps, vets and stages every entry of src, then publishes the
+// batch. A batch with failed entries is reported but not published. The
+// returned error is set when the archive cannot be read or the batch
+// could not be staged or published, in which case nothing went live.
+func (s *Service) Import(ctx context.Context, src Source, opts Options) (Report, error) {
+	if opts.Mapping == nil {
+		return Report{}, errors.New("import: no mapping")
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Report{}, fmt.Errorf("new batch id: %w", err)
+	}
+	rep := Report{ID: id.String(), DryRun: opts.DryRun, Imported: []Entry{}, Skipped: []Entry{}, Failed: []Entry{}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b *batch
+	if !opts.DryRun {
+		b = s.batch(rep.ID)
+		defer b.discard()
+	}
+	names, err := src.List("")
+	if err != nil {
+		return rep, fmt.Errorf("list archive: %w", err)
+	}
+	seen := make(map[string]string)
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return rep, err
+		}
+		e, data, status := s.plan(src, name, opts, seen)
+		switch status {
+		case statusImport:
+			rep.Imported = append(rep.Imported, e)
+		case statusSkip:
+			rep.Skipped = append(rep.Skipped, e)
+		default:
+			rep.Failed = append(rep.Failed, e)
+		}
+		if status == statusImport && b != nil && len(rep.Failed) == 0 {
+			if err := b.stage(e, data); err != nil {
+				rep.Error = err.Error()
+				return rep, err
+			}
+		}
+	}
+	if opts.DryRun || len(rep.Failed) > 0 || len(rep.Imported) == 0 {
+		return rep, nil
+	}
+
+	if err := s.publish(b, opts.Actor); err != nil {
+		rep.Error = err.Error()
+		logger.Errorf("[importer] batch %s not published: %v", rep.ID, err)
+		return rep, err
+	}
+	rep.Committed = true
+	logger.Infof("[importer] batch %s: imported %d, skipped %d", rep.ID, len(rep.Imported), len(rep.Skipped))
+	// The batch is live; recording it as versions can no longer undo that.
+	if err := s.fold(context.WithoutCancel(ctx), b.id, opts.Actor); err != nil {
+		logger.Errorf("[importer] batch %s is live but not folded into the asset roots, retried at the next start: %v", rep.ID, err)
+	}
+	return rep, nil
+}
+
+type status int
+
+const (
+	statusImport status = iota
+	statusSkip
+	statusFail
+)
+
+// plan decides what to do with one entry, returning its content when it
+// is to be imported. seen maps the keys planned so far to the entries
+// they came from.
+func (s *Service) plan(src Source, name string, opts Options, seen map[string]string) (Entry, []byte, status) {
+	e := Entry{Name: name}
+	if systemFile(name) {
+		e.Reason = "system file"
+		return e, nil, statusSkip
+	}
+	key, ok := opts.Mapping.Map(name)
+	if !ok {
+		e.Reason = "not mapped"
+		return e, nil, statusSkip
+	}
+	e.Key = key
+	clean, err := storage.NormalizeKey(key)
+	if err != nil || storage.IsWhiteout(clean) {
+		e.Reason = fmt.Sprintf("invalid key %q", key)
+		return e, nil, statusFail
+	}
+	e.Key = clean
+	if s.held != nil && s.held.Holds(clean) {
+		e.Reason = "moderated prefix; upload it for review instead"
+		return e, nil, statusFail
+	}
+	if other, dup := seen[clean]; dup {
+		e.Reason = "key also mapped from " + other
+		return e, nil, statusFail
+	}
+	seen[clean] = name
+
+	info, err := storage.Stat(src, name)
+	if err != nil {
+		e.Reason = err.Error()
+		return e, nil, statusFail
+	}
+	e.Size = info.Size
+	if e.Size > audit.DefaultMaxBytes {
+		e.Reason = fmt.Sprintf("%d bytes exceeds the %d byte limit", e.Size, audit.DefaultMaxBytes)
+		return e, nil, statusFail
+	}
+	data, err := src.Get(name)
+	if err != nil {
+		e.Reason = err.Error()
+		return e, nil, statusFail
+	}
+	if reason := s.vet(clean, data); reason != "" {
+		e.Reason = reason
+		return e, nil, statusFail
+	}
+
+	if s.live.Exists(clean) {
+		if !opts.Replace {
+			e.Reason = "already exists"
+			return e, nil, statusSkip
+		}
+		e.Replaced = true
+	}
+	return e, data, statusImport
+}
+
+// vet runs the audit checks and the registered checkers on data as key,
+// returning why it is unacceptable or "".
+func (s *Service) vet(key string, data []byte) string {
+	var reasons []string
+	opts := audit.Options{MaxBytes: audit.DefaultMaxBytes, MaxPixels: audit.DefaultMaxPixels}
+	for _, f := range audit.Check(entry{key: key, data: data}, key, opts) {
+		reasons = append(reasons, f.Rule+": "+f.Message)
+	}
+	for _, c := range s.checkers {
+		if err := c.Check(key, data); err != nil {
+			reasons = append(reasons, err.Error())
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// entry presents one archive entry to audit.Check under its destination
+// key.
+type entry struct {
+	key  string
+	data []byte
+}
+
+func (e entry) Get(key string) ([]byte, error) {
+	if key != e.key {
+		return nil, storage.ErrNotFound
+	}
+	return e.data, nil
+}
+
+func (e entry) Exists(key string) bool { return key == e.key }
+
+// publishedName is the directory of published batches under staging.
+const publishedName = "published"
+
+// PublishedDir is where batches are published under staging. Live
+// storage serves it on top of the asset roots.
+func PublishedDir(staging string) string {
+	return filepath.Join(staging, publishedName)
+}
+
+// journal records who imported a batch, for Recover to fold it with.
+type journal struct {
+	Actor string `json:"actor"`
+}
+
+// batch is the staging directory of one import, holding the content of
+// every entry under its key. Nothing in it is served until publish moves
+// it under PublishedDir.
+type batch struct {
+	id, dir   string
+	files     storage.ReadWriter
+	published bool
+}
+
+func (s *Service) batch(id string) *batch {
+	dir := filepath.Join(s.staging, id)
+	return &batch{id: id, dir: dir, files: storage.NewLocalStorage(dir, storage.WithSymlinkPolicy(storage.SymlinksDeny))}
+}
+
+// stage keeps data for e.
+func (b *batch) stage(e Entry, data []byte) error {
+	if err := b.files.Put(e.Key, data); err != nil {
+		return fmt.Errorf("stage %s: %w", e.Name, err)
+	}
+	return nil
+}
+
+// discard removes the staging directory of a batch that was not
+// published.
+func (b *batch) discard() {
+	if b.published {
+		return
+	}
+	if err := os.RemoveAll(b.dir); err != nil {
+		logger.Warnf("[importer] batch %s: remove staging: %v", b.id, err)
+	}
+}
+
+func (s *Service) journalPath(id string) string {
+	return filepath.Join(s.staging, id+".json")
+}
+
+// publish writes the journal of b, then renames b under PublishedDir:
+// that one rename puts every entry live.
+func (s *Service) publish(b *batch, actor string) error {
+	data, err := json.Marshal(journal{Actor: actor})
+	if err != nil {
+		return err
+	}
+	tmp := s.journalPath(b.id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := os.Rename(tmp, s.journalPath(b.id)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if err := os.MkdirAll(PublishedDir(s.staging), 0o755); err != nil {
+		os.Remove(s.journalPath(b.id))
+		return fmt.Errorf("publish: %w", err)
+	}
+	if err := os.Rename(b.dir, filepath.Join(PublishedDir(s.staging), b.id)); err != nil {
+		os.Remove(s.journalPath(b.id))
+		return fmt.Errorf("publish: %w", err)
+	}
+	b.published = true
+	return nil
+}
+
+// fold writes each entry of the published batch id through write, as
+// actor. Writing a key through live drops it from the batch without any
+// visible change; once every key is written the batch is removed.
+func (s *Service) fold(ctx context.Context, id, actor string) error {
+	dir := filepath.Join(PublishedDir(s.staging), id)
+	files := storage.NewLocalStorage(dir, storage.WithSymlinkPolicy(storage.SymlinksDeny))
+	keys, err := files.List("")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		data, err := files.Get(key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue // written or deleted since it was published
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", key, err)
+		}
+		if err := s.write(ctx, key, data, actor); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("remove published batch: %w", err)
+	}
+	if err := os.Remove(s.journalPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return nil
+}
+
+// Recover folds the published batches a crash or a failed write left in
+// live storage, and clears whatever else is left in the staging
+// directory: batches that were never published, and spooled uploads.
+// Call it at start-up, before any import, while no other process imports
+// through the same staging directory.
+func (s *Service) Recover(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	published, err := os.ReadDir(PublishedDir(s.staging))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read published batches: %w", err)
+	}
+	unfolded := make(map[string]bool)
+	for _, d := range published {
+		if !d.IsDir() {
+			continue
+		}
+		id := d.Name()
+		j := journal{Actor: "import"}
+		if data, err := os.ReadFile(s.journalPath(id)); err == nil {
+			_ = json.Unmarshal(data, &j)
+		}
+		if err := s.fold(ctx, id, j.Actor); err != nil {
+			errs = append(errs, fmt.Errorf("batch %s: %w", id, err))
+			unfolded[id] = true // keep its journal for the next try
+			continue
+		}
+		logger.Warnf("[importer] batch %s: folded an interrupted import", id)
+	}
+
+	dirents, err := os.ReadDir(s.staging)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(errs...)
+	}
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("read staging: %w", err))...)
+	}
+	for _, d := range dirents {
+		name := d.Name()
+		switch {
+		case name == publishedName:
+		case d.IsDir():
+			// Never published; nothing of it was served.
+			s.batch(name).discard()
+		case strings.HasSuffix(name, ".json") && unfolded[strings.TrimSuffix(name, ".json")]:
+		case strings.HasPrefix(name, "upload-"), strings.HasSuffix(name, ".json"), strings.HasSuffix(name, ".json.tmp"):
+			_ = os.Remove(filepath.Join(s.staging, name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// openUpload spools an archive read from r into a temporary file under
+// the staging directory and opens it. name (the uploaded file name) picks
+// the format. A .tar.gz is unpacked to a plain .tar as it is spooled, so
+// its entries are read from disk rather than held in memory. The returned
+// func closes the archive and removes the file.
+func (s *Service) openUpload(name string, r io.Reader) (*storage.ArchiveStorage, func(), error) {
+	if err := os.MkdirAll(s.staging, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("create staging: %w", err)
+	}
+	ext := archiveExt(name)
+	switch ext {
+	case "":
+		return nil, nil, fmt.Errorf("unsupported archive type: %q", name)
+	case ".tar.gz", ".tgz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read gzip: %w", err)
+		}
+		defer gz.Close()
+		r, ext = gz, ".tar"
+	}
+	f, err := os.CreateTemp(s.staging, "upload-*"+ext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("spool archive: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	n, err := io.Copy(f, io.LimitReader(r, MaxArchiveBytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > MaxArchiveBytes {
+		err = fmt.Errorf("archive exceeds %d bytes", MaxArchiveBytes)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	a, err := storage.OpenArchive(f.Name())
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return a, func() { a.Close(); cleanup() }, nil
+}
+
+// openFile opens the archive at path, unpacking a .tar.gz under the
+// staging directory like an upload.
+func (s *Service) openFile(path string) (*storage.ArchiveStorage, func(), error) {
+	if ext := archiveExt(path); ext != ".tar.gz" && ext != ".tgz" {
+		a, err := storage.OpenArchive(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return a, func() { a.Close() }, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+	return s.openUpload(path, f)
+}
+
+// archiveExt is the archive suffix of name that storage.OpenArchive
+// recognises, or "". A name without an extension is taken as a zip.
+func archiveExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	if path.Ext(lower) == "" {
+		return ".zip"
+	}
+	return ""
+}
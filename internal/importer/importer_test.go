@@ -0,0 +1,439 @@
+package importer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/imagetest"
+	"codlocker-assets/internal/storage"
+)
+
+// zipBytes builds a zip holding files, in name order.
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openZip(t *testing.T, files map[string][]byte) *storage.ArchiveStorage {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "delivery.zip")
+	if err := os.WriteFile(p, zipBytes(t, files), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := storage.OpenArchive(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+const testMapping = `
+rules:
+  - match: '^Contact sheets/'
+    skip: true
+  - match: '^(?P<category>[A-Za-z]+)/(?P<sku>[A-Z]+-\d+)_(?P<view>front|back)\.PNG$'
+    key: 'products/${category}/${sku}/${view}.png'
+    lower: true
+`
+
+func mustMapping(t *testing.T) *Mapping {
+	t.Helper()
+	m, err := ParseMapping([]byte(testMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// newLive returns live storage over a fresh asset root, serving the
+// batches published under staging.
+func newLive(t *testing.T, staging string) (*storage.BatchOverlay, *storage.LocalStorage) {
+	t.Helper()
+	root := storage.NewLocalStorage(t.TempDir())
+	return storage.NewBatchOverlay(root, PublishedDir(staging)), root
+}
+
+func TestMapping(t *testing.T) {
+	m := mustMapping(t)
+	for name, want := range map[string]string{
+		"Shellfish/SQD-042_front.PNG": "products/shellfish/sqd-042/front.png",
+		"Contact sheets/SQD-042.PNG":  "",
+		"Shellfish/SQD-042_side.PNG":  "",
+	} {
+		if got, ok := m.Map(name); got != want || ok != (want != "") {
+			t.Errorf("Map(%q) = %q, %v; want %q", name, got, ok, want)
+		}
+	}
+
+	for _, bad := range []string{
+		`rules: []`,
+		`rules: [{match: "("}]`,
+		`rules: [{match: "x"}]`,
+		`rules: [{match: "x", key: "y", skip: true}]`,
+		`rules: [{match: "x", key: "y", rename: true}]`,
+	} {
+		if _, err := ParseMapping([]byte(bad)); err == nil {
+			t.Errorf("ParseMapping(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	staging := t.TempDir()
+	live, root := newLive(t, staging)
+	_ = live.Put("products/shellfish/sqd-001/front.png", imagetest.PNG(t, 2, 2))
+	svc := New(live, staging, nil)
+	src := openZip(t, map[string][]byte{
+		"Shellfish/SQD-001_front.PNG":  imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_front.PNG":  imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_back.PNG":   imagetest.PNG(t, 8, 8),
+		"Contact sheets/all.PNG":       imagetest.PNG(t, 8, 8),
+		"__MACOSX/Shellfish/._SQD-002": []byte("fork"),
+		"Shellfish/.DS_Store":          []byte("junk"),
+		"notes.txt":                    []byte("hello"),
+		"Frozen/FRZ-001_front.PNG":     imagetest.PNG(t, 8, 8),
+		"frozen/FRZ-001_front.PNG":     imagetest.PNG(t, 8, 8),
+		"Frozen/FRZ-002_front.PNG":     nil,
+		"Frozen/FRZ-003_front.PNG":     []byte("not a png"),
+		"Frozen/FRZ-004_back.PNG":      imagetest.PNG(t, 8, 8),
+	})
+
+	rep, err := svc.Import(ctx, src, Options{Mapping: mustMapping(t), Actor: "studio"})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	failed := make(map[string]string)
+	for _, e := range rep.Failed {
+		failed[e.Name] = e.Reason
+	}
+	if len(failed) != 3 || !strings.Contains(failed["frozen/FRZ-001_front.PNG"], "also mapped from") ||
+		!strings.Contains(failed["Frozen/FRZ-002_front.PNG"], "zero-byte") ||
+		!strings.Contains(failed["Frozen/FRZ-003_front.PNG"], "invalid-image") {
+		t.Errorf("failed = %v", failed)
+	}
+	if rep.Committed || live.Exists("products/shellfish/sqd-002/front.png") {
+		t.Fatalf("a batch with failures was written: %+v", rep)
+	}
+
+	// Without the bad entries the batch goes in, leaving existing keys
+	// alone unless Replace is set.
+	src = openZip(t, map[string][]byte{
+		"Shellfish/SQD-001_front.PNG": imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_front.PNG": imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_back.PNG":  imagetest.PNG(t, 8, 8),
+		"__MACOSX/._SQD-002":          []byte("fork"),
+		"notes.txt":                   []byte("hello"),
+	})
+	rep, err = svc.Import(ctx, src, Options{Mapping: mustMapping(t), DryRun: true})
+	if err != nil || rep.Committed || len(rep.Imported) != 2 || live.Exists("products/shellfish/sqd-002/front.png") {
+		t.Fatalf("dry run = %+v, %v", rep, err)
+	}
+	rep, err = svc.Import(ctx, src, Options{Mapping: mustMapping(t)})
+	if err != nil || !rep.Committed || len(rep.Imported) != 2 || len(rep.Skipped) != 3 || len(rep.Failed) != 0 {
+		t.Fatalf("Import = %+v, %v", rep, err)
+	}
+	if !root.Exists("products/shellfish/sqd-002/back.png") {
+		t.Error("imported asset not folded into the asset root")
+	}
+	if left, _ := os.ReadDir(PublishedDir(staging)); len(left) != 0 {
+		t.Errorf("published batches left behind: %v", left)
+	}
+	if old, _ := live.Get("products/shellfish/sqd-001/front.png"); !bytes.Equal(old, imagetest.PNG(t, 2, 2)) {
+		t.Error("existing asset replaced without Replace")
+	}
+
+	rep, err = svc.Import(ctx, src, Options{Mapping: mustMapping(t), Replace: true})
+	if err != nil || len(rep.Imported) != 3 || !rep.Imported[0].Replaced {
+		t.Fatalf("Import with Replace = %+v, %v", rep, err)
+	}
+	if got, _ := live.Get("products/shellfish/sqd-001/front.png"); !bytes.Equal(got, imagetest.PNG(t, 8, 8)) {
+		t.Error("existing asset not replaced")
+	}
+}
+
+func TestImportAtomic(t *testing.T) {
+	ctx := context.Background()
+	staging := t.TempDir()
+	live, root := newLive(t, staging)
+	original := imagetest.PNG(t, 2, 2)
+	_ = live.Put("products/shellfish/sqd-001/front.png", original)
+	keys := []string{
+		"products/shellfish/sqd-001/front.png",
+		"products/shellfish/sqd-002/back.png",
+		"products/shellfish/sqd-002/front.png",
+	}
+
+	// By the first write every entry is already served: the batch was
+	// published in one step.
+	var writes int
+	svc := New(live, staging, func(_ context.Context, key string, data []byte, _ string) error {
+		writes++
+		if writes == 1 {
+			for _, k := range keys {
+				if got, _ := live.Get(k); !bytes.Equal(got, imagetest.PNG(t, 8, 8)) {
+					t.Errorf("%s not live when the batch is written", k)
+				}
+			}
+		}
+		if writes == 2 {
+			return errors.New("disk full")
+		}
+		return live.Put(key, data)
+	})
+	src := openZip(t, map[string][]byte{
+		"Shellfish/SQD-001_front.PNG": imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_back.PNG":  imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_front.PNG": imagetest.PNG(t, 8, 8),
+	})
+	rep, err := svc.Import(ctx, src, Options{Mapping: mustMapping(t), Replace: true})
+	if err != nil || !rep.Committed {
+		t.Fatalf("Import = %+v, %v", rep, err)
+	}
+
+	// A failed write leaves the batch published: still served whole, and
+	// folded by Recover.
+	for _, k := range keys {
+		if got, _ := live.Get(k); !bytes.Equal(got, imagetest.PNG(t, 8, 8)) {
+			t.Errorf("%s not served after a failed fold", k)
+		}
+	}
+	if root.Exists("products/shellfish/sqd-002/front.png") {
+		t.Fatal("entry after the failed write reached the asset root")
+	}
+	if err := svc.Recover(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if got, _ := root.Get(k); !bytes.Equal(got, imagetest.PNG(t, 8, 8)) {
+			t.Errorf("%s not folded by Recover", k)
+		}
+	}
+	if left, _ := os.ReadDir(staging); len(left) != 1 {
+		t.Errorf("staging after Recover holds %v, want the empty published directory", left)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	src := openZip(t, map[string][]byte{
+		"Shellfish/SQD-001_front.PNG": imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-002_back.PNG":  imagetest.PNG(t, 8, 8),
+	})
+
+	// Nothing goes live unless the whole batch was staged.
+	blocked := filepath.Join(t.TempDir(), "file")
+	_ = os.WriteFile(blocked, nil, 0o644)
+	live, _ := newLive(t, blocked)
+	rep, err := New(live, blocked, nil).Import(ctx, src, Options{Mapping: mustMapping(t)})
+	if err == nil || rep.Committed || live.Exists("products/shellfish/sqd-001/front.png") {
+		t.Fatalf("Import with unusable staging = %+v, %v", rep, err)
+	}
+
+	// What a crash leaves: a batch published but not folded, one staged
+	// but not published, and a spooled upload.
+	staging := t.TempDir()
+	live, root := newLive(t, staging)
+	published := filepath.Join(PublishedDir(staging), "0001")
+	_ = os.MkdirAll(filepath.Join(published, "products"), 0o755)
+	_ = os.WriteFile(filepath.Join(published, "products", "a.png"), imagetest.PNG(t, 8, 8), 0o644)
+	_ = os.WriteFile(filepath.Join(staging, "0001.json"), []byte(`{"actor":"studio"}`), 0o644)
+	_ = os.MkdirAll(filepath.Join(staging, "0002", "products"), 0o755)
+	_ = os.WriteFile(filepath.Join(staging, "0002", "products", "b.png"), imagetest.PNG(t, 8, 8), 0o644)
+	_ = os.WriteFile(filepath.Join(staging, "0002.json"), []byte(`{"actor":"studio"}`), 0o644)
+	_ = os.WriteFile(filepath.Join(staging, "upload-123.zip"), []byte("spooled"), 0o644)
+	if !live.Exists("products/a.png") || live.Exists("products/b.png") {
+		t.Fatal("only the published batch should be served")
+	}
+
+	var actors []string
+	svc := New(live, staging, func(_ context.Context, key string, data []byte, actor string) error {
+		actors = append(actors, actor)
+		return live.Put(key, data)
+	})
+	if err := svc.Recover(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !root.Exists("products/a.png") || !slices.Equal(actors, []string{"studio"}) {
+		t.Errorf("published batch not folded; written as %v", actors)
+	}
+	if live.Exists("products/b.png") {
+		t.Error("unpublished batch went live")
+	}
+	if left, _ := os.ReadDir(staging); len(left) != 1 || left[0].Name() != "published" {
+		t.Errorf("staging left behind: %v", left)
+	}
+	if left, _ := os.ReadDir(PublishedDir(staging)); len(left) != 0 {
+		t.Errorf("published batches left behind: %v", left)
+	}
+}
+
+func TestOpenUploadTarGz(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	data := imagetest.PNG(t, 4, 4)
+	_ = tw.WriteHeader(&tar.Header{Name: "Shellfish/SQD-001_front.PNG", Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write(data)
+	_ = tw.Close()
+	_ = gz.Close()
+
+	// The upload is unpacked to a plain tar on disk, read in place.
+	staging := t.TempDir()
+	live, _ := newLive(t, staging)
+	a, done, err := New(live, staging, nil).openUpload("delivery.tgz", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(a.Path(), ".tar") {
+		t.Errorf("spooled as %s, want a .tar", a.Path())
+	}
+	if got, err := a.Get("Shellfish/SQD-001_front.PNG"); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("entry = %d bytes, %v", len(got), err)
+	}
+	done()
+	if left, _ := os.ReadDir(staging); len(left) != 0 {
+		t.Errorf("spooled upload left behind: %v", left)
+	}
+}
+
+// held holds the keys under a prefix.
+type held string
+
+func (h held) Holds(key string) bool { return strings.HasPrefix(key, string(h)) }
+
+func TestImportModerated(t *testing.T) {
+	staging := t.TempDir()
+	live, _ := newLive(t, staging)
+	svc := New(live, staging, nil)
+	svc.RefuseHeld(held("products/shellfish/"))
+	src := openZip(t, map[string][]byte{
+		"Frozen/FRZ-001_front.PNG":    imagetest.PNG(t, 8, 8),
+		"Shellfish/SQD-001_front.PNG": imagetest.PNG(t, 8, 8),
+	})
+	rep, err := svc.Import(context.Background(), src, Options{Mapping: mustMapping(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Failed) != 1 || rep.Failed[0].Key != "products/shellfish/sqd-001/front.png" || !strings.Contains(rep.Failed[0].Reason, "moderated") {
+		t.Errorf("failed = %+v", rep.Failed)
+	}
+	if rep.Committed || live.Exists("products/frozen/frz-001/front.png") || live.Exists("products/shellfish/sqd-001/front.png") {
+		t.Errorf("a batch into a moderated prefix was written: %+v", rep)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	staging := t.TempDir()
+	live, _ := newLive(t, staging)
+	svc := New(live, staging, nil)
+	r := mux.NewRouter()
+	svc.Register(r)
+
+	post := func(query, mapping string, archive []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if mapping != "" {
+			_ = mw.WriteField("mapping", mapping)
+		}
+		if archive != nil {
+			fw, _ := mw.CreateFormFile("archive", "delivery.zip")
+			_, _ = io.Copy(fw, bytes.NewReader(archive))
+		}
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/imports"+query, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	archive := zipBytes(t, map[string][]byte{"Shellfish/SQD-001_front.PNG": imagetest.PNG(t, 4, 4)})
+	if rec := post("?dryRun=true", testMapping, archive); rec.Code != http.StatusOK || live.Exists("products/shellfish/sqd-001/front.png") {
+		t.Errorf("dry run status = %d: %s", rec.Code, rec.Body)
+	}
+	rec := post("", testMapping, archive)
+	var rep Report
+	if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil || rec.Code != http.StatusCreated || !rep.Committed {
+		t.Fatalf("import = %d %+v, %v", rec.Code, rep, err)
+	}
+	if !live.Exists("products/shellfish/sqd-001/front.png") {
+		t.Error("imported asset missing")
+	}
+
+	bad := zipBytes(t, map[string][]byte{"Shellfish/SQD-002_front.PNG": []byte("nope")})
+	if rec := post("", testMapping, bad); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid entry status = %d, want 422", rec.Code)
+	}
+	if rec := post("", "", archive); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing mapping status = %d, want 400", rec.Code)
+	}
+	if rec := post("", "rules: [{match: '('}]", archive); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad mapping status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := filepath.Join(dir, "mapping.yaml")
+	_ = os.WriteFile(mapFile, []byte(testMapping), 0o644)
+	good := filepath.Join(dir, "good.zip")
+	_ = os.WriteFile(good, zipBytes(t, map[string][]byte{"Shellfish/SQD-001_front.PNG": imagetest.PNG(t, 4, 4)}), 0o644)
+	bad := filepath.Join(dir, "bad.zip")
+	_ = os.WriteFile(bad, zipBytes(t, map[string][]byte{"Shellfish/SQD-001_front.PNG": nil}), 0o644)
+
+	staging := t.TempDir()
+	live, _ := newLive(t, staging)
+	svc := New(live, staging, nil)
+	var out, errOut bytes.Buffer
+
+	if code := Command([]string{"-map", mapFile, "-dry-run", good}, svc, &out, &errOut); code != ExitOK {
+		t.Errorf("dry run exit = %d: %s", code, errOut.String())
+	}
+	if !strings.Contains(out.String(), "1 imported, 0 skipped, 0 failed (dry run, nothing written)") {
+		t.Errorf("text output = %q", out.String())
+	}
+	if code := Command([]string{"-map", mapFile, bad}, svc, &out, &errOut); code != ExitFailed {
+		t.Errorf("failed entries exit = %d, want %d", code, ExitFailed)
+	}
+	if code := Command([]string{good}, svc, &out, &errOut); code != ExitError {
+		t.Errorf("missing -map exit = %d, want %d", code, ExitError)
+	}
+	if code := Command([]string{"-map", mapFile, "-json", good}, svc, &out, &errOut); code != ExitOK || !live.Exists("products/shellfish/sqd-001/front.png") {
+		t.Errorf("import exit = %d: %s", code, errOut.String())
+	}
+}
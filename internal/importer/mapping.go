@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping turns archive entry names into asset keys. Rules are tried in
+// order and the first whose pattern matches an entry decides it; entries
+// that no rule matches are skipped.
+type Mapping struct {
+	Rules []MapRule `yaml:"rules"`
+}
+
+// MapRule maps the entries matching Match, a regular expression over the
+// entry's path inside the archive, to Key, a template that refers to
+// submatches as ${1} or ${name}. Skip drops matching entries instead.
+type MapRule struct {
+	Match string `yaml:"match"`
+	Key   string `yaml:"key"`
+	// Lower lowercases the resulting key, for deliveries named like
+	// "Shellfish/IMG_0042.JPG".
+	Lower bool `yaml:"lower"`
+	Skip  bool `yaml:"skip"`
+
+	re *regexp.Regexp
+}
+
+// LoadMapping reads a mapping file. JSON is accepted as it is valid YAML.
+func LoadMapping(file string) (*Mapping, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return m, nil
+}
+
+// ParseMapping parses and validates a mapping. Unknown fields are errors.
+func ParseMapping(data []byte) (*Mapping, error) {
+	var m Mapping
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("parse mapping: %w", err)
+	}
+	if len(m.Rules) == 0 {
+		return nil, fmt.Errorf("mapping has no rules")
+	}
+	for i := range m.Rules {
+		r := &m.Rules[i]
+		if r.Match == "" {
+			return nil, fmt.Errorf("rule %d: match is required", i+1)
+		}
+		if (r.Key == "") == !r.Skip {
+			return nil, fmt.Errorf("rule %d: exactly one of key and skip is required", i+1)
+		}
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: match: %w", i+1, err)
+		}
+		r.re = re
+	}
+	return &m, nil
+}
+
+// Map returns the key for the entry called name. ok is false when the
+// entry is skipped, by a skip rule or because no rule matches.
+func (m *Mapping) Map(name string) (key string, ok bool) {
+	for _, r := range m.Rules {
+		match := r.re.FindStringSubmatchIndex(name)
+		if match == nil {
+			continue
+		}
+		if r.Skip {
+			return "", false
+		}
+		key = string(r.re.ExpandString(nil, r.Key, name, match))
+		if r.Lower {
+			key = strings.ToLower(key)
+		}
+		return key, true
+	}
+	return "", false
+}
+
+// systemFile reports whether name is operating-system clutter that zip
+// tools add to deliveries: __MACOSX resource forks, .DS_Store, Thumbs.db
+// and other dot files.
+func systemFile(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if seg == "__MACOSX" || strings.HasPrefix(seg, ".") {
+			return true
+		}
+	}
+	return strings.EqualFold(path.Base(name), "Thumbs.db")
+}
@@ -0,0 +1,42 @@
+package lifecycle
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the lifecycle endpoints:
+//
+//	GET  /api/v1/lifecycle/policy               rules in force
+//	POST /api/v1/lifecycle/run?dryRun=true      evaluate now, applying unless dryRun
+//	GET  /api/v1/lifecycle/report               latest report
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/lifecycle/policy", s.handlePolicy).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/lifecycle/run", s.handleRun).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/lifecycle/report", s.handleReport).Methods(http.MethodGet)
+}
+
+func (s *Service) handlePolicy(w http.ResponseWriter, _ *http.Request) {
+	api.WriteJSON(w, http.StatusOK, s.policy)
+}
+
+func (s *Service) handleRun(w http.ResponseWriter, r *http.Request) {
+	rep, err := s.Apply(r.Context(), r.URL.Query().Get("dryRun") == "true", api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rep)
+}
+
+func (s *Service) handleReport(w http.ResponseWriter, _ *http.Request) {
+	rep, ok := s.Last()
+	if !ok {
+		api.Error(w, http.StatusNotFound, "no lifecycle run yet")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,374 @@
+// Package lifecycle applies declarative per-prefix rules to stored
+// assets: expiring assets nobody has used for a while, moving them to a
+// cheaper cold tier, and trimming old versions. A worker evaluates the
+// rules against catalog metadata and read statistics on a schedule; a
+// dry run reports what it would do.
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/orphans"
+	"codlocker-assets/internal/storage"
+)
+
+// Actions in a report.
+const (
+	ActionExpire     = "expire"
+	ActionTransition = "transition"
+	ActionPrune      = "prune-version"
+)
+
+// Source is the merged view of live storage, hot and cold tiers alike.
+type Source interface {
+	storage.Storage
+	storage.Lister
+}
+
+// Stats reports when assets were last used. *db.AccessRepo implements it.
+type Stats interface {
+	LastUsed(ctx context.Context, prefix string) (map[string]time.Time, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Versions lists and deletes asset versions. *versions.Service
+// implements it.
+type Versions interface {
+	Versions(ctx context.Context, key string) ([]db.AssetVersion, error)
+	DeleteVersion(ctx context.Context, key, id string) error
+}
+
+// DeleteFunc removes an expired asset from live storage. It lets the
+// caller route expiry through the trash.
+type DeleteFunc func(ctx context.Context, key, actor string) error
+
+// Action is one planned or applied step.
+type Action struct {
+	Rule     string    `json:"rule"`
+	Action   string    `json:"action"`
+	Key      string    `json:"key"`
+	Version  string    `json:"version,omitempty"`
+	LastUsed time.Time `json:"lastUsed,omitzero"`
+	// OrphanReport is when the orphan report that allowed an orphansOnly
+	// expiry was generated.
+	OrphanReport time.Time `json:"orphanReport,omitzero"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Report is the result of a run. On a dry run nothing is changed and the
+// counts are of planned actions.
+type Report struct {
+	GeneratedAt  time.Time `json:"generatedAt"`
+	DryRun       bool      `json:"dryRun"`
+	Scanned      int       `json:"scanned"`
+	Expired      int       `json:"expired"`
+	Transitioned int       `json:"transitioned"`
+	Pruned       int       `json:"pruned"`
+	Failed       int       `json:"failed"`
+	Actions      []Action  `json:"actions"`
+}
+
+// Service evaluates a Policy against live storage.
+type Service struct {
+	policy *Policy
+	live   Source
+	stats  Stats
+	remove DeleteFunc
+
+	hot, cold storage.ReadWriter
+	versions  Versions
+	orphans   func() (orphans.Report, bool)
+	orphanAge time.Duration
+	now       func() time.Time
+
+	run  sync.Mutex // one run at a time
+	mu   sync.Mutex
+	last *Report
+}
+
+// New returns a Service applying policy to live. remove expires assets.
+func New(policy *Policy, live Source, stats Stats, remove DeleteFunc) *Service {
+	return &Service{policy: policy, live: live, stats: stats, remove: remove, now: time.Now}
+}
+
+// UseColdTier enables transitions: assets move from hot, the layer of
+// live storage that takes writes, to cold, a layer beneath it, and keep
+// being served from there.
+func (s *Service) UseColdTier(hot, cold storage.ReadWriter) {
+	s.hot, s.cold = hot, cold
+}
+
+// UseVersions enables keepVersions.
+func (s *Service) UseVersions(v Versions) {
+	s.versions = v
+}
+
+// UseOrphans supplies the latest orphan report for orphansOnly rules; ok
+// is false while there is none. A rule only trusts a report run on the
+// catalog database, over a prefix covering the rule's, and no older than
+// maxAge (0 for no limit): an uploaded export may be partial, and a report
+// over another prefix or an old one lists keys that are referenced now.
+func (s *Service) UseOrphans(latest func() (orphans.Report, bool), maxAge time.Duration) {
+	s.orphans, s.orphanAge = latest, maxAge
+}
+
+// orphanKeys returns the orphans of rep usable for rule r, or nil.
+func (s *Service) orphanKeys(r *Rule, rep orphans.Report, now time.Time) map[string]bool {
+	switch {
+	case rep.Source != orphans.SourceDB:
+		logger.Warnf("[lifecycle] rule %s: latest orphan report is from an export, not the catalog database; nothing expires", r.ID)
+		return nil
+	case !strings.HasPrefix(r.Prefix, rep.Prefix):
+		logger.Warnf("[lifecycle] rule %s: latest orphan report covers %s, not %s; nothing expires", r.ID, rep.Prefix, r.Prefix)
+		return nil
+	case s.orphanAge > 0 && now.Sub(rep.GeneratedAt) > s.orphanAge:
+		logger.Warnf("[lifecycle] rule %s: latest orphan report is from %s, older than %s; nothing expires",
+			r.ID, rep.GeneratedAt.Format(time.RFC3339), s.orphanAge)
+		return nil
+	}
+	keys := make(map[string]bool, len(rep.Orphans))
+	for _, k := range rep.Orphans {
+		keys[k] = true
+	}
+	return keys
+}
+
+// Written drops the cold copy of a key written again, which the new hot
+// object shadows.
+func (s *Service) Written(_ context.Context, key string, _ []byte, _ string) {
+	s.dropCold(key)
+}
+
+// Removed drops the cold copy and the read statistics of a deleted key.
+func (s *Service) Removed(ctx context.Context, key string) {
+	s.dropCold(key)
+	if err := s.stats.Delete(ctx, key); err != nil {
+		logger.Warnf("[lifecycle] forget access stats of %s: %v", key, err)
+	}
+}
+
+func (s *Service) dropCold(key string) {
+	if s.cold == nil || !s.cold.Exists(key) {
+		return
+	}
+	if err := s.cold.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logger.Warnf("[lifecycle] drop cold copy of %s: %v", key, err)
+	}
+}
+
+// Plan evaluates the policy without changing anything.
+func (s *Service) Plan(ctx context.Context) (Report, error) {
+	return s.Apply(ctx, true, "")
+}
+
+// Apply evaluates the policy and, unless dryRun, carries out the actions
+// as actor. Failed actions are recorded in the report and do not stop the
+// run; the report is kept for Last.
+func (s *Service) Apply(ctx context.Context, dryRun bool, actor string) (Report, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+
+	rep, err := s.plan(ctx)
+	if err != nil {
+		return rep, err
+	}
+	rep.DryRun = dryRun
+	for i := range rep.Actions {
+		a := &rep.Actions[i]
+		if !dryRun {
+			if err := ctx.Err(); err != nil {
+				return rep, err
+			}
+			if err := s.apply(ctx, *a, actor); err != nil {
+				a.Error = err.Error()
+				rep.Failed++
+				continue
+			}
+		}
+		switch a.Action {
+		case ActionExpire:
+			rep.Expired++
+		case ActionTransition:
+			rep.Transitioned++
+		case ActionPrune:
+			rep.Pruned++
+		}
+	}
+	s.mu.Lock()
+	s.last = &rep
+	s.mu.Unlock()
+	return rep, nil
+}
+
+// Last returns the most recent report.
+func (s *Service) Last() (Report, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		return Report{}, false
+	}
+	return *s.last, true
+}
+
+// Policy returns the rules in force.
+func (s *Service) Policy() *Policy {
+	return s.policy
+}
+
+func (s *Service) plan(ctx context.Context) (Report, error) {
+	now := s.now().UTC()
+	rep := Report{GeneratedAt: now, Actions: []Action{}}
+
+	var orphanRep orphans.Report
+	haveOrphans := false
+	if s.orphans != nil {
+		orphanRep, haveOrphans = s.orphans()
+	}
+
+	for i := range s.policy.Rules {
+		r := &s.policy.Rules[i]
+		keys, err := s.live.List(r.Prefix)
+		if err != nil {
+			return rep, fmt.Errorf("rule %s: list %s: %w", r.ID, r.Prefix, err)
+		}
+		var orphanKeys map[string]bool
+		if r.OrphansOnly && r.ExpireAfterDays > 0 && haveOrphans {
+			orphanKeys = s.orphanKeys(r, orphanRep, now)
+		}
+		var used map[string]time.Time
+		if r.ExpireAfterDays > 0 || r.ColdAfterDays > 0 {
+			if used, err = s.stats.LastUsed(ctx, r.Prefix); err != nil {
+				return rep, fmt.Errorf("rule %s: %w", r.ID, err)
+			}
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return rep, err
+			}
+			if s.policy.For(key) != r {
+				continue // an earlier rule governs it
+			}
+			rep.Scanned++
+			lastUsed, known := used[key]
+			age := now.Sub(lastUsed)
+			act := func(action string) Action {
+				return Action{Rule: r.ID, Action: action, Key: key, LastUsed: lastUsed}
+			}
+
+			// Assets without a catalog row have no known age and are
+			// left alone until the catalog backfill records them.
+			if known && r.ExpireAfterDays > 0 && age >= days(r.ExpireAfterDays) && (!r.OrphansOnly || orphanKeys[key]) {
+				a := act(ActionExpire)
+				if r.OrphansOnly {
+					a.OrphanReport = orphanRep.GeneratedAt
+				}
+				rep.Actions = append(rep.Actions, a)
+				continue
+			}
+			if known && r.ColdAfterDays > 0 && age >= days(r.ColdAfterDays) && s.cold != nil && s.hot.Exists(key) {
+				rep.Actions = append(rep.Actions, act(ActionTransition))
+			}
+			if r.KeepVersions > 0 && s.versions != nil {
+				list, err := s.versions.Versions(ctx, key)
+				if err != nil {
+					return rep, fmt.Errorf("rule %s: versions of %s: %w", r.ID, key, err)
+				}
+				for _, v := range excessVersions(list, r.KeepVersions) {
+					a := act(ActionPrune)
+					a.Version = v.ID
+					a.LastUsed = time.Time{}
+					rep.Actions = append(rep.Actions, a)
+				}
+			}
+		}
+	}
+	return rep, nil
+}
+
+// excessVersions returns the versions beyond the newest keep, newest
+// first in list; the current version is always kept.
+func excessVersions(list []db.AssetVersion, keep int) []db.AssetVersion {
+	var out []db.AssetVersion
+	kept := 0
+	for _, v := range list {
+		if v.Current || kept < keep-1 {
+			if !v.Current {
+				kept++
+			}
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func (s *Service) apply(ctx context.Context, a Action, actor string) error {
+	switch a.Action {
+	case ActionExpire:
+		return s.remove(ctx, a.Key, actor)
+	case ActionTransition:
+		return s.transition(a.Key)
+	case ActionPrune:
+		return s.versions.DeleteVersion(ctx, a.Key, a.Version)
+	}
+	return fmt.Errorf("unknown action %q", a.Action)
+}
+
+// transition copies key to the cold tier and removes it from the hot
+// one. If the hot object changes meanwhile, the cold copy is dropped and
+// the object stays hot.
+func (s *Service) transition(key string) error {
+	data, err := s.hot.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := s.cold.Put(key, data); err != nil {
+		return fmt.Errorf("write cold copy: %w", err)
+	}
+	if cur, err := s.hot.Get(key); err != nil || !bytes.Equal(cur, data) {
+		_ = s.cold.Delete(key)
+		return fmt.Errorf("%s changed during transition", key)
+	}
+	if err := s.hot.Delete(key); err != nil {
+		_ = s.cold.Delete(key)
+		return fmt.Errorf("remove hot object: %w", err)
+	}
+	return nil
+}
+
+// Run applies the policy every interval until ctx is cancelled, only
+// reporting what it would do when dryRun is set.
+func (s *Service) Run(ctx context.Context, interval time.Duration, dryRun bool) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			rep, err := s.Apply(ctx, dryRun, "lifecycle")
+			switch {
+			case err != nil:
+				logger.Errorf("[lifecycle] run failed: %v", err)
+			case dryRun:
+				logger.Infof("[lifecycle] dry run: would expire %d, transition %d, prune %d versions of %d assets",
+					rep.Expired, rep.Transitioned, rep.Pruned, rep.Scanned)
+			case len(rep.Actions) > 0:
+				logger.Infof("[lifecycle] expired %d, transitioned %d, pruned %d versions; %d failed",
+					rep.Expired, rep.Transitioned, rep.Pruned, rep.Failed)
+			}
+		}
+	}
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
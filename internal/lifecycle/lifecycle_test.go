@@ -0,0 +1,323 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/orphans"
+	"codlocker-assets/internal/storage"
+)
+
+var now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type fakeStats struct {
+	used    map[string]time.Time
+	deleted []string
+}
+
+func (f *fakeStats) LastUsed(_ context.Context, prefix string) (map[string]time.Time, error) {
+	out := make(map[string]time.Time)
+	for k, t := range f.used {
+		if strings.HasPrefix(k, prefix) {
+			out[k] = t
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStats) Delete(_ context.Context, key string) error {
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+type fakeVersions map[string][]db.AssetVersion
+
+func (f fakeVersions) Versions(_ context.Context, key string) ([]db.AssetVersion, error) {
+	return f[key], nil
+}
+
+func (f fakeVersions) DeleteVersion(_ context.Context, key, id string) error {
+	for i, v := range f[key] {
+		if v.ID == id {
+			f[key] = slices.Delete(f[key], i, i+1)
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+const testPolicy = `
+rules:
+  - id: review-images
+    prefix: reviews/
+    expireAfterDays: 365
+    coldAfterDays: 30
+  - id: campaigns
+    prefix: banners/campaigns/
+    expireAfterDays: 90
+    orphansOnly: true
+  - id: banners
+    prefix: banners/
+    keepVersions: 2
+`
+
+type fixture struct {
+	svc         *Service
+	hot, cold   *storage.LocalStorage
+	live        *storage.OverlayStorage
+	stats       *fakeStats
+	versions    fakeVersions
+	orphans     orphans.Report
+	haveOrphans bool
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	policy, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fixture{
+		hot:  storage.NewLocalStorage(t.TempDir()),
+		cold: storage.NewLocalStorage(t.TempDir()),
+		stats: &fakeStats{used: map[string]time.Time{
+			"reviews/old.jpg":              now.AddDate(-2, 0, 0),
+			"reviews/stale.jpg":            now.AddDate(0, 0, -45),
+			"reviews/fresh.jpg":            now.AddDate(0, 0, -1),
+			"banners/campaigns/spring.png": now.AddDate(0, -6, 0),
+			"banners/campaigns/summer.png": now.AddDate(0, -6, 0),
+		}},
+		versions: fakeVersions{"banners/logo.svg": {
+			{Key: "banners/logo.svg", ID: "v3", Current: true},
+			{Key: "banners/logo.svg", ID: "v2"},
+			{Key: "banners/logo.svg", ID: "v1"},
+		}},
+	}
+	for _, key := range []string{
+		"reviews/old.jpg", "reviews/stale.jpg", "reviews/fresh.jpg", "reviews/unknown.jpg",
+		"banners/campaigns/spring.png", "banners/campaigns/summer.png", "banners/logo.svg",
+	} {
+		if err := f.hot.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.live = storage.NewOverlayStorage(f.hot, f.cold)
+	f.svc = New(policy, f.live, f.stats, func(ctx context.Context, key, _ string) error {
+		if err := f.live.Delete(key); err != nil {
+			return err
+		}
+		f.svc.Removed(ctx, key)
+		return nil
+	})
+	f.svc.now = func() time.Time { return now }
+	f.svc.UseColdTier(f.hot, f.cold)
+	f.svc.UseVersions(f.versions)
+	f.svc.UseOrphans(func() (orphans.Report, bool) { return f.orphans, f.haveOrphans }, 24*time.Hour)
+	return f
+}
+
+func actions(rep Report) []string {
+	var out []string
+	for _, a := range rep.Actions {
+		s := a.Action + " " + a.Key
+		if a.Version != "" {
+			s += "@" + a.Version
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := p.For("banners/campaigns/spring.png"); r == nil || r.ID != "campaigns" {
+		t.Errorf("For(campaign) = %+v", r)
+	}
+	if r := p.For("ui/logo.svg"); r != nil {
+		t.Errorf("For(ui) = %+v, want nil", r)
+	}
+	if !p.UsesCold() {
+		t.Error("UsesCold = false")
+	}
+
+	for _, bad := range []string{
+		"rules:\n  - id: a\n    expireAfterDays: 1\n",
+		"rules:\n  - id: a\n    prefix: a/\n",
+		"rules:\n  - id: a\n    prefix: a/\n    expireAfterDays: 10\n    coldAfterDays: 10\n",
+		"rules:\n  - id: a\n    prefix: a/\n    keepVersions: 2\n    orphansOnly: true\n",
+		"rules:\n  - id: a\n    prefix: a/\n    expireAfterDay: 10\n",
+		"rules:\n  - id: a\n    prefix: a/\n    keepVersions: 1\n  - id: a\n    prefix: b/\n    keepVersions: 1\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	rep, err := f.svc.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"expire reviews/old.jpg",
+		"transition reviews/stale.jpg",
+		"prune-version banners/logo.svg@v1",
+	}
+	if got := actions(rep); !slices.Equal(got, want) {
+		t.Errorf("dry run actions = %q, want %q", got, want)
+	}
+	if !rep.DryRun || rep.Scanned != 7 || !f.hot.Exists("reviews/old.jpg") || len(f.versions["banners/logo.svg"]) != 3 {
+		t.Errorf("dry run changed something or miscounted: %+v", rep)
+	}
+
+	// Only a recent report on the catalog database over the rule's prefix
+	// allows orphansOnly expiry.
+	good := orphans.Report{
+		GeneratedAt: now.Add(-time.Hour),
+		Source:      orphans.SourceDB,
+		Prefix:      "banners/",
+		Orphans:     []string{"banners/campaigns/summer.png"},
+	}
+	f.haveOrphans = true
+	for name, edit := range map[string]func(*orphans.Report){
+		"export":       func(r *orphans.Report) { r.Source = orphans.SourceExport },
+		"other prefix": func(r *orphans.Report) { r.Prefix = "banners/campaigns/summer" },
+		"stale":        func(r *orphans.Report) { r.GeneratedAt = now.Add(-25 * time.Hour) },
+	} {
+		f.orphans = good
+		edit(&f.orphans)
+		rep, err := f.svc.Plan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := actions(rep); slices.Contains(got, "expire banners/campaigns/summer.png") {
+			t.Errorf("%s report: actions = %q", name, got)
+		}
+	}
+
+	f.orphans = good
+	rep, err = f.svc.Apply(ctx, false, "lifecycle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Expired != 2 || rep.Transitioned != 1 || rep.Pruned != 1 || rep.Failed != 0 {
+		t.Errorf("report = %+v", rep)
+	}
+	for _, a := range rep.Actions {
+		if want := a.Key == "banners/campaigns/summer.png"; a.OrphanReport.Equal(good.GeneratedAt) != want {
+			t.Errorf("%s %s: orphan report at %v", a.Action, a.Key, a.OrphanReport)
+		}
+	}
+	if f.live.Exists("reviews/old.jpg") || f.live.Exists("banners/campaigns/summer.png") || !f.live.Exists("banners/campaigns/spring.png") {
+		t.Error("expiry removed the wrong assets")
+	}
+	if f.hot.Exists("reviews/stale.jpg") || !f.cold.Exists("reviews/stale.jpg") {
+		t.Error("reviews/stale.jpg was not moved to the cold tier")
+	}
+	if data, err := f.live.Get("reviews/stale.jpg"); err != nil || string(data) != "reviews/stale.jpg" {
+		t.Errorf("cold asset not served: %q, %v", data, err)
+	}
+	if ids := len(f.versions["banners/logo.svg"]); ids != 2 {
+		t.Errorf("%d versions left, want 2", ids)
+	}
+	if !slices.Equal(f.stats.deleted, []string{"reviews/old.jpg", "banners/campaigns/summer.png"}) {
+		t.Errorf("stats forgotten for %q", f.stats.deleted)
+	}
+	if last, ok := f.svc.Last(); !ok || last.DryRun {
+		t.Errorf("Last = %+v, %v", last, ok)
+	}
+
+	// A new upload shadows the cold copy, which is dropped.
+	if err := f.live.Put("reviews/stale.jpg", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	f.svc.Written(ctx, "reviews/stale.jpg", []byte("new"), "alice")
+	if f.cold.Exists("reviews/stale.jpg") {
+		t.Error("cold copy survived a new upload")
+	}
+}
+
+type fakeRecorder struct {
+	batches [][]db.AssetAccess
+	fail    bool
+}
+
+func (f *fakeRecorder) Record(_ context.Context, hits []db.AssetAccess) error {
+	if f.fail {
+		return errors.New("database down")
+	}
+	f.batches = append(f.batches, hits)
+	return nil
+}
+
+func TestTracker(t *testing.T) {
+	rec := &fakeRecorder{fail: true}
+	tr := NewTracker(rec)
+	tr.now = func() time.Time { return now }
+	tr.Touch("reviews/a.jpg")
+	tr.Touch("reviews/a.jpg")
+	if err := tr.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded with a failing recorder")
+	}
+
+	tr.Touch("reviews/a.jpg")
+	rec.fail = false
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.batches) != 1 || len(rec.batches[0]) != 1 {
+		t.Fatalf("batches = %+v", rec.batches)
+	}
+	if h := rec.batches[0][0]; h.Key != "reviews/a.jpg" || h.Count != 3 || !h.At.Equal(now) {
+		t.Errorf("hit = %+v, want 3 reads kept across the failed flush", h)
+	}
+	if err := tr.Flush(context.Background()); err != nil || len(rec.batches) != 1 {
+		t.Errorf("empty flush recorded a batch: %v", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	f := newFixture(t)
+	r := mux.NewRouter()
+	f.svc.Register(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/lifecycle/report", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("report before a run = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/lifecycle/run?dryRun=true", nil))
+	var rep Report
+	if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("run = %d, %v", rec.Code, err)
+	}
+	if !rep.DryRun || rep.Expired != 1 || !f.hot.Exists("reviews/old.jpg") {
+		t.Errorf("dry run report = %+v", rep)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/lifecycle/policy", nil))
+	var p Policy
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil || len(p.Rules) != 3 || p.Rules[0].ColdAfterDays != 30 {
+		t.Errorf("policy = %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,99 @@
+package lifecycle
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is an ordered list of rules. A key is governed by the first rule
+// whose prefix it starts with.
+type Policy struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// Rule sets the lifecycle of the assets under Prefix. Ages count days
+// since an asset was last read or written; zero fields are not applied.
+type Rule struct {
+	ID     string `yaml:"id" json:"id"`
+	Prefix string `yaml:"prefix" json:"prefix"`
+	// ExpireAfterDays moves assets to the trash once unused this long.
+	ExpireAfterDays int `yaml:"expireAfterDays" json:"expireAfterDays,omitempty"`
+	// ColdAfterDays moves assets to the cold tier once unused this long.
+	ColdAfterDays int `yaml:"coldAfterDays" json:"coldAfterDays,omitempty"`
+	// KeepVersions is the number of versions kept per key, the current
+	// one included.
+	KeepVersions int `yaml:"keepVersions" json:"keepVersions,omitempty"`
+	// OrphansOnly limits expiry to keys listed as orphans by the latest
+	// orphan report, if it was run on the catalog database over a prefix
+	// covering this one within the last lifecycle interval.
+	OrphansOnly bool `yaml:"orphansOnly" json:"orphansOnly,omitempty"`
+}
+
+// Load reads a policy file. JSON is accepted as it is valid YAML; unknown
+// fields are errors so a typo does not silently disable a rule.
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return p, nil
+}
+
+// Parse parses and validates a policy.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	ids := make(map[string]bool)
+	for i, r := range p.Rules {
+		if r.ID == "" || r.Prefix == "" {
+			return nil, fmt.Errorf("rule %d: id and prefix are required", i+1)
+		}
+		if ids[r.ID] {
+			return nil, fmt.Errorf("rule %s: duplicate id", r.ID)
+		}
+		ids[r.ID] = true
+		switch {
+		case r.ExpireAfterDays < 0 || r.ColdAfterDays < 0 || r.KeepVersions < 0:
+			return nil, fmt.Errorf("rule %s: days and versions cannot be negative", r.ID)
+		case r.ExpireAfterDays == 0 && r.ColdAfterDays == 0 && r.KeepVersions == 0:
+			return nil, fmt.Errorf("rule %s: set expireAfterDays, coldAfterDays or keepVersions", r.ID)
+		case r.ExpireAfterDays > 0 && r.ColdAfterDays >= r.ExpireAfterDays:
+			return nil, fmt.Errorf("rule %s: coldAfterDays must be less than expireAfterDays", r.ID)
+		case r.OrphansOnly && r.ExpireAfterDays == 0:
+			return nil, fmt.Errorf("rule %s: orphansOnly needs expireAfterDays", r.ID)
+		}
+	}
+	return &p, nil
+}
+
+// For returns the rule governing key, or nil.
+func (p *Policy) For(key string) *Rule {
+	for i := range p.Rules {
+		if strings.HasPrefix(key, p.Rules[i].Prefix) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// UsesCold reports whether any rule moves assets to the cold tier.
+func (p *Policy) UsesCold() bool {
+	for _, r := range p.Rules {
+		if r.ColdAfterDays > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/logger"
+)
+
+// maxPending bounds the keys a Tracker holds between flushes; reads of
+// further keys are not counted until the next flush.
+const maxPending = 100_000
+
+// Recorder stores read statistics. *db.AccessRepo implements it.
+type Recorder interface {
+	Record(ctx context.Context, hits []db.AssetAccess) error
+}
+
+// Tracker counts asset reads in memory and writes them to a Recorder in
+// batches, so serving never waits on the database.
+type Tracker struct {
+	rec Recorder
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]db.AssetAccess
+}
+
+func NewTracker(rec Recorder) *Tracker {
+	return &Tracker{rec: rec, now: time.Now, pending: make(map[string]db.AssetAccess)}
+}
+
+// Touch counts a read of key. It has the signature of
+// assets.Handler.Accessed.
+func (t *Tracker) Touch(key string) {
+	now := t.now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.pending[key]
+	if !ok && len(t.pending) >= maxPending {
+		return
+	}
+	t.pending[key] = db.AssetAccess{Key: key, At: now, Count: h.Count + 1}
+}
+
+// Flush writes the reads counted since the last flush. On failure they
+// are kept for the next attempt.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = make(map[string]db.AssetAccess, len(batch))
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	hits := make([]db.AssetAccess, 0, len(batch))
+	for _, h := range batch {
+		hits = append(hits, h)
+	}
+	err := t.rec.Record(ctx, hits)
+	if err == nil {
+		return nil
+	}
+	t.mu.Lock()
+	for key, h := range batch {
+		if cur, ok := t.pending[key]; ok {
+			h.At = cur.At
+			h.Count += cur.Count
+		}
+		t.pending[key] = h
+	}
+	t.mu.Unlock()
+	return err
+}
+
+// Run flushes every interval until ctx is cancelled, then once more.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(context.Background()); err != nil {
+				logger.Errorf("[lifecycle] final access flush failed: %v", err)
+			}
+			return
+		case <-tick.C:
+			if err := t.Flush(ctx); err != nil {
+				logger.Warnf("[lifecycle] access flush failed, retrying next time: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package lint enforces declarative per-prefix rules on asset content —
+// formats, dimensions, file size and names — loaded from a YAML or JSON
+// policy file. The same policy is applied to uploads and by the audit
+// command.
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/imageinfo"
+)
+
+// Policy is an ordered list of rules. Every rule whose pattern matches a
+// key applies to it.
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule constrains the assets matching Match, a glob where "*" matches
+// within one path segment and "**" matches any number of segments (e.g.
+// "products/**"). Zero fields are not checked.
+type Rule struct {
+	ID    string `yaml:"id"`
+	Match string `yaml:"match"`
+	// Formats lists the allowed formats: png, jpeg, gif, webp, svg.
+	Formats   []string `yaml:"formats"`
+	Square    bool     `yaml:"square"`
+	MinWidth  int      `yaml:"minWidth"`
+	MaxWidth  int      `yaml:"maxWidth"`
+	MinHeight int      `yaml:"minHeight"`
+	MaxHeight int      `yaml:"maxHeight"`
+	MaxBytes  ByteSize `yaml:"maxBytes"`
+	// Name is a regular expression the file name must match.
+	Name string `yaml:"name"`
+
+	name *regexp.Regexp
+}
+
+// Check names, appended to a rule ID to form a violation's rule.
+const (
+	CheckFormat     = "format"
+	CheckSquare     = "square"
+	CheckDimensions = "dimensions"
+	CheckMinWidth   = "min-width"
+	CheckMaxWidth   = "max-width"
+	CheckMinHeight  = "min-height"
+	CheckMaxHeight  = "max-height"
+	CheckMaxBytes   = "max-bytes"
+	CheckName       = "name"
+)
+
+// Violation is one failed check. Rule is "<rule id>.<check>", e.g.
+// "product-images.min-width".
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error rejects an asset that violates the policy. It wraps
+// api.ErrRejected.
+type Error struct {
+	Key        string
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Rule + ": " + v.Message
+	}
+	return fmt.Sprintf("%s: %s: %s", api.ErrRejected, e.Key, strings.Join(parts, "; "))
+}
+
+func (e *Error) Unwrap() error { return api.ErrRejected }
+
+var formats = map[string]string{"png": "png", "jpeg": "jpeg", "jpg": "jpeg", "gif": "gif", "webp": "webp", "svg": "svg"}
+
+// Load reads a policy file. JSON is accepted as it is valid YAML; unknown
+// fields are errors so typos do not silently disable a check.
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return p, nil
+}
+
+// Parse parses and validates a policy.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	ids := make(map[string]bool)
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.ID == "" || r.Match == "" {
+			return nil, fmt.Errorf("rule %d: id and match are required", i+1)
+		}
+		if ids[r.ID] {
+			return nil, fmt.Errorf("rule %s: duplicate id", r.ID)
+		}
+		ids[r.ID] = true
+		if _, err := path.Match(strings.ReplaceAll(r.Match, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("rule %s: match: %w", r.ID, err)
+		}
+		for j, f := range r.Formats {
+			canon, ok := formats[strings.ToLower(f)]
+			if !ok {
+				return nil, fmt.Errorf("rule %s: unknown format %q", r.ID, f)
+			}
+			r.Formats[j] = canon
+		}
+		if r.Name != "" {
+			re, err := regexp.Compile(r.Name)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: name: %w", r.ID, err)
+			}
+			r.name = re
+		}
+	}
+	return &p, nil
+}
+
+// Lint returns the violations of key with content data.
+func (p *Policy) Lint(key string, data []byte) []Violation {
+	var out []Violation
+	var info *imageinfo.Info
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if !Match(r.Match, key) {
+			continue
+		}
+		add := func(check, format string, args ...any) {
+			out = append(out, Violation{Rule: r.ID + "." + check, Message: fmt.Sprintf(format, args...)})
+		}
+		if info == nil {
+			inspected := imageinfo.Inspect(data)
+			info = &inspected
+		}
+
+		if len(r.Formats) > 0 {
+			got := imageinfo.Sniff(data)
+			if !contains(r.Formats, got) {
+				if got == "" {
+					got = "not an image"
+				}
+				add(CheckFormat, "format %s is not one of %s", got, strings.Join(r.Formats, ", "))
+			}
+		}
+		if r.MaxBytes > 0 && int64(len(data)) > int64(r.MaxBytes) {
+			add(CheckMaxBytes, "%d bytes exceeds %s", len(data), r.MaxBytes)
+		}
+		if r.name != nil && !r.name.MatchString(path.Base(key)) {
+			add(CheckName, "name %q does not match %s", path.Base(key), r.Name)
+		}
+
+		if !r.Square && r.MinWidth == 0 && r.MaxWidth == 0 && r.MinHeight == 0 && r.MaxHeight == 0 {
+			continue
+		}
+		w, h := info.Width, info.Height
+		if w == 0 || h == 0 {
+			add(CheckDimensions, "dimensions are unknown")
+			continue
+		}
+		if r.Square && w != h {
+			add(CheckSquare, "%dx%d is not square", w, h)
+		}
+		for _, c := range []struct {
+			check      string
+			got, limit int
+			min        bool
+			what       string
+		}{
+			{CheckMinWidth, w, r.MinWidth, true, "width"},
+			{CheckMaxWidth, w, r.MaxWidth, false, "width"},
+			{CheckMinHeight, h, r.MinHeight, true, "height"},
+			{CheckMaxHeight, h, r.MaxHeight, false, "height"},
+		} {
+			switch {
+			case c.limit == 0:
+			case c.min && c.got < c.limit:
+				add(c.check, "%s %d is below %d", c.what, c.got, c.limit)
+			case !c.min && c.got > c.limit:
+				add(c.check, "%s %d is above %d", c.what, c.got, c.limit)
+			}
+		}
+	}
+	return out
+}
+
+// Check returns an *Error listing the violations of key, or nil. It lets
+// a Policy vet uploads.
+func (p *Policy) Check(key string, data []byte) error {
+	if v := p.Lint(key, data); len(v) > 0 {
+		return &Error{Key: key, Violations: v}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether key matches pattern. "**" as a whole segment
+// matches zero or more segments; other segments use path.Match.
+func Match(pattern, key string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(key, "/"))
+}
+
+func matchSegments(pat, key []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(key); i++ {
+				if matchSegments(pat[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(key) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], key[0]); !ok {
+			return false
+		}
+		pat, key = pat[1:], key[1:]
+	}
+	return len(key) == 0
+}
+
+// ByteSize is a size in bytes, written in a policy as a number or with a
+// unit: "500KB" (1000 bytes), "2MiB" (1024² bytes).
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	scale  float64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"B", 1},
+}
+
+// ParseByteSize parses "512000", "500KB", "1.5MB" or "2MiB".
+func ParseByteSize(s string) (ByteSize, error) {
+	num, scale := strings.ToUpper(strings.TrimSpace(s)), 1.0
+	for _, u := range byteUnits {
+		if rest, ok := strings.CutSuffix(num, u.suffix); ok {
+			num, scale = strings.TrimSpace(rest), u.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(v * scale), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return errors.New("size must be a number or a string such as 500KB")
+	}
+	v, err := ParseByteSize(node.Value)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+func (b ByteSize) String() string {
+	return strconv.FormatInt(int64(b), 10) + " bytes"
+}
@@ -0,0 +1,115 @@
+package lint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/imagetest"
+)
+
+const policyYAML = `
+rules:
+  - id: product-images
+    match: "products/**"
+    formats: [png, jpg, svg]
+    square: true
+    minWidth: 800
+    maxBytes: 500KB
+    name: '^product-\d{3}\.(png|jpg|svg)$'
+  - id: banner-size
+    match: "banners/*.png"
+    maxWidth: 1920
+`
+
+func TestLint(t *testing.T) {
+	p, err := Parse([]byte(policyYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Rules[0].MaxBytes != 500_000 || p.Rules[0].Formats[1] != "jpeg" {
+		t.Errorf("parsed rule = %+v", p.Rules[0])
+	}
+
+	tests := []struct {
+		key  string
+		data []byte
+		want []string
+	}{
+		{"products/frozen/product-001.png", imagetest.PNG(t, 800, 800), nil},
+		{"products/frozen/product-002.png", imagetest.PNG(t, 640, 480), []string{"product-images.square", "product-images.min-width"}},
+		{"products/frozen/Product 3.gif", []byte("GIF89a\x01\x00\x01\x00"), []string{
+			"product-images.format", "product-images.name", "product-images.dimensions"}},
+		{"products/frozen/product-004.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="900" height="900"/>`), nil},
+		{"banners/spring.png", imagetest.PNG(t, 2000, 10), []string{"banner-size.max-width"}},
+		{"banners/2026/spring.png", imagetest.PNG(t, 2000, 10), nil}, // * stays within one segment
+		{"ui/logo.png", imagetest.PNG(t, 1, 1), nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range p.Lint(tt.key, tt.data) {
+			got = append(got, v.Rule)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Lint(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	err = p.Check("products/frozen/product-002.png", imagetest.PNG(t, 640, 480))
+	var lerr *Error
+	if !errors.As(err, &lerr) || !errors.Is(err, api.ErrRejected) || len(lerr.Violations) != 2 {
+		t.Fatalf("Check err = %v", err)
+	}
+	if !strings.Contains(err.Error(), "product-images.min-width: width 640 is below 800") {
+		t.Errorf("error message = %q", err)
+	}
+	if err := p.Check("products/frozen/product-001.png", imagetest.PNG(t, 800, 800)); err != nil {
+		t.Errorf("Check(valid) = %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, policy := range map[string]string{
+		"unknown field":  "rules:\n  - id: a\n    match: '*'\n    minwidht: 3\n",
+		"missing id":     "rules:\n  - match: '*'\n",
+		"duplicate id":   "rules:\n  - {id: a, match: '*'}\n  - {id: a, match: '*'}\n",
+		"bad format":     "rules:\n  - {id: a, match: '*', formats: [bmp]}\n",
+		"bad size":       "rules:\n  - {id: a, match: '*', maxBytes: lots}\n",
+		"bad name regex": "rules:\n  - {id: a, match: '*', name: '('}\n",
+	} {
+		if _, err := Parse([]byte(policy)); err == nil {
+			t.Errorf("%s: Parse should fail", name)
+		}
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	_ = os.WriteFile(file, []byte(`{"rules":[{"id":"small","match":"**","maxBytes":"2MiB"}]}`), 0o644)
+	p, err := Load(file)
+	if err != nil || p.Rules[0].MaxBytes != 2<<20 {
+		t.Fatalf("Load = %+v, %v", p, err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"products/**", "products/a.png", true},
+		{"products/**", "products/x/y/a.png", true},
+		{"products/**", "banners/a.png", false},
+		{"**/*.svg", "a.svg", true},
+		{"**/*.svg", "ui/icons/a.svg", true},
+		{"products/*/hero-*.jpg", "products/frozen/hero-1.jpg", true},
+		{"products/*/hero-*.jpg", "products/frozen/x/hero-1.jpg", false},
+	} {
+		if got := Match(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package lqip
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash encodes img as a BlurHash (https://blurha.sh) with xComp by
+// yComp components, each between 1 and 9. Callers should pass a small
+// image; the cost is proportional to pixels times components.
+func BlurHash(img image.Image, xComp, yComp int) (string, error) {
+	if xComp < 1 || xComp > 9 || yComp < 1 || yComp > 9 {
+		return "", fmt.Errorf("blurhash: components must be 1-9, got %dx%d", xComp, yComp)
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return "", fmt.Errorf("blurhash: empty image")
+	}
+
+	// Linear RGB per pixel, computed once.
+	lin := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			lin[y*w+x] = [3]float64{srgbToLinear(r >> 8), srgbToLinear(g >> 8), srgbToLinear(bl >> 8)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				by := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+				for x := 0; x < w; x++ {
+					basis := norm * math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * by
+					p := lin[y*w+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := 1 / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	base83(&sb, (xComp-1)+(yComp-1)*9, 1)
+
+	ac := factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		base83(&sb, quantised, 1)
+	} else {
+		base83(&sb, 0, 1)
+	}
+
+	dc := factors[0]
+	base83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		q := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		base83(&sb, q(f[0])*19*19+q(f[1])*19+q(f[2]), 2)
+	}
+	return sb.String(), nil
+}
+
+func base83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func srgbToLinear(v uint32) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
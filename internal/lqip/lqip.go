@@ -0,0 +1,99 @@
+// Package lqip computes low-quality image placeholders: a BlurHash string
+// and a tiny inline image that clients can paint while the real image
+// loads.
+package lqip
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF for Decode
+	"image/jpeg"
+	_ "image/png" // register PNG for Decode
+
+	"golang.org/x/image/draw"
+
+	"codlocker-assets/internal/imageinfo"
+)
+
+const (
+	// hashSide is the longest side of the image the BlurHash is computed
+	// from; more pixels do not change the result noticeably.
+	hashSide = 32
+	// previewSide is the longest side of the inline JPEG preview.
+	previewSide = 16
+	// previewQuality is the JPEG quality of the inline preview.
+	previewQuality = 40
+)
+
+// ErrUnsupported is returned for data no placeholder can be made from.
+var ErrUnsupported = errors.New("lqip: unsupported image")
+
+// Placeholder is what clients paint before an image loads.
+type Placeholder struct {
+	BlurHash string
+	// LQIP is a data: URI of a tiny preview.
+	LQIP string
+}
+
+// Generate computes the placeholder of a PNG, JPEG, GIF or SVG. SVGs are
+// not rasterised; their placeholder is the background colour of the
+// first full-size rect (or the root's fill/background style). Raster
+// images over the pixel limit are not decoded.
+func Generate(data []byte) (Placeholder, error) {
+	if imageinfo.IsSVG(data) {
+		return generateSVG(data)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Placeholder{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width*cfg.Height > imageinfo.MaxPixels {
+		return Placeholder{}, fmt.Errorf("%w: %dx%d exceeds the %d pixel limit", ErrUnsupported, cfg.Width, cfg.Height, imageinfo.MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Placeholder{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	xc, yc := components(img.Bounds())
+	hash, err := BlurHash(fit(img, hashSide), xc, yc)
+	if err != nil {
+		return Placeholder{}, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, fit(img, previewSide), &jpeg.Options{Quality: previewQuality}); err != nil {
+		return Placeholder{}, fmt.Errorf("encode preview: %w", err)
+	}
+	return Placeholder{
+		BlurHash: hash,
+		LQIP:     "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// components picks 4x3 for landscape images and 3x4 for portrait ones.
+func components(b image.Rectangle) (int, int) {
+	if b.Dy() > b.Dx() {
+		return 3, 4
+	}
+	return 4, 3
+}
+
+// fit scales img so its longest side is at most side pixels.
+func fit(img image.Image, side int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= side && h <= side {
+		return img
+	}
+	if w >= h {
+		w, h = side, max(1, h*side/w)
+	} else {
+		w, h = max(1, w*side/h), side
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
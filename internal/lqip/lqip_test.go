@@ -0,0 +1,99 @@
+package lqip
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"codlocker-assets/internal/imagetest"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBlurHash(t *testing.T) {
+	got, err := BlurHash(solid(8, 8, color.Black), 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "L00000fQfQfQfQfQfQfQfQfQfQfQ"; got != want {
+		t.Errorf("BlurHash(black) = %q, want %q", got, want)
+	}
+	if _, err := BlurHash(solid(8, 8, color.Black), 0, 3); err == nil {
+		t.Errorf("BlurHash with 0 components should fail")
+	}
+}
+
+func TestGenerateRaster(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solid(400, 200, color.RGBA{200, 30, 30, 255})); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Generate(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.BlurHash) != 28 {
+		t.Errorf("BlurHash = %q, want 28 chars for 4x3", p.BlurHash)
+	}
+	data, ok := strings.CutPrefix(p.LQIP, "data:image/jpeg;base64,")
+	if !ok {
+		t.Fatalf("LQIP = %.40q, want a JPEG data URI", p.LQIP)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(data)
+	preview, err := jpeg.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := preview.Bounds(); b.Dx() != previewSide || b.Dy() != previewSide/2 {
+		t.Errorf("preview = %dx%d, want %dx%d", b.Dx(), b.Dy(), previewSide, previewSide/2)
+	}
+}
+
+func TestGenerateSVG(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="120" height="60"><rect width="100%" height="100%" fill="#000"/><circle r="5"/></svg>`
+	p, err := Generate([]byte(svg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.BlurHash != "L00000fQfQfQfQfQfQfQfQfQfQfQ" {
+		t.Errorf("BlurHash = %q", p.BlurHash)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(p.LQIP, "data:image/svg+xml;base64,"))
+	if !strings.Contains(string(raw), `viewBox="0 0 120 60"`) || !strings.Contains(string(raw), `fill="#000000"`) {
+		t.Errorf("LQIP svg = %s", raw)
+	}
+
+	styled := `<svg xmlns="http://www.w3.org/2000/svg" style="background-color: #fff"><path d="M0 0"/></svg>`
+	if p, err := Generate([]byte(styled)); err != nil || p.BlurHash == "" {
+		t.Errorf("Generate(styled) = %+v, %v", p, err)
+	}
+
+	bare := `<svg xmlns="http://www.w3.org/2000/svg"><circle r="5"/></svg>`
+	if _, err := Generate([]byte(bare)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Generate(no background) err = %v, want ErrUnsupported", err)
+	}
+	if _, err := Generate([]byte("plain text")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Generate(text) err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestGenerateOversized(t *testing.T) {
+	_, err := Generate(imagetest.Oversized(60000, 60000))
+	if !errors.Is(err, ErrUnsupported) || !strings.Contains(err.Error(), "pixel limit") {
+		t.Errorf("Generate(60000x60000) err = %v, want the pixel limit", err)
+	}
+}
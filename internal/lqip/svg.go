@@ -0,0 +1,144 @@
+package lqip
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"codlocker-assets/internal/imageinfo"
+)
+
+// generateSVG builds a flat placeholder from an SVG's background colour.
+func generateSVG(data []byte) (Placeholder, error) {
+	bg, ok := svgBackground(data)
+	if !ok {
+		return Placeholder{}, fmt.Errorf("%w: SVG without a background colour", ErrUnsupported)
+	}
+	info := imageinfo.Inspect(data)
+	w, h := info.Width, info.Height
+	if w == 0 || h == 0 {
+		w, h = 1, 1
+	}
+
+	xc, yc := components(image.Rect(0, 0, w, h))
+	flat := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	hash, err := BlurHash(flat, xc, yc)
+	if err != nil {
+		return Placeholder{}, err
+	}
+	hex := fmt.Sprintf("#%02x%02x%02x", bg.R, bg.G, bg.B)
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d"><rect width="100%%" height="100%%" fill="%s"/></svg>`, w, h, hex)
+	return Placeholder{
+		BlurHash: hash,
+		LQIP:     "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg)),
+	}, nil
+}
+
+// svgBackground finds the colour of the root element's fill or background
+// style, or of the first rect child that covers the whole canvas.
+func svgBackground(data []byte) (color.RGBA, bool) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return color.RGBA{}, false
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1 && el.Name.Local == "svg":
+				if c, ok := parseColor(styleOf(el, "background-color", "background")); ok {
+					return c, true
+				}
+			case depth == 1:
+				return color.RGBA{}, false
+			case depth == 2 && el.Name.Local == "rect":
+				if coversCanvas(el) {
+					return parseColor(styleOf(el, "fill"))
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// styleOf returns the first of props found as an attribute or in the
+// style attribute of el.
+func styleOf(el xml.StartElement, props ...string) string {
+	for _, p := range props {
+		for _, a := range el.Attr {
+			if a.Name.Local == p {
+				return a.Value
+			}
+		}
+		for _, a := range el.Attr {
+			if a.Name.Local != "style" {
+				continue
+			}
+			for _, decl := range strings.Split(a.Value, ";") {
+				if k, v, ok := strings.Cut(decl, ":"); ok && strings.TrimSpace(k) == p {
+					return strings.TrimSpace(v)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// coversCanvas reports whether a rect sits at the origin with width and
+// height given (as the viewBox size or 100%).
+func coversCanvas(el xml.StartElement) bool {
+	var hasW, hasH bool
+	for _, a := range el.Attr {
+		switch a.Name.Local {
+		case "x", "y":
+			if v := strings.TrimSuffix(a.Value, "px"); v != "0" && v != "0%" {
+				return false
+			}
+		case "width":
+			hasW = true
+		case "height":
+			hasH = true
+		}
+	}
+	return hasW && hasH
+}
+
+var namedColors = map[string]color.RGBA{
+	"white": {255, 255, 255, 255},
+	"black": {0, 0, 0, 255},
+}
+
+// parseColor understands #rgb, #rrggbb and a few names.
+func parseColor(s string) (color.RGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok {
+		return color.RGBA{}, false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, false
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 255}, true
+}
@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"codlocker-assets/internal/storage"
+)
+
+// ClassifierActor is the actor of automatic decisions in the audit trail.
+const ClassifierActor = "classifier"
+
+// Classifier decisions.
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+	DecisionReview  = "review" // leave it to a moderator
+)
+
+// Verdict is a classifier's view of an upload.
+type Verdict struct {
+	Decision string  `json:"decision"`
+	Label    string  `json:"label,omitempty"`
+	Score    float64 `json:"score,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+func (v Verdict) note() string {
+	note := v.Decision
+	if v.Label != "" {
+		note += " " + v.Label + " " + strconv.FormatFloat(v.Score, 'f', 2, 64)
+	}
+	if v.Reason != "" {
+		note += ": " + v.Reason
+	}
+	return note
+}
+
+// Classifier looks at uploads as they are held.
+type Classifier interface {
+	Classify(ctx context.Context, key string, data []byte) (Verdict, error)
+}
+
+// HTTPClassifier asks an external service. It POSTs the upload with its
+// Content-Type and an X-Asset-Key header, and expects a Verdict as JSON.
+type HTTPClassifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPClassifier returns a classifier calling url, giving up after
+// timeout.
+func NewHTTPClassifier(url string, timeout time.Duration) *HTTPClassifier {
+	return &HTTPClassifier{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *HTTPClassifier) Classify(ctx context.Context, key string, data []byte) (Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(data))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", storage.DetectContentType(key, data))
+	req.Header.Set("X-Asset-Key", key)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Verdict{}, fmt.Errorf("classifier returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var v Verdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&v); err != nil {
+		return Verdict{}, fmt.Errorf("decode verdict: %w", err)
+	}
+	switch v.Decision {
+	case DecisionApprove, DecisionReject, DecisionReview:
+		return v, nil
+	}
+	return Verdict{}, fmt.Errorf("unknown decision %q", v.Decision)
+}
@@ -0,0 +1,118 @@
+package moderation
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the moderator endpoints:
+//
+//	GET  /api/v1/moderation?status=pending&prefix=&limit=   list items, oldest first
+//	GET  /api/v1/moderation/{id}                           item and audit trail
+//	GET  /api/v1/moderation/{id}/content                   held content, while pending
+//	POST /api/v1/moderation/{id}/approve                   publish to /assets/
+//	POST /api/v1/moderation/{id}/reject                    discard; optional {"reason": "..."}
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/moderation", s.handleList).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/moderation/{id}", s.handleGet).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/moderation/{id}/content", s.handleContent).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/moderation/{id}/approve", s.handleApprove).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/moderation/{id}/reject", s.handleReject).Methods(http.MethodPost)
+}
+
+func decisionError(w http.ResponseWriter, err error) {
+	status := api.StatusFor(err)
+	if errors.Is(err, ErrDecided) {
+		status = http.StatusConflict
+	}
+	api.Error(w, status, err.Error())
+}
+
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "":
+		status = db.ModerationPending
+	case "any":
+		status = ""
+	case db.ModerationPending, db.ModerationApproved, db.ModerationRejected:
+	default:
+		api.Error(w, http.StatusBadRequest, "status must be pending, approved, rejected or any")
+		return
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			api.Error(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	items, err := s.List(r.Context(), status, q.Get("prefix"), limit)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	if items == nil {
+		items = []db.ModerationItem{}
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	m, events, err := s.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"item": m, "events": events})
+}
+
+func (s *Service) handleContent(w http.ResponseWriter, r *http.Request) {
+	obj, m, err := s.Content(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	defer obj.Close()
+	w.Header().Set("Content-Type", m.ContentType)
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", m.SubmittedAt, obj)
+}
+
+func (s *Service) handleApprove(w http.ResponseWriter, r *http.Request) {
+	m, err := s.Approve(r.Context(), mux.Vars(r)["id"], api.Actor(r))
+	if err != nil {
+		decisionError(w, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, m)
+}
+
+func (s *Service) handleReject(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := api.DecodeJSON(w, r, &body); err != nil {
+			api.Error(w, api.StatusFor(err), err.Error())
+			return
+		}
+	}
+	m, err := s.Reject(r.Context(), mux.Vars(r)["id"], api.Actor(r), body.Reason)
+	if err != nil {
+		decisionError(w, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, m)
+}
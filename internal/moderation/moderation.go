@@ -0,0 +1,235 @@
+// Package moderation holds uploads to designated prefixes in quarantine
+// until a moderator, or an automatic classifier, approves them. Held
+// content is kept outside live storage, so it cannot be served before
+// approval, and every step is recorded in an audit trail.
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// ErrDecided is returned when approving or rejecting an item that is no
+// longer pending.
+var ErrDecided = errors.New("already decided")
+
+// Audit trail actions, besides the statuses approved and rejected.
+const (
+	ActionSubmitted  = "submitted"
+	ActionClassified = "classified"
+	ActionReopened   = "reopened"
+)
+
+// Index stores items and their audit trail. *db.ModerationRepo
+// implements it.
+type Index interface {
+	Add(ctx context.Context, m db.ModerationItem, events ...db.ModerationEvent) error
+	Get(ctx context.Context, id string) (db.ModerationItem, error)
+	List(ctx context.Context, status, prefix string, limit int) ([]db.ModerationItem, error)
+	Decide(ctx context.Context, id, status string, e db.ModerationEvent) error
+	Reopen(ctx context.Context, id string, e db.ModerationEvent) error
+	Events(ctx context.Context, id string) ([]db.ModerationEvent, error)
+}
+
+// PublishFunc writes approved content to live storage. It lets the
+// caller route approvals through versioning.
+type PublishFunc func(ctx context.Context, key string, data []byte, actor string) error
+
+// Prefixes are the key prefixes whose uploads are moderated.
+type Prefixes []string
+
+// Holds reports whether uploads to key are moderated.
+func (p Prefixes) Holds(key string) bool {
+	for _, prefix := range p {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Service quarantines uploads under its prefixes.
+type Service struct {
+	prefixes   Prefixes
+	bin        storage.ReadWriter
+	index      Index
+	publish    PublishFunc
+	classifier Classifier
+	now        func() time.Time
+}
+
+// New returns a Service holding uploads under prefixes. Held content is
+// kept in bin until decided; approved content is handed to publish.
+func New(prefixes Prefixes, bin storage.ReadWriter, index Index, publish PublishFunc) *Service {
+	return &Service{prefixes: prefixes, bin: bin, index: index, publish: publish, now: time.Now}
+}
+
+// UseClassifier has c look at every upload as it is held. Without one,
+// every upload waits for a moderator.
+func (s *Service) UseClassifier(c Classifier) {
+	s.classifier = c
+}
+
+// Holds reports whether uploads to key are moderated.
+func (s *Service) Holds(key string) bool {
+	return s.prefixes.Holds(key)
+}
+
+// Hold quarantines an upload; it implements versions.Holder.
+func (s *Service) Hold(ctx context.Context, key string, data []byte, actor string) (any, error) {
+	return s.Submit(ctx, key, data, actor)
+}
+
+// Submit quarantines data for key and runs the classifier, which may
+// approve or reject it straight away. A failing classifier leaves the
+// item pending.
+func (s *Service) Submit(ctx context.Context, key string, data []byte, actor string) (db.ModerationItem, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return db.ModerationItem{}, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return db.ModerationItem{}, fmt.Errorf("new moderation id: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	now := s.now().UTC()
+	m := db.ModerationItem{
+		ID:          id.String(),
+		Key:         key,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		ContentType: storage.DetectContentType(key, data),
+		Status:      db.ModerationPending,
+		SubmittedAt: now,
+		SubmittedBy: actor,
+	}
+	events := []db.ModerationEvent{{ItemID: m.ID, At: now, Actor: actor, Action: ActionSubmitted}}
+
+	var verdict Verdict
+	if s.classifier != nil {
+		var err error
+		verdict, err = s.classifier.Classify(ctx, key, data)
+		if err != nil {
+			logger.Warnf("[moderation] classify %s: %v", key, err)
+			verdict = Verdict{Decision: DecisionReview, Reason: "classifier failed: " + err.Error()}
+		}
+		m.Label, m.Score = verdict.Label, verdict.Score
+		events = append(events, db.ModerationEvent{
+			ItemID: m.ID, At: now, Actor: ClassifierActor, Action: ActionClassified, Note: verdict.note(),
+		})
+	}
+
+	if err := s.bin.Put(m.ID, data); err != nil {
+		return db.ModerationItem{}, fmt.Errorf("store held upload: %w", err)
+	}
+	if err := s.index.Add(ctx, m, events...); err != nil {
+		_ = s.bin.Delete(m.ID)
+		return db.ModerationItem{}, err
+	}
+
+	switch verdict.Decision {
+	case DecisionApprove:
+		return s.decide(ctx, m.ID, db.ModerationApproved, ClassifierActor, verdict.Reason)
+	case DecisionReject:
+		return s.decide(ctx, m.ID, db.ModerationRejected, ClassifierActor, verdict.Reason)
+	}
+	return m, nil
+}
+
+// Approve publishes a pending item to its key.
+func (s *Service) Approve(ctx context.Context, id, actor string) (db.ModerationItem, error) {
+	return s.decide(ctx, id, db.ModerationApproved, actor, "")
+}
+
+// Reject discards a pending item.
+func (s *Service) Reject(ctx context.Context, id, actor, reason string) (db.ModerationItem, error) {
+	return s.decide(ctx, id, db.ModerationRejected, actor, reason)
+}
+
+// decide records the decision first, so two moderators cannot both act
+// on an item, then publishes approved content as its uploader. If that
+// fails the item is reopened. Decided content leaves the quarantine.
+func (s *Service) decide(ctx context.Context, id, status, actor, reason string) (db.ModerationItem, error) {
+	m, err := s.index.Get(ctx, id)
+	if err != nil {
+		return db.ModerationItem{}, err
+	}
+	if m.Status != db.ModerationPending {
+		return db.ModerationItem{}, fmt.Errorf("%w: %s is %s", ErrDecided, m.ID, m.Status)
+	}
+	var data []byte
+	if status == db.ModerationApproved {
+		if data, err = s.bin.Get(m.ID); err != nil {
+			return db.ModerationItem{}, fmt.Errorf("read held upload: %w", err)
+		}
+	}
+
+	now := s.now().UTC()
+	err = s.index.Decide(ctx, m.ID, status, db.ModerationEvent{ItemID: m.ID, At: now, Actor: actor, Action: status, Note: reason})
+	if errors.Is(err, db.ErrNotFound) {
+		return db.ModerationItem{}, fmt.Errorf("%w: %s", ErrDecided, m.ID)
+	}
+	if err != nil {
+		return db.ModerationItem{}, err
+	}
+	if status == db.ModerationApproved {
+		if err := s.publish(ctx, m.Key, data, m.SubmittedBy); err != nil {
+			reopen := db.ModerationEvent{ItemID: m.ID, At: s.now().UTC(), Actor: actor, Action: ActionReopened, Note: "publish failed: " + err.Error()}
+			if rerr := s.index.Reopen(ctx, m.ID, reopen); rerr != nil {
+				logger.Errorf("[moderation] reopen %s after failed publish: %v", m.ID, rerr)
+			}
+			return db.ModerationItem{}, fmt.Errorf("publish %s: %w", m.Key, err)
+		}
+	}
+	if err := s.bin.Delete(m.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logger.Warnf("[moderation] drop held upload %s: %v", m.ID, err)
+	}
+
+	m.Status, m.DecidedAt, m.DecidedBy, m.Reason = status, &now, actor, reason
+	return m, nil
+}
+
+// List returns items with status ("" for any) under prefix, oldest
+// first.
+func (s *Service) List(ctx context.Context, status, prefix string, limit int) ([]db.ModerationItem, error) {
+	return s.index.List(ctx, status, prefix, limit)
+}
+
+// Get returns an item and its audit trail.
+func (s *Service) Get(ctx context.Context, id string) (db.ModerationItem, []db.ModerationEvent, error) {
+	m, err := s.index.Get(ctx, id)
+	if err != nil {
+		return db.ModerationItem{}, nil, err
+	}
+	events, err := s.index.Events(ctx, id)
+	if err != nil {
+		return db.ModerationItem{}, nil, err
+	}
+	return m, events, nil
+}
+
+// Content opens the held content of a pending item for review.
+func (s *Service) Content(ctx context.Context, id string) (io.ReadSeekCloser, db.ModerationItem, error) {
+	m, err := s.index.Get(ctx, id)
+	if err != nil {
+		return nil, db.ModerationItem{}, err
+	}
+	r, err := storage.Open(s.bin, m.ID)
+	if err != nil {
+		return nil, db.ModerationItem{}, err
+	}
+	return r, m, nil
+}
@@ -0,0 +1,296 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/storage"
+)
+
+// memIndex is an in-memory Index.
+type memIndex struct {
+	mu     sync.Mutex
+	items  []db.ModerationItem
+	events []db.ModerationEvent
+}
+
+func (m *memIndex) Add(_ context.Context, item db.ModerationItem, events ...db.ModerationEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = append(m.items, item)
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *memIndex) Get(_ context.Context, id string) (db.ModerationItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, it := range m.items {
+		if it.ID == id {
+			return it, nil
+		}
+	}
+	return db.ModerationItem{}, db.ErrNotFound
+}
+
+func (m *memIndex) List(_ context.Context, status, prefix string, limit int) ([]db.ModerationItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.ModerationItem
+	for _, it := range m.items {
+		if (status == "" || it.Status == status) && strings.HasPrefix(it.Key, prefix) && len(out) < limit {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+func (m *memIndex) set(id string, e db.ModerationEvent, pendingOnly bool, f func(*db.ModerationItem)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.items {
+		if m.items[i].ID == id && (!pendingOnly || m.items[i].Status == db.ModerationPending) {
+			f(&m.items[i])
+			m.events = append(m.events, e)
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+func (m *memIndex) Decide(_ context.Context, id, status string, e db.ModerationEvent) error {
+	return m.set(id, e, true, func(it *db.ModerationItem) {
+		it.Status, it.DecidedAt, it.DecidedBy, it.Reason = status, &e.At, e.Actor, e.Note
+	})
+}
+
+func (m *memIndex) Reopen(_ context.Context, id string, e db.ModerationEvent) error {
+	return m.set(id, e, false, func(it *db.ModerationItem) {
+		it.Status, it.DecidedAt, it.DecidedBy, it.Reason = db.ModerationPending, nil, "", ""
+	})
+}
+
+func (m *memIndex) Events(_ context.Context, id string) ([]db.ModerationEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.ModerationEvent
+	for _, e := range m.events {
+		if e.ItemID == id {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// stubClassifier decides by a word in the upload.
+type stubClassifier struct{}
+
+func (stubClassifier) Classify(_ context.Context, _ string, data []byte) (Verdict, error) {
+	switch {
+	case strings.Contains(string(data), "safe"):
+		return Verdict{Decision: DecisionApprove, Label: "safe", Score: 0.99}, nil
+	case strings.Contains(string(data), "nsfw"):
+		return Verdict{Decision: DecisionReject, Label: "nsfw", Score: 0.97, Reason: "explicit content"}, nil
+	case strings.Contains(string(data), "broken"):
+		return Verdict{}, errors.New("model unavailable")
+	}
+	return Verdict{Decision: DecisionReview, Label: "unsure", Score: 0.5}, nil
+}
+
+type fixture struct {
+	svc       *Service
+	index     *memIndex
+	bin, live *storage.LocalStorage
+	failNext  bool
+}
+
+func newFixture(t *testing.T, c Classifier) *fixture {
+	t.Helper()
+	f := &fixture{
+		index: &memIndex{},
+		bin:   storage.NewLocalStorage(t.TempDir()),
+		live:  storage.NewLocalStorage(t.TempDir()),
+	}
+	f.svc = New([]string{"reviews/"}, f.bin, f.index, func(_ context.Context, key string, data []byte, _ string) error {
+		if f.failNext {
+			f.failNext = false
+			return errors.New("disk full")
+		}
+		return f.live.Put(key, data)
+	})
+	if c != nil {
+		f.svc.UseClassifier(c)
+	}
+	return f
+}
+
+func TestModeration(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, nil)
+
+	if !f.svc.Holds("reviews/p-1/r-1.jpg") || f.svc.Holds("products/p-1.jpg") {
+		t.Error("Holds does not follow the prefixes")
+	}
+
+	m, err := f.svc.Submit(ctx, "reviews/p-1/r-1.jpg", []byte("jpeg"), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != db.ModerationPending || f.live.Exists(m.Key) || !f.bin.Exists(m.ID) {
+		t.Fatalf("held item = %+v", m)
+	}
+
+	f.failNext = true
+	if _, err := f.svc.Approve(ctx, m.ID, "mod"); err == nil {
+		t.Fatal("Approve succeeded although publishing failed")
+	}
+	if got, _ := f.index.Get(ctx, m.ID); got.Status != db.ModerationPending || !f.bin.Exists(m.ID) {
+		t.Errorf("item after failed publish = %+v", got)
+	}
+
+	if m, err = f.svc.Approve(ctx, m.ID, "mod"); err != nil || m.Status != db.ModerationApproved || m.DecidedBy != "mod" {
+		t.Fatalf("Approve = %+v, %v", m, err)
+	}
+	if data, _ := f.live.Get(m.Key); string(data) != "jpeg" || f.bin.Exists(m.ID) {
+		t.Errorf("approved content not published or still held: %q", data)
+	}
+	if _, err := f.svc.Reject(ctx, m.ID, "mod", "late"); !errors.Is(err, ErrDecided) {
+		t.Errorf("Reject after approval = %v, want ErrDecided", err)
+	}
+
+	_, events, err := f.svc.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trail []string
+	for _, e := range events {
+		trail = append(trail, e.Actor+" "+e.Action)
+	}
+	if got := strings.Join(trail, ", "); got != "alice submitted, mod approved, mod reopened, mod approved" {
+		t.Errorf("audit trail = %s", got)
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, stubClassifier{})
+
+	for _, c := range []struct {
+		body, status, label string
+	}{
+		{"safe review photo", db.ModerationApproved, "safe"},
+		{"nsfw review photo", db.ModerationRejected, "nsfw"},
+		{"review photo", db.ModerationPending, "unsure"},
+		{"broken review photo", db.ModerationPending, ""},
+	} {
+		m, err := f.svc.Submit(ctx, "reviews/p-1/"+strings.Fields(c.body)[0]+".jpg", []byte(c.body), "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Status != c.status || m.Label != c.label {
+			t.Errorf("%q: item = %+v, want %s/%s", c.body, m, c.status, c.label)
+		}
+		if live := f.live.Exists(m.Key); live != (c.status == db.ModerationApproved) {
+			t.Errorf("%q: live = %v", c.body, live)
+		}
+		_, events, _ := f.svc.Get(ctx, m.ID)
+		if len(events) < 2 || events[1].Actor != ClassifierActor || events[1].Action != ActionClassified {
+			t.Errorf("%q: events = %+v", c.body, events)
+		}
+	}
+}
+
+func TestHTTPClassifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Header.Get("X-Asset-Key") != "reviews/r-1.svg" || r.Header.Get("Content-Type") != "image/svg+xml":
+			http.Error(w, "bad request", http.StatusBadRequest)
+		case strings.Contains(string(body), "odd"):
+			_, _ = w.Write([]byte(`{"decision": "maybe"}`))
+		default:
+			_, _ = w.Write([]byte(`{"decision": "reject", "label": "spam", "score": 0.8}`))
+		}
+	}))
+	defer srv.Close()
+	c := NewHTTPClassifier(srv.URL, 0)
+
+	v, err := c.Classify(context.Background(), "reviews/r-1.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`))
+	if err != nil || v.Decision != DecisionReject || v.Label != "spam" {
+		t.Errorf("Classify = %+v, %v", v, err)
+	}
+	if _, err := c.Classify(context.Background(), "reviews/r-1.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><!-- odd --></svg>`)); err == nil {
+		t.Error("unknown decision accepted")
+	}
+	if _, err := c.Classify(context.Background(), "reviews/r-1.png", []byte("png")); err == nil {
+		t.Error("error status accepted")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, nil)
+	r := mux.NewRouter()
+	f.svc.Register(r)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-User", "mod")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first, _ := f.svc.Submit(ctx, "reviews/p-1/r-1.jpg", []byte("first"), "alice")
+	second, _ := f.svc.Submit(ctx, "reviews/p-2/r-2.jpg", []byte("second"), "bob")
+
+	rec := do(http.MethodGet, "/api/v1/moderation?prefix=reviews/p-1/", "")
+	var listing struct {
+		Items []db.ModerationItem `json:"items"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || len(listing.Items) != 1 || listing.Items[0].ID != first.ID {
+		t.Fatalf("list = %d %+v, %v", rec.Code, listing, err)
+	}
+	if rec := do(http.MethodGet, "/api/v1/moderation?status=bogus", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bogus status = %d, want 400", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/moderation/"+first.ID+"/content", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "first" || rec.Header().Get("Cache-Control") != "private, no-store" {
+		t.Errorf("content = %d %q %v", rec.Code, rec.Body, rec.Header())
+	}
+
+	if rec := do(http.MethodPost, "/api/v1/moderation/"+first.ID+"/approve", ""); rec.Code != http.StatusOK || !f.live.Exists(first.Key) {
+		t.Errorf("approve = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/v1/moderation/"+first.ID+"/approve", ""); rec.Code != http.StatusConflict {
+		t.Errorf("second approve = %d, want 409", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/moderation/"+second.ID+"/reject", `{"reason": "off-topic"}`); rec.Code != http.StatusOK || f.live.Exists(second.Key) {
+		t.Errorf("reject = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/v1/moderation/"+second.ID+"/content", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("content of rejected item = %d, want 404", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/moderation/"+second.ID, "")
+	var detail struct {
+		Item   db.ModerationItem    `json:"item"`
+		Events []db.ModerationEvent `json:"events"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil || detail.Item.Reason != "off-topic" || len(detail.Events) != 2 {
+		t.Errorf("detail = %+v, %v", detail, err)
+	}
+	if rec := do(http.MethodPost, "/api/v1/moderation/nope/approve", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("approve unknown = %d, want 404", rec.Code)
+	}
+}
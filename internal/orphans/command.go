@@ -0,0 +1,115 @@
+package orphans
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"codlocker-assets/internal/db"
+)
+
+// Exit codes of Command.
+const (
+	ExitOK       = 0
+	ExitFindings = 1
+	ExitError    = 2
+)
+
+// Env is what Command runs against, supplied by main.
+type Env struct {
+	Source    Source
+	Redirects Resolver
+	// Query runs a catalog query for -db.
+	Query func(ctx context.Context, query string) ([]db.ImageRef, error)
+}
+
+// Command implements "codlocker-assets orphans". It reads the catalog
+// from -catalog or, with -db, from a read-only query, writes the report
+// to stdout and returns the process exit code: non-zero when there are
+// orphans or dangling references.
+func Command(args []string, env Env, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("orphans", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	catalog := fs.String("catalog", "", "catalog export (.csv or .json)")
+	fromDB := fs.Bool("db", false, "read the catalog with -query instead of -catalog")
+	defaultQuery := os.Getenv("ASSETS_CATALOG_QUERY")
+	if defaultQuery == "" {
+		defaultQuery = db.GalleryRefsQuery
+	}
+	query := fs.String("query", defaultQuery, "catalog query returning (product id, image url) rows; defaults to $ASSETS_CATALOG_QUERY or the galleries")
+	prefix := fs.String("prefix", DefaultPrefix, "only keys under this prefix can be orphans")
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	orphansOnly := fs.Bool("orphans", false, "write only orphan keys, one per line")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: codlocker-assets orphans (-catalog FILE | -db) [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	} else if err != nil {
+		return ExitError
+	}
+	if (*catalog == "") == !*fromDB {
+		fs.Usage()
+		return ExitError
+	}
+
+	ctx := context.Background()
+	var refs []db.ImageRef
+	var err error
+	source := SourceExport
+	if *fromDB {
+		source = SourceDB
+		refs, err = env.Query(ctx, *query)
+	} else {
+		refs, err = LoadFile(*catalog)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "orphans: %v\n", err)
+		return ExitError
+	}
+
+	rep, err := Run(ctx, env.Source, refs, Options{Prefix: *prefix, Source: source, Redirects: env.Redirects})
+	if err != nil {
+		fmt.Fprintf(stderr, "orphans: %v\n", err)
+		return ExitError
+	}
+	switch {
+	case *orphansOnly:
+		for _, key := range rep.Orphans {
+			fmt.Fprintln(stdout, key)
+		}
+	case *asJSON:
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(rep)
+	default:
+		WriteText(stdout, rep)
+	}
+	if len(rep.Orphans)+len(rep.Dangling) > 0 {
+		return ExitFindings
+	}
+	return ExitOK
+}
+
+// WriteText writes rep for people.
+func WriteText(w io.Writer, rep Report) {
+	for _, key := range rep.Orphans {
+		fmt.Fprintf(w, "orphan     %s\n", key)
+	}
+	for _, r := range rep.Dangling {
+		fmt.Fprintf(w, "dangling   %s: %s (%s)\n", r.ProductID, r.URL, r.Reason)
+	}
+	for _, r := range rep.Redirected {
+		fmt.Fprintf(w, "redirected %s: %s -> %s\n", r.ProductID, r.URL, r.Target)
+	}
+	if len(rep.Orphans)+len(rep.Dangling)+len(rep.Redirected) > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d products, %d references, %d assets under %s: %d orphans, %d dangling, %d redirected\n",
+		rep.Products, rep.References, rep.Scanned, rep.Prefix, len(rep.Orphans), len(rep.Dangling), len(rep.Redirected))
+}
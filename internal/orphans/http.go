@@ -0,0 +1,80 @@
+package orphans
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the report endpoints:
+//
+//	POST /api/v1/reports/orphans?prefix=products/             run on a CSV or JSON export
+//	POST /api/v1/reports/orphans?source=db&prefix=products/   run on the catalog query
+//	GET  /api/v1/reports/orphans                              latest report
+//
+// The export's format comes from the Content-Type: text/csv or
+// application/json.
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/reports/orphans", s.handleRun).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/reports/orphans", s.handleLast).Methods(http.MethodGet)
+}
+
+func (s *Service) handleRun(w http.ResponseWriter, r *http.Request) {
+	refs, err := s.readCatalog(w, r)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	source := SourceExport
+	if r.URL.Query().Get("source") == "db" {
+		source = SourceDB
+	}
+	rep, err := s.Run(r.Context(), refs, source, r.URL.Query().Get("prefix"))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rep)
+}
+
+func (s *Service) readCatalog(w http.ResponseWriter, r *http.Request) ([]db.ImageRef, error) {
+	if r.URL.Query().Get("source") == "db" {
+		if s.query == nil {
+			return nil, fmt.Errorf("%w: no catalog database configured", api.ErrBadRequest)
+		}
+		return s.query(r.Context())
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var format string
+	switch ct {
+	case "text/csv":
+		format = FormatCSV
+	case "application/json":
+		format = FormatJSON
+	default:
+		return nil, fmt.Errorf("%w: Content-Type must be text/csv or application/json, or use ?source=db", api.ErrBadRequest)
+	}
+	data, err := api.ReadBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", api.ErrBadRequest, err)
+	}
+	return refs, nil
+}
+
+func (s *Service) handleLast(w http.ResponseWriter, _ *http.Request) {
+	rep, ok := s.Last()
+	if !ok {
+		api.Error(w, http.StatusNotFound, "no report has run yet")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,196 @@
+package orphans
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"codlocker-assets/internal/db"
+)
+
+// Catalog export formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// productFields are the column or field names taken as the product ID,
+// in order of preference. Case, spaces, "_" and "-" are ignored, so
+// "product_id" and "Product ID" match "productId".
+var productFields = []string{"productId", "product", "sku", "id"}
+
+// LoadFile reads a catalog export, picking the format from the file's
+// extension.
+func LoadFile(file string) ([]db.ImageRef, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := Parse(data, strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return refs, nil
+}
+
+// Parse reads a catalog export in format (FormatCSV or FormatJSON).
+func Parse(data []byte, format string) ([]db.ImageRef, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatJSON:
+		return parseJSON(data)
+	default:
+		return nil, fmt.Errorf("unknown catalog format %q (want csv or json)", format)
+	}
+}
+
+// parseCSV reads a CSV with a header row. The product ID column is the
+// first of productFields present; every image column (see imageField)
+// holds image URLs, several to a cell when separated by "|".
+func parseCSV(data []byte) ([]db.ImageRef, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	idCol := -1
+	for _, want := range productFields {
+		for i, h := range header {
+			if normalizeField(h) == normalizeField(want) {
+				idCol = i
+				break
+			}
+		}
+		if idCol >= 0 {
+			break
+		}
+	}
+	var imageCols []int
+	for i, h := range header {
+		if i != idCol && imageField(h) {
+			imageCols = append(imageCols, i)
+		}
+	}
+	if idCol < 0 || len(imageCols) == 0 {
+		return nil, fmt.Errorf("CSV needs a product ID column (%s) and an image or URL column", strings.Join(productFields, ", "))
+	}
+
+	var refs []db.ImageRef
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		if idCol >= len(rec) {
+			continue
+		}
+		for _, c := range imageCols {
+			if c >= len(rec) {
+				continue
+			}
+			for _, u := range strings.Split(rec[c], "|") {
+				if u = strings.TrimSpace(u); u != "" {
+					refs = append(refs, db.ImageRef{ProductID: strings.TrimSpace(rec[idCol]), URL: u})
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+// parseJSON reads an array of products, or an object holding one under
+// "products". A product's ID is the first of productFields present, and
+// its image fields (see imageField) hold a URL, or a list of URLs or of
+// objects with a "url" or "key".
+func parseJSON(data []byte) ([]db.ImageRef, error) {
+	var products []map[string]any
+	if err := json.Unmarshal(data, &products); err != nil {
+		var wrapped struct {
+			Products []map[string]any `json:"products"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil || wrapped.Products == nil {
+			return nil, fmt.Errorf("parse JSON: want an array of products or {\"products\": [...]}: %w", err)
+		}
+		products = wrapped.Products
+	}
+
+	var refs []db.ImageRef
+	for i, p := range products {
+		fields := make(map[string]any, len(p))
+		for k, v := range p {
+			fields[normalizeField(k)] = v
+		}
+		var id string
+		for _, f := range productFields {
+			if v, ok := fields[normalizeField(f)]; ok {
+				id = strings.TrimSpace(fmt.Sprint(v))
+				break
+			}
+		}
+		if id == "" {
+			return nil, fmt.Errorf("product %d has no ID (%s)", i+1, strings.Join(productFields, ", "))
+		}
+		for _, u := range imageURLs(fields) {
+			refs = append(refs, db.ImageRef{ProductID: id, URL: u})
+		}
+	}
+	return refs, nil
+}
+
+func imageURLs(fields map[string]any) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if imageField(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var out []string
+	var add func(v any)
+	add = func(v any) {
+		switch v := v.(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		case []any:
+			for _, item := range v {
+				add(item)
+			}
+		case map[string]any:
+			for _, k := range []string{"url", "key"} {
+				if s, ok := v[k].(string); ok && s != "" {
+					out = append(out, s)
+					return
+				}
+			}
+		}
+	}
+	for _, name := range names {
+		add(fields[name])
+	}
+	return out
+}
+
+// imageField reports whether a column or field holds image URLs: its
+// name mentions "image" ("image_url", "Images") or is "url" or "urls".
+func imageField(name string) bool {
+	name = normalizeField(name)
+	return strings.Contains(name, "image") || name == "url" || name == "urls"
+}
+
+func normalizeField(s string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+}
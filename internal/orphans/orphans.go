@@ -0,0 +1,213 @@
+// Package orphans cross-references a product catalog with storage. It
+// reports images no product references (orphans) and product image URLs
+// that do not resolve to an asset (dangling), from a CSV or JSON catalog
+// export or a read-only database query.
+package orphans
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/storage"
+)
+
+// DefaultPrefix is where product images live; only keys under the prefix
+// are candidates for orphans.
+const DefaultPrefix = "products/"
+
+// Source is storage that can be walked.
+type Source interface {
+	storage.Storage
+	storage.Lister
+}
+
+// Resolver follows redirects and aliases for moved assets. It has the
+// signature of assets.Redirector; *redirects.Service implements it.
+type Resolver interface {
+	Resolve(key string) (target string, status int, deprecated time.Time, ok bool)
+}
+
+// Catalog sources recorded in a report.
+const (
+	SourceDB     = "db"     // the catalog query, complete
+	SourceExport = "export" // an uploaded CSV or JSON export, maybe partial
+)
+
+// Options configures a run.
+type Options struct {
+	// Prefix limits the orphan search; "" means DefaultPrefix.
+	Prefix string
+	// Source records where refs came from; "" means SourceExport.
+	Source string
+	// Redirects, when set, counts references to moved assets as resolving
+	// to their targets.
+	Redirects Resolver
+}
+
+// Ref is one product image reference in a report.
+type Ref struct {
+	ProductID string `json:"productId"`
+	URL       string `json:"url"`
+	Key       string `json:"key,omitempty"`
+	// Target is where a redirected reference ends up.
+	Target string `json:"target,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Report is the result of a run. Redirected references resolve, but only
+// through the redirect table, so the catalog should be updated.
+type Report struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Source      string    `json:"source"`
+	Prefix      string    `json:"prefix"`
+	Products    int       `json:"products"`
+	References  int       `json:"references"`
+	Scanned     int       `json:"scanned"`
+	Orphans     []string  `json:"orphans"`
+	Dangling    []Ref     `json:"dangling"`
+	Redirected  []Ref     `json:"redirected"`
+}
+
+// Run checks refs against src.
+func Run(ctx context.Context, src Source, refs []db.ImageRef, opts Options) (Report, error) {
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.Source == "" {
+		opts.Source = SourceExport
+	}
+	rep := Report{
+		GeneratedAt: time.Now().UTC(),
+		Source:      opts.Source,
+		Prefix:      opts.Prefix,
+		References:  len(refs),
+		Orphans:     []string{},
+		Dangling:    []Ref{},
+		Redirected:  []Ref{},
+	}
+
+	products := make(map[string]bool)
+	referenced := make(map[string]bool)
+	exists := make(map[string]bool)
+	has := func(key string) bool {
+		ok, seen := exists[key]
+		if !seen {
+			ok = src.Exists(key)
+			exists[key] = ok
+		}
+		return ok
+	}
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return rep, err
+		}
+		products[ref.ProductID] = true
+		r := Ref{ProductID: ref.ProductID, URL: ref.URL}
+		key, err := KeyFor(ref.URL)
+		if err != nil {
+			r.Reason = err.Error()
+			rep.Dangling = append(rep.Dangling, r)
+			continue
+		}
+		r.Key = key
+		if has(key) {
+			referenced[key] = true
+			continue
+		}
+		if opts.Redirects != nil {
+			if target, _, _, ok := opts.Redirects.Resolve(key); ok && has(target) {
+				referenced[target] = true
+				r.Target = target
+				rep.Redirected = append(rep.Redirected, r)
+				continue
+			}
+		}
+		r.Reason = "not found"
+		rep.Dangling = append(rep.Dangling, r)
+	}
+	rep.Products = len(products)
+
+	keys, err := src.List(opts.Prefix)
+	if err != nil {
+		return rep, fmt.Errorf("list %s: %w", opts.Prefix, err)
+	}
+	rep.Scanned = len(keys)
+	for _, key := range keys {
+		if !referenced[key] {
+			rep.Orphans = append(rep.Orphans, key)
+		}
+	}
+	return rep, nil
+}
+
+// KeyFor extracts the asset key from an image URL: the path after
+// "/assets/" of an absolute or root-relative URL, or a bare key such as
+// "products/frozen/product-001.png". Query strings are ignored.
+func KeyFor(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("not a URL: %v", err)
+	}
+	p := u.Path
+	if u.IsAbs() || strings.HasPrefix(p, "/") {
+		_, after, ok := strings.Cut(p, "/assets/")
+		if !ok {
+			return "", fmt.Errorf("not an asset URL")
+		}
+		p = after
+	}
+	key, err := storage.NormalizeKey(p)
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Service runs reports for the API and keeps the latest, so lifecycle
+// cleanup can act on its orphans.
+type Service struct {
+	src       Source
+	redirects Resolver
+	query     func(ctx context.Context) ([]db.ImageRef, error)
+	prefix    string
+
+	mu   sync.Mutex
+	last *Report
+}
+
+// New returns a Service checking src. query, when set, loads the catalog
+// from a database; redirects may be nil.
+func New(src Source, redirects Resolver, query func(ctx context.Context) ([]db.ImageRef, error), prefix string) *Service {
+	return &Service{src: src, redirects: redirects, query: query, prefix: prefix}
+}
+
+// Run checks refs, which came from source, and remembers the report.
+// prefix overrides the service's default when set.
+func (s *Service) Run(ctx context.Context, refs []db.ImageRef, source, prefix string) (Report, error) {
+	if prefix == "" {
+		prefix = s.prefix
+	}
+	rep, err := Run(ctx, s.src, refs, Options{Prefix: prefix, Source: source, Redirects: s.redirects})
+	if err != nil {
+		return rep, err
+	}
+	s.mu.Lock()
+	s.last = &rep
+	s.mu.Unlock()
+	return rep, nil
+}
+
+// Last returns the most recent report.
+func (s *Service) Last() (Report, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		return Report{}, false
+	}
+	return *s.last, true
+}
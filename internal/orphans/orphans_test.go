@@ -0,0 +1,240 @@
+package orphans
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/storage"
+)
+
+type redirectStub map[string]string
+
+func (s redirectStub) Resolve(key string) (string, int, time.Time, bool) {
+	target, ok := s[key]
+	return target, http.StatusMovedPermanently, time.Time{}, ok
+}
+
+func newTestStore(t *testing.T) *storage.LocalStorage {
+	t.Helper()
+	store := storage.NewLocalStorage(t.TempDir())
+	for _, key := range []string{
+		"products/frozen/product-001.png",
+		"products/frozen/product-002.png",
+		"products/frozen/product-002-old.png",
+		"products/shellfish/product-003.jpg",
+		"ui/logo.svg",
+	} {
+		if err := store.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+const catalogCSV = `Product ID,Name,Image URL,Gallery Images,Product URL
+P-001,Cod,https://cdn.example.com/assets/products/frozen/product-001.png?w=400,,https://shop.example.com/p/1
+P-002,Haddock,/assets/products/frozen/product-002.png,products/frozen/moved.png|products/frozen/gone.png,
+P-004,Crab,https://shop.example.com/img/crab.png,,
+`
+
+func TestKeyFor(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://cdn.example.com/assets/products/a.png?v=2": "products/a.png",
+		"/assets/ui/logo.svg":                               "ui/logo.svg",
+		"products/frozen/product-001.png":                   "products/frozen/product-001.png",
+		"https://shop.example.com/img/crab.png":             "",
+		"/assets/../secret":                                 "",
+	} {
+		got, err := KeyFor(raw)
+		if want == "" {
+			if err == nil {
+				t.Errorf("KeyFor(%q) = %q, want error", raw, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("KeyFor(%q) = %q, %v, want %q", raw, got, err, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	refs, err := Parse([]byte(catalogCSV), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 5 || refs[0].ProductID != "P-001" || refs[2].URL != "products/frozen/moved.png" {
+		t.Errorf("CSV refs = %+v", refs)
+	}
+
+	refs, err = Parse([]byte(`{"products": [
+		{"sku": "P-001", "image": "products/frozen/product-001.png"},
+		{"sku": "P-002", "images": ["products/a.png", {"url": "/assets/products/b.png"}], "url_slug": "haddock"}
+	]}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, r := range refs {
+		urls = append(urls, r.ProductID+" "+r.URL)
+	}
+	want := []string{"P-001 products/frozen/product-001.png", "P-002 products/a.png", "P-002 /assets/products/b.png"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("JSON refs = %q, want %q", urls, want)
+	}
+
+	if _, err := Parse([]byte("name,price\nCod,3\n"), FormatCSV); err == nil {
+		t.Error("CSV without ID or image columns parsed")
+	}
+	if _, err := Parse([]byte(`[{"image": "a.png"}]`), FormatJSON); err == nil {
+		t.Error("JSON product without ID parsed")
+	}
+}
+
+func TestRun(t *testing.T) {
+	store := newTestStore(t)
+	refs, err := Parse([]byte(catalogCSV), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := Run(context.Background(), store, refs, Options{
+		Redirects: redirectStub{"products/frozen/moved.png": "products/frozen/product-002-old.png"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Products != 3 || rep.References != 5 || rep.Scanned != 4 {
+		t.Errorf("counts = %+v", rep)
+	}
+	if want := []string{"products/shellfish/product-003.jpg"}; !slices.Equal(rep.Orphans, want) {
+		t.Errorf("orphans = %q, want %q", rep.Orphans, want)
+	}
+	if len(rep.Dangling) != 2 || rep.Dangling[0].Key != "products/frozen/gone.png" || rep.Dangling[1].ProductID != "P-004" {
+		t.Errorf("dangling = %+v", rep.Dangling)
+	}
+	if len(rep.Redirected) != 1 || rep.Redirected[0].Target != "products/frozen/product-002-old.png" {
+		t.Errorf("redirected = %+v", rep.Redirected)
+	}
+
+	rep, err = Run(context.Background(), store, refs, Options{Prefix: "ui/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ui/logo.svg"}; !slices.Equal(rep.Orphans, want) || len(rep.Dangling) != 3 {
+		t.Errorf("ui/ report = %+v", rep)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	store := newTestStore(t)
+	query := func(context.Context) ([]db.ImageRef, error) {
+		return []db.ImageRef{{ProductID: "P-001", URL: "products/frozen/product-001.png"}}, nil
+	}
+	r := mux.NewRouter()
+	New(store, nil, query, DefaultPrefix).Register(r)
+
+	do := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/reports/orphans", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET before a run = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/reports/orphans", "text/plain", catalogCSV); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST text/plain = %d, want 400", rec.Code)
+	}
+
+	rec := do(http.MethodPost, "/api/v1/reports/orphans?prefix=products/frozen/", "text/csv; charset=utf-8", catalogCSV)
+	var rep Report
+	if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST csv = %d, %v", rec.Code, err)
+	}
+	if rep.Prefix != "products/frozen/" || rep.Source != SourceExport || len(rep.Orphans) != 1 || len(rep.Dangling) != 3 {
+		t.Errorf("csv report = %+v", rep)
+	}
+
+	rec = do(http.MethodPost, "/api/v1/reports/orphans?source=db", "", "")
+	if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST source=db = %d, %v", rec.Code, err)
+	}
+	if rep.Source != SourceDB || len(rep.Orphans) != 3 || len(rep.Dangling) != 0 {
+		t.Errorf("db report = %+v", rep)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/reports/orphans", "", "")
+	var last Report
+	if err := json.NewDecoder(rec.Body).Decode(&last); err != nil || rec.Code != http.StatusOK || len(last.Orphans) != 3 {
+		t.Errorf("GET last = %d, %+v, %v", rec.Code, last, err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	store := newTestStore(t)
+	catalog := filepath.Join(t.TempDir(), "catalog.csv")
+	if err := os.WriteFile(catalog, []byte(catalogCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := Env{
+		Source: store,
+		Query: func(_ context.Context, query string) ([]db.ImageRef, error) {
+			if query != "SELECT sku, image FROM catalog" {
+				t.Errorf("query = %q", query)
+			}
+			return []db.ImageRef{
+				{ProductID: "P-001", URL: "products/frozen/product-001.png"},
+				{ProductID: "P-002", URL: "products/frozen/product-002.png"},
+				{ProductID: "P-002", URL: "products/frozen/product-002-old.png"},
+				{ProductID: "P-003", URL: "products/shellfish/product-003.jpg"},
+			}, nil
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Command([]string{"-catalog", catalog}, env, &stdout, &stderr); code != ExitFindings {
+		t.Fatalf("exit = %d, stderr %s", code, stderr.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "orphan     products/shellfish/product-003.jpg") || !strings.Contains(out, "3 dangling") {
+		t.Errorf("text report:\n%s", out)
+	}
+
+	stdout.Reset()
+	if code := Command([]string{"-catalog", catalog, "-orphans"}, env, &stdout, &stderr); code != ExitFindings {
+		t.Fatalf("-orphans exit = %d", code)
+	}
+	if got := stdout.String(); got != "products/frozen/product-002-old.png\nproducts/shellfish/product-003.jpg\n" {
+		t.Errorf("-orphans output = %q", got)
+	}
+
+	stdout.Reset()
+	if code := Command([]string{"-db", "-query", "SELECT sku, image FROM catalog", "-json"}, env, &stdout, &stderr); code != ExitOK {
+		t.Fatalf("-db exit = %d, stderr %s", code, stderr.String())
+	}
+	var rep Report
+	if err := json.Unmarshal(stdout.Bytes(), &rep); err != nil || rep.Products != 3 || len(rep.Orphans) != 0 {
+		t.Errorf("-db -json = %+v, %v", rep, err)
+	}
+
+	for _, args := range [][]string{nil, {"-db", "-catalog", catalog}, {"-catalog", "missing.csv"}} {
+		if code := Command(args, env, &stdout, &stderr); code != ExitError {
+			t.Errorf("Command(%q) = %d, want %d", args, code, ExitError)
+		}
+	}
+}
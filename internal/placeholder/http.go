@@ -0,0 +1,46 @@
+package placeholder
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the placeholder generator:
+//
+//	GET /placeholder/{w}x{h}.svg?bg=e0f2fe&fg=0369a1&text=Atlantic+Salmon&icon=fish
+//	GET /placeholder/{w}x{h}.png?...
+func Register(r *mux.Router) {
+	r.HandleFunc("/placeholder/{w:[0-9]+}x{h:[0-9]+}.{ext:svg|png}", handle).Methods(http.MethodGet, http.MethodHead)
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	width, _ := strconv.Atoi(vars["w"])
+	height, _ := strconv.Atoi(vars["h"])
+	spec, err := ParseSpec(width, height, r.URL.Query())
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+
+	body, contentType := SVG(spec), "image/svg+xml"
+	if vars["ext"] == "png" {
+		if body, err = PNG(spec); err != nil {
+			api.Error(w, api.StatusFor(err), err.Error())
+			return
+		}
+		contentType = "image/png"
+	}
+	// The output depends only on the URL.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
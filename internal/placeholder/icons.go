@@ -0,0 +1,112 @@
+package placeholder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Icons are the drawings available via ?icon=. They are drawn in the
+// foreground colour, centred on the origin, for an 800x800 image.
+var Icons = map[string][]Shape{
+	// The fish of the bundled product placeholders.
+	"fish": {
+		{Path: ellipse(0, 0, 120, 60), Opacity: 0.3},
+		{Path: mustParsePath("M -100,0 Q -80,-40 0,-10 Q 80,-40 100,0 Q 80,40 0,10 Q -80,40 -100,0 Z"), Opacity: 0.5},
+		{Path: ellipse(-60, -5, 8, 8), Opacity: 1},
+		{Path: mustParsePath("M 100,0 L 140,-30 L 140,30 Z"), Opacity: 0.4},
+	},
+	"shell": {
+		{Path: ellipse(0, 0, 120, 90), Opacity: 0.3},
+		{Path: mustParsePath("M -90,30 Q -110,-70 0,-80 Q 110,-70 90,30 Z"), Opacity: 0.5},
+		{Path: mustParsePath("M -30,30 L 30,30 L 20,60 L -20,60 Z"), Opacity: 0.7},
+	},
+	"image": {
+		{Path: mustParsePath("M -120,-90 L 120,-90 L 120,90 L -120,90 Z"), Opacity: 0.3},
+		{Path: mustParsePath("M -100,70 L -30,-20 L 20,40 L 50,10 L 100,70 Z"), Opacity: 0.6},
+		{Path: ellipse(55, -45, 18, 18), Opacity: 0.6},
+	},
+}
+
+// Shape is one filled path of an icon.
+type Shape struct {
+	Path    Path
+	Opacity float64
+}
+
+// Path is a list of absolute path commands.
+type Path []Cmd
+
+// Cmd is one path command: 'M' and 'L' take a point, 'Q' a control point
+// and a point, 'C' two control points and a point, 'Z' nothing.
+type Cmd struct {
+	Op  byte
+	Pts []float64
+}
+
+var cmdArgs = map[byte]int{'M': 2, 'L': 2, 'Q': 4, 'C': 6, 'Z': 0}
+
+// parsePath parses SVG path data limited to absolute M, L, Q, C and Z
+// commands with explicit letters.
+func parsePath(d string) (Path, error) {
+	var p Path
+	fields := strings.Fields(strings.ReplaceAll(d, ",", " "))
+	for i := 0; i < len(fields); {
+		f := fields[i]
+		n, ok := cmdArgs[f[0]]
+		if len(f) != 1 || !ok {
+			return nil, fmt.Errorf("unsupported path command %q", f)
+		}
+		if i+n >= len(fields) {
+			return nil, fmt.Errorf("path command %q needs %d numbers", f, n)
+		}
+		c := Cmd{Op: f[0]}
+		for _, s := range fields[i+1 : i+1+n] {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("path command %q: %w", f, err)
+			}
+			c.Pts = append(c.Pts, v)
+		}
+		p = append(p, c)
+		i += 1 + n
+	}
+	return p, nil
+}
+
+func mustParsePath(d string) Path {
+	p, err := parsePath(d)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// D returns the path as SVG path data.
+func (p Path) D() string {
+	parts := make([]string, 0, len(p))
+	for _, c := range p {
+		part := string(c.Op)
+		for j := 0; j < len(c.Pts); j += 2 {
+			part += " " + round2(c.Pts[j]) + "," + round2(c.Pts[j+1])
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// kappa places cubic Bézier control points to approximate a quarter ellipse.
+const kappa = 0.5522847498
+
+// ellipse returns a closed path approximating an ellipse.
+func ellipse(cx, cy, rx, ry float64) Path {
+	kx, ky := rx*kappa, ry*kappa
+	return Path{
+		{'M', []float64{cx + rx, cy}},
+		{'C', []float64{cx + rx, cy + ky, cx + kx, cy + ry, cx, cy + ry}},
+		{'C', []float64{cx - kx, cy + ry, cx - rx, cy + ky, cx - rx, cy}},
+		{'C', []float64{cx - rx, cy - ky, cx - kx, cy - ry, cx, cy - ry}},
+		{'C', []float64{cx + kx, cy - ry, cx + rx, cy - ky, cx + rx, cy}},
+		{'Z', nil},
+	}
+}
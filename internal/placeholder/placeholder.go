@@ -0,0 +1,150 @@
+// Package placeholder renders parameterised placeholder images — a
+// background, an optional icon and a caption — as SVG or PNG, so missing
+// product images in dev and staging need no hand-made files.
+package placeholder
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"codlocker-assets/internal/http/api"
+)
+
+const (
+	// MaxSide bounds the width and height of any placeholder.
+	MaxSide = 4000
+	// MaxPNGPixels bounds the area of PNG placeholders, which are
+	// rasterised in memory.
+	MaxPNGPixels = 2000 * 2000
+	// MaxText bounds the caption length in characters.
+	MaxText = 80
+
+	// reference is the side of the square layout the icons are drawn
+	// for; everything is scaled from it, matching the bundled
+	// 800x800 product placeholders.
+	reference = 800
+)
+
+// Defaults match the bundled fresh-fish placeholders.
+var (
+	DefaultBackground = color.RGBA{0xe0, 0xf2, 0xfe, 0xff}
+	DefaultForeground = color.RGBA{0x03, 0x69, 0xa1, 0xff}
+)
+
+// Spec describes a placeholder.
+type Spec struct {
+	Width, Height int
+	Background    color.RGBA
+	Foreground    color.RGBA
+	// Text is the caption; "" draws none.
+	Text string
+	// Icon names an entry of Icons; "" draws none.
+	Icon string
+}
+
+// ParseSpec validates a w x h placeholder and reads the bg, fg, text and
+// icon query parameters. Colours are hex (#rgb, #rrggbb, with or without
+// the #). Without text, the caption is the size, e.g. "640 x 480".
+func ParseSpec(width, height int, q url.Values) (Spec, error) {
+	if width < 1 || height < 1 || width > MaxSide || height > MaxSide {
+		return Spec{}, fmt.Errorf("%w: width and height must be between 1 and %d", api.ErrBadRequest, MaxSide)
+	}
+	s := Spec{
+		Width:      width,
+		Height:     height,
+		Background: DefaultBackground,
+		Foreground: DefaultForeground,
+		Text:       fmt.Sprintf("%d x %d", width, height),
+		Icon:       q.Get("icon"),
+	}
+	for _, c := range []struct {
+		name string
+		dst  *color.RGBA
+	}{{"bg", &s.Background}, {"fg", &s.Foreground}} {
+		if v := q.Get(c.name); v != "" {
+			parsed, err := ParseColor(v)
+			if err != nil {
+				return Spec{}, fmt.Errorf("%w: %s: %v", api.ErrBadRequest, c.name, err)
+			}
+			*c.dst = parsed
+		}
+	}
+	if q.Has("text") {
+		s.Text = strings.TrimSpace(q.Get("text"))
+	}
+	if utf8.RuneCountInString(s.Text) > MaxText {
+		return Spec{}, fmt.Errorf("%w: text is longer than %d characters", api.ErrBadRequest, MaxText)
+	}
+	if _, ok := Icons[s.Icon]; s.Icon != "" && !ok {
+		return Spec{}, fmt.Errorf("%w: unknown icon %q (have %s)", api.ErrBadRequest, s.Icon, strings.Join(IconNames(), ", "))
+	}
+	return s, nil
+}
+
+// ParseColor parses a hex colour: "#rgb", "#rrggbb", "rgb" or "rrggbb".
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", s)
+	}
+	return color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 0xff}, nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// layout positions the icon and caption. Coordinates are in output pixels;
+// Scale maps icon coordinates (drawn for the 800x800 reference) to them.
+type layout struct {
+	Scale        float64
+	IconX, IconY float64
+	TextX, TextY float64
+	FontSize     float64
+}
+
+// fontAdvance estimates the advance of a bold sans-serif character as a
+// fraction of the font size, to keep captions inside the image.
+const fontAdvance = 0.6
+
+func (s Spec) layout() layout {
+	w, h := float64(s.Width), float64(s.Height)
+	l := layout{Scale: math.Min(w, h) / reference, IconX: w / 2, TextX: w / 2}
+	l.FontSize = 52 * l.Scale
+	if n := utf8.RuneCountInString(s.Text); n > 0 {
+		l.FontSize = math.Min(l.FontSize, 0.9*w/(fontAdvance*float64(n)))
+	}
+	if s.Icon != "" {
+		// As in the bundled files: icon centre at 35%, caption baseline at 57.5%.
+		l.IconY = h * 0.35
+		l.TextY = h * 0.575
+	} else {
+		l.TextY = h/2 + l.FontSize*0.35
+	}
+	return l
+}
+
+// round2 formats v with at most two decimals.
+func round2(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// IconNames lists the available icons, sorted.
+func IconNames() []string {
+	names := make([]string, 0, len(Icons))
+	for name := range Icons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
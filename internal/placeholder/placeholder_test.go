@@ -0,0 +1,128 @@
+package placeholder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+func TestParseSpec(t *testing.T) {
+	s, err := ParseSpec(640, 480, url.Values{"bg": {"#fce7f3"}, "fg": {"be185d"}, "icon": {"fish"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Background != (color.RGBA{0xfc, 0xe7, 0xf3, 0xff}) || s.Foreground != (color.RGBA{0xbe, 0x18, 0x5d, 0xff}) {
+		t.Errorf("colours = %v, %v", s.Background, s.Foreground)
+	}
+	if s.Text != "640 x 480" {
+		t.Errorf("default text = %q", s.Text)
+	}
+	if s, _ := ParseSpec(10, 10, url.Values{"text": {""}}); s.Text != "" {
+		t.Errorf("explicit empty text = %q, want none", s.Text)
+	}
+
+	for name, tc := range map[string]struct {
+		w, h int
+		q    url.Values
+	}{
+		"zero width":   {0, 10, nil},
+		"too tall":     {10, MaxSide + 1, nil},
+		"bad colour":   {10, 10, url.Values{"bg": {"blue"}}},
+		"bad icon":     {10, 10, url.Values{"icon": {"whale"}}},
+		"long text":    {10, 10, url.Values{"text": {strings.Repeat("x", MaxText+1)}}},
+		"short colour": {10, 10, url.Values{"fg": {"#12345"}}},
+	} {
+		if _, err := ParseSpec(tc.w, tc.h, tc.q); !errors.Is(err, api.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	s, _ := ParseSpec(800, 800, url.Values{"icon": {"fish"}, "text": {"Fish & <Chips>"}})
+	out := SVG(s)
+	if err := xml.Unmarshal(out, new(struct{})); err != nil {
+		t.Fatalf("SVG is not well-formed: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		`<rect width="800" height="800" fill="#e0f2fe"/>`,
+		`transform="translate(400, 280) scale(1)"`,
+		`M -100,0 Q -80,-40 0,-10`,
+		`Fish &amp; &lt;Chips&gt;`,
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("SVG lacks %q:\n%s", want, out)
+		}
+	}
+
+	// Long captions shrink to fit.
+	s, _ = ParseSpec(200, 800, url.Values{"text": {strings.Repeat("W", 40)}})
+	if l := s.layout(); l.FontSize*fontAdvance*40 > 0.9*200+0.01 {
+		t.Errorf("font size %v overflows a 200px wide image", l.FontSize)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	s, _ := ParseSpec(320, 200, url.Values{"icon": {"image"}, "fg": {"000"}})
+	data, err := PNG(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 200 {
+		t.Fatalf("PNG = %v, want 320x200", b)
+	}
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != DefaultBackground {
+		t.Errorf("corner = %v, want background %v", got, DefaultBackground)
+	}
+	// The icon's frame darkens the area around its centre.
+	if r, _, _, _ := img.At(160, int(200*0.35)).RGBA(); r>>8 >= uint32(DefaultBackground.R) {
+		t.Errorf("icon centre not drawn: %v", img.At(160, 70))
+	}
+
+	big, _ := ParseSpec(MaxSide, MaxSide, nil)
+	if _, err := PNG(big); !errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("oversized PNG err = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	r := mux.NewRouter()
+	Register(r)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/placeholder/640x480.svg?icon=shell&text=King+Prawns")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("svg = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "King Prawns") {
+		t.Errorf("svg body lacks caption: %s", rec.Body)
+	}
+	if rec := get("/placeholder/64x64.png"); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("png = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := get("/placeholder/64x64.svg?bg=nope"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad colour status = %d, want 400", rec.Code)
+	}
+	if rec := get("/placeholder/64x64.gif"); rec.Code != http.StatusNotFound {
+		t.Errorf("gif status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package placeholder
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// PNG renders s as a PNG with the same layout as SVG. The caption is set
+// in a scaled bitmap font that covers ASCII only; other characters are
+// drawn as replacement glyphs.
+func PNG(s Spec) ([]byte, error) {
+	if s.Width*s.Height > MaxPNGPixels {
+		return nil, fmt.Errorf("%w: PNG placeholders are limited to %d pixels", api.ErrBadRequest, MaxPNGPixels)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, s.Width, s.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(s.Background), image.Point{}, draw.Src)
+
+	l := s.layout()
+	for _, sh := range Icons[s.Icon] {
+		z := vector.NewRasterizer(s.Width, s.Height)
+		at := func(i int, pts []float64) (float32, float32) {
+			return float32(l.IconX + pts[i]*l.Scale), float32(l.IconY + pts[i+1]*l.Scale)
+		}
+		for _, c := range sh.Path {
+			switch c.Op {
+			case 'M':
+				z.MoveTo(at(0, c.Pts))
+			case 'L':
+				z.LineTo(at(0, c.Pts))
+			case 'Q':
+				bx, by := at(0, c.Pts)
+				cx, cy := at(2, c.Pts)
+				z.QuadTo(bx, by, cx, cy)
+			case 'C':
+				bx, by := at(0, c.Pts)
+				cx, cy := at(2, c.Pts)
+				dx, dy := at(4, c.Pts)
+				z.CubeTo(bx, by, cx, cy, dx, dy)
+			case 'Z':
+				z.ClosePath()
+			}
+		}
+		fill := color.NRGBA{s.Foreground.R, s.Foreground.G, s.Foreground.B, uint8(sh.Opacity * 0xff)}
+		z.Draw(img, img.Bounds(), image.NewUniform(fill), image.Point{})
+	}
+
+	if s.Text != "" {
+		drawText(img, s.Text, s.Foreground, l)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode placeholder: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawText sets text centred on l.TextX with its baseline at l.TextY,
+// scaling the 13px bitmap font to l.FontSize.
+func drawText(dst draw.Image, text string, fg color.RGBA, l layout) {
+	face := basicfont.Face7x13
+	d := &font.Drawer{Src: image.NewUniform(fg), Face: face}
+	w := d.MeasureString(text).Ceil()
+	glyphs := image.NewNRGBA(image.Rect(0, 0, w, face.Height))
+	d.Dst, d.Dot = glyphs, fixed.P(0, face.Ascent)
+	d.DrawString(text)
+
+	scale := l.FontSize / float64(face.Height)
+	tw, th := float64(w)*scale, float64(face.Height)*scale
+	x0 := l.TextX - tw/2
+	y0 := l.TextY - float64(face.Ascent)*scale
+	target := image.Rect(int(x0), int(y0), int(x0+tw), int(y0+th))
+	draw.ApproxBiLinear.Scale(dst, target, glyphs, glyphs.Bounds(), draw.Over, nil)
+}
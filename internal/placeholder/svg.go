@@ -0,0 +1,64 @@
+package placeholder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strconv"
+	"text/template"
+)
+
+var svgTemplate = template.Must(template.New("placeholder.svg").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.W}}" height="{{.H}}" viewBox="0 0 {{.W}} {{.H}}">
+  <rect width="{{.W}}" height="{{.H}}" fill="{{.Bg}}"/>
+{{- if .Shapes}}
+  <g transform="translate({{.IconX}}, {{.IconY}}) scale({{.Scale}})" fill="{{.Fg}}">
+{{- range .Shapes}}
+    <path d="{{.D}}" opacity="{{.Opacity}}"/>
+{{- end}}
+  </g>
+{{- end}}
+{{- if .Text}}
+  <text x="{{.TextX}}" y="{{.TextY}}" font-family="Arial, Helvetica, sans-serif"
+        font-size="{{.FontSize}}" font-weight="700" fill="{{.Fg}}"
+        text-anchor="middle">{{.Text}}</text>
+{{- end}}
+</svg>
+`))
+
+// svgShape is a Shape with its path data rendered.
+type svgShape struct {
+	D       string
+	Opacity string
+}
+
+// SVG renders s as an SVG document.
+func SVG(s Spec) []byte {
+	l := s.layout()
+	data := struct {
+		W, H                   int
+		Bg, Fg                 string
+		Shapes                 []svgShape
+		IconX, IconY, Scale    string
+		Text                   string
+		TextX, TextY, FontSize string
+	}{
+		W: s.Width, H: s.Height,
+		Bg: hexColor(s.Background), Fg: hexColor(s.Foreground),
+		IconX: round2(l.IconX), IconY: round2(l.IconY),
+		Scale: strconv.FormatFloat(math.Round(l.Scale*1e4)/1e4, 'f', -1, 64),
+		TextX: round2(l.TextX), TextY: round2(l.TextY), FontSize: round2(l.FontSize),
+	}
+	for _, sh := range Icons[s.Icon] {
+		data.Shapes = append(data.Shapes, svgShape{D: sh.Path.D(), Opacity: round2(sh.Opacity)})
+	}
+	var text bytes.Buffer
+	_ = xml.EscapeText(&text, []byte(s.Text))
+	data.Text = text.String()
+
+	var buf bytes.Buffer
+	if err := svgTemplate.Execute(&buf, data); err != nil {
+		panic(err) // the template and its data are fixed
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,71 @@
+package redirects
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the redirect table endpoints. {source} is a key or a
+// prefix ending in "*":
+//
+//	GET    /api/v1/redirects            database and file rules
+//	GET    /api/v1/redirects/{source}   one database rule
+//	PUT    /api/v1/redirects/{source}   create or replace {"target","status","deprecated"}
+//	DELETE /api/v1/redirects/{source}   remove a database rule
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/redirects", s.handleList).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/redirects/{source:.+}", s.handleGet).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/redirects/{source:.+}", s.handlePut).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/redirects/{source:.+}", s.handleDelete).Methods(http.MethodDelete)
+}
+
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	rules, file, err := s.List(r.Context())
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	if rules == nil {
+		rules = []db.Redirect{}
+	}
+	if file == nil {
+		file = []db.Redirect{}
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"redirects": rules, "fileRedirects": file})
+}
+
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	rd, err := s.Get(r.Context(), mux.Vars(r)["source"])
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rd)
+}
+
+func (s *Service) handlePut(w http.ResponseWriter, r *http.Request) {
+	var rd db.Redirect
+	if err := api.DecodeJSON(w, r, &rd); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	rd.Source = mux.Vars(r)["source"]
+	rd, err := s.Put(r.Context(), rd, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rd)
+}
+
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.Delete(r.Context(), mux.Vars(r)["source"]); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,292 @@
+// Package redirects maps moved or renamed asset keys to their new keys,
+// either as HTTP redirects or as transparent aliases, so URLs cached by
+// clients keep working.
+package redirects
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// StatusAlias marks a rule that serves the target under the old URL.
+const StatusAlias = 200
+
+// maxHops bounds how far Put follows redirects when checking for loops.
+const maxHops = 10
+
+// Index stores redirects. *db.RedirectRepo implements it.
+type Index interface {
+	List(ctx context.Context) ([]db.Redirect, error)
+	Get(ctx context.Context, source string) (db.Redirect, error)
+	Put(ctx context.Context, rd db.Redirect) error
+	Delete(ctx context.Context, source string) error
+}
+
+// table is an immutable lookup snapshot.
+type table struct {
+	exact map[string]db.Redirect
+	// prefixes holds prefix rules with the "*" stripped from source and
+	// target, longest source first.
+	prefixes []db.Redirect
+}
+
+func newTable(rules ...[]db.Redirect) *table {
+	t := &table{exact: make(map[string]db.Redirect)}
+	bySource := make(map[string]db.Redirect)
+	for _, set := range rules {
+		for _, rd := range set {
+			bySource[rd.Source] = rd
+		}
+	}
+	for _, rd := range bySource {
+		if stem, ok := strings.CutSuffix(rd.Source, "*"); ok {
+			rd.Source, rd.Target = stem, strings.TrimSuffix(rd.Target, "*")
+			t.prefixes = append(t.prefixes, rd)
+		} else {
+			t.exact[rd.Source] = rd
+		}
+	}
+	sort.Slice(t.prefixes, func(i, j int) bool { return len(t.prefixes[i].Source) > len(t.prefixes[j].Source) })
+	return t
+}
+
+func (t *table) resolve(key string) (db.Redirect, bool) {
+	if rd, ok := t.exact[key]; ok {
+		return rd, true
+	}
+	for _, rd := range t.prefixes {
+		if rest, ok := strings.CutPrefix(key, rd.Source); ok {
+			rd.Target += rest
+			return rd, true
+		}
+	}
+	return db.Redirect{}, false
+}
+
+// Service keeps an in-memory copy of the redirect table for the asset
+// handler. Rules from the database override file rules with the same
+// source; the file rules keep working while the database is unreachable.
+type Service struct {
+	index Index
+	file  []db.Redirect
+	now   func() time.Time
+
+	mu    sync.RWMutex
+	table *table
+}
+
+// New returns a Service serving the file rules until Reload succeeds.
+func New(index Index, file []db.Redirect) *Service {
+	return &Service{index: index, file: file, now: time.Now, table: newTable(file)}
+}
+
+// LoadFile reads a JSON array of redirects ({"source","target","status",
+// "deprecated"}) and validates them.
+func LoadFile(path string) ([]db.Redirect, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []db.Redirect
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range rules {
+		if err := Validate(&rules[i]); err != nil {
+			return nil, fmt.Errorf("%s: redirect %d: %w", path, i, err)
+		}
+	}
+	return rules, nil
+}
+
+// Validate normalises rd and checks its shape: a key or "prefix*" source,
+// a target of the same kind, and status 200 (alias), 301 or 308; 0 means
+// 301.
+func Validate(rd *db.Redirect) error {
+	srcStem, srcPrefix := strings.CutSuffix(rd.Source, "*")
+	dstStem, dstPrefix := strings.CutSuffix(rd.Target, "*")
+	if srcPrefix != dstPrefix {
+		return fmt.Errorf("%w: source and target must both end in * or neither", api.ErrBadRequest)
+	}
+	var err error
+	if srcStem, err = normalizeStem(srcStem, srcPrefix); err != nil {
+		return fmt.Errorf("%w: source: %v", api.ErrBadRequest, err)
+	}
+	if dstStem, err = normalizeStem(dstStem, dstPrefix); err != nil {
+		return fmt.Errorf("%w: target: %v", api.ErrBadRequest, err)
+	}
+	if srcStem == dstStem {
+		return fmt.Errorf("%w: source and target are the same", api.ErrBadRequest)
+	}
+	if rd.Status == 0 {
+		rd.Status = 301
+	}
+	switch rd.Status {
+	case StatusAlias, 301, 308:
+	default:
+		return fmt.Errorf("%w: status must be 200 (alias), 301 or 308", api.ErrBadRequest)
+	}
+	if srcPrefix && rd.Status != StatusAlias && strings.HasPrefix(dstStem, srcStem) {
+		return fmt.Errorf("%w: target is inside the redirected prefix", api.ErrBadRequest)
+	}
+	rd.Source, rd.Target = srcStem, dstStem
+	if srcPrefix {
+		rd.Source, rd.Target = srcStem+"*", dstStem+"*"
+	}
+	return nil
+}
+
+// normalizeStem normalises a key, or the part of a prefix before the "*".
+// A prefix may be empty or end in "/".
+func normalizeStem(stem string, prefix bool) (string, error) {
+	if prefix && stem == "" {
+		return "", nil
+	}
+	slash := prefix && strings.HasSuffix(stem, "/")
+	norm, err := storage.NormalizeKey(stem)
+	if err != nil {
+		return "", err
+	}
+	if slash {
+		norm += "/"
+	}
+	return norm, nil
+}
+
+// Resolve returns the target of the rule matching key, with the rest of
+// the key appended for prefix rules. It implements assets.Redirector.
+func (s *Service) Resolve(key string) (target string, status int, deprecated time.Time, ok bool) {
+	s.mu.RLock()
+	t := s.table
+	s.mu.RUnlock()
+	rd, ok := t.resolve(key)
+	if !ok {
+		return "", 0, time.Time{}, false
+	}
+	if rd.Deprecated != nil {
+		deprecated = *rd.Deprecated
+	}
+	return rd.Target, rd.Status, deprecated, true
+}
+
+// Reload rebuilds the lookup table from the file rules and the database.
+// On error the previous table stays in use.
+func (s *Service) Reload(ctx context.Context) error {
+	rules, err := s.index.List(ctx)
+	if err != nil {
+		return err
+	}
+	t := newTable(s.file, rules)
+	s.mu.Lock()
+	s.table = t
+	s.mu.Unlock()
+	return nil
+}
+
+// Run reloads the table every interval until ctx is cancelled, picking up
+// changes made through other instances.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := s.Reload(ctx); err != nil {
+				logger.Errorf("[redirects] reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// List returns the database rules and the file rules.
+func (s *Service) List(ctx context.Context) ([]db.Redirect, []db.Redirect, error) {
+	rules, err := s.index.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rules, s.file, nil
+}
+
+// Get returns the database rule for source.
+func (s *Service) Get(ctx context.Context, source string) (db.Redirect, error) {
+	return s.index.Get(ctx, source)
+}
+
+// Put validates and stores rd, refusing rules that would make clients
+// loop between redirects.
+func (s *Service) Put(ctx context.Context, rd db.Redirect, actor string) (db.Redirect, error) {
+	if err := Validate(&rd); err != nil {
+		return db.Redirect{}, err
+	}
+	if err := s.Reload(ctx); err != nil {
+		return db.Redirect{}, err
+	}
+	s.mu.RLock()
+	current := s.table
+	s.mu.RUnlock()
+	if loops(newTable(s.file, current.rules(), []db.Redirect{rd}), rd) {
+		return db.Redirect{}, fmt.Errorf("%w: %s would create a redirect loop", api.ErrBadRequest, rd.Source)
+	}
+
+	rd.UpdatedAt, rd.UpdatedBy = s.now().UTC(), actor
+	if err := s.index.Put(ctx, rd); err != nil {
+		return db.Redirect{}, err
+	}
+	return rd, s.Reload(ctx)
+}
+
+// Delete removes the database rule for source.
+func (s *Service) Delete(ctx context.Context, source string) error {
+	if err := s.index.Delete(ctx, source); err != nil {
+		return err
+	}
+	return s.Reload(ctx)
+}
+
+// rules returns the rules of t in their stored form.
+func (t *table) rules() []db.Redirect {
+	out := make([]db.Redirect, 0, len(t.exact)+len(t.prefixes))
+	for _, rd := range t.exact {
+		out = append(out, rd)
+	}
+	for _, rd := range t.prefixes {
+		rd.Source, rd.Target = rd.Source+"*", rd.Target+"*"
+		out = append(out, rd)
+	}
+	return out
+}
+
+// loops reports whether following redirects from rd's target in t comes
+// back to a key redirected by rd, or does not settle within maxHops.
+// Aliases end a chain: the handler serves their target without
+// re-resolving it.
+func loops(t *table, rd db.Redirect) bool {
+	if rd.Status == StatusAlias {
+		return false
+	}
+	key := rd.Target
+	if stem, ok := strings.CutSuffix(key, "*"); ok {
+		key = stem + "probe" // any key under the target prefix
+	}
+	for range maxHops {
+		next, ok := t.resolve(key)
+		if !ok || next.Status == StatusAlias {
+			return false
+		}
+		key = next.Target
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package redirects
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+)
+
+// memIndex is an in-memory Index.
+type memIndex struct {
+	mu   sync.Mutex
+	rows map[string]db.Redirect
+	err  error
+}
+
+func newMemIndex() *memIndex { return &memIndex{rows: make(map[string]db.Redirect)} }
+
+func (m *memIndex) List(context.Context) ([]db.Redirect, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	var out []db.Redirect
+	for _, rd := range m.rows {
+		out = append(out, rd)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out, nil
+}
+
+func (m *memIndex) Get(_ context.Context, source string) (db.Redirect, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rd, ok := m.rows[source]
+	if !ok {
+		return db.Redirect{}, db.ErrNotFound
+	}
+	return rd, nil
+}
+
+func (m *memIndex) Put(_ context.Context, rd db.Redirect) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rows[rd.Source] = rd
+	return nil
+}
+
+func (m *memIndex) Delete(_ context.Context, source string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rows[source]; !ok {
+		return db.ErrNotFound
+	}
+	delete(m.rows, source)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	rd := db.Redirect{Source: "products/electronics/*", Target: "products/gadgets/*"}
+	if err := Validate(&rd); err != nil || rd.Status != 301 {
+		t.Fatalf("Validate = %+v, %v", rd, err)
+	}
+	for name, bad := range map[string]db.Redirect{
+		"mixed kinds":   {Source: "products/*", Target: "catalog/x.svg"},
+		"same":          {Source: "a.svg", Target: "a.svg"},
+		"traversal":     {Source: "a.svg", Target: "../b.svg"},
+		"bad status":    {Source: "a.svg", Target: "b.svg", Status: 302},
+		"inside prefix": {Source: "products/*", Target: "products/v2/*", Status: 308},
+	} {
+		if err := Validate(&bad); !errors.Is(err, api.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+	alias := db.Redirect{Source: "products/*", Target: "products/v2/*", Status: StatusAlias}
+	if err := Validate(&alias); err != nil {
+		t.Errorf("alias into its own prefix should be allowed: %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dep := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	file := []db.Redirect{
+		{Source: "products/electronics/*", Target: "products/gadgets/*", Status: 301},
+		{Source: "logo.jpg", Target: "ui/logo.svg", Status: 301},
+	}
+	index := newMemIndex()
+	index.rows["products/electronics/product-001.jpg"] = db.Redirect{
+		Source: "products/electronics/product-001.jpg", Target: "products/gadgets/phone.svg", Status: 308, Deprecated: &dep,
+	}
+	index.rows["logo.jpg"] = db.Redirect{Source: "logo.jpg", Target: "ui/logo.svg", Status: StatusAlias}
+	s := New(index, file)
+
+	if target, status, _, ok := s.Resolve("logo.jpg"); !ok || status != 301 || target != "ui/logo.svg" {
+		t.Errorf("before Reload: logo.jpg = %s %d %v, want the file rule", target, status, ok)
+	}
+	if err := s.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, target string
+		status      int
+		deprecated  time.Time
+	}{
+		{"products/electronics/product-001.jpg", "products/gadgets/phone.svg", 308, dep},
+		{"products/electronics/tv/product-002.jpg", "products/gadgets/tv/product-002.jpg", 301, time.Time{}},
+		{"logo.jpg", "ui/logo.svg", StatusAlias, time.Time{}}, // database overrides file
+	}
+	for _, tt := range tests {
+		target, status, deprecated, ok := s.Resolve(tt.key)
+		if !ok || target != tt.target || status != tt.status || !deprecated.Equal(tt.deprecated) {
+			t.Errorf("Resolve(%s) = %s %d %v %v", tt.key, target, status, deprecated, ok)
+		}
+	}
+	if _, _, _, ok := s.Resolve("products/frozen/product-001.jpg"); ok {
+		t.Errorf("unrelated key should not resolve")
+	}
+
+	// A failed reload keeps the last table.
+	index.err = errors.New("database down")
+	if err := s.Reload(context.Background()); err == nil {
+		t.Fatal("Reload should fail")
+	}
+	if _, status, _, _ := s.Resolve("logo.jpg"); status != StatusAlias {
+		t.Errorf("table lost after failed reload")
+	}
+}
+
+func TestPutLoops(t *testing.T) {
+	ctx := context.Background()
+	s := New(newMemIndex(), nil)
+	if _, err := s.Put(ctx, db.Redirect{Source: "a.svg", Target: "b.svg"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, db.Redirect{Source: "b.svg", Target: "a.svg"}, "alice"); !errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("loop err = %v, want ErrBadRequest", err)
+	}
+	if _, err := s.Put(ctx, db.Redirect{Source: "b.svg", Target: "a.svg", Status: StatusAlias}, "alice"); err != nil {
+		t.Errorf("alias back to a redirect source ends the chain: %v", err)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redirects.json")
+	_ = os.WriteFile(path, []byte(`[{"source":"old/*","target":"new/*","status":308}]`), 0o644)
+	rules, err := LoadFile(path)
+	if err != nil || len(rules) != 1 || rules[0].Status != 308 {
+		t.Fatalf("LoadFile = %+v, %v", rules, err)
+	}
+	_ = os.WriteFile(path, []byte(`[{"source":"old/*","target":"new.svg"}]`), 0o644)
+	if _, err := LoadFile(path); err == nil {
+		t.Errorf("LoadFile should reject a mixed rule")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	s := New(newMemIndex(), []db.Redirect{{Source: "x.jpg", Target: "x.svg", Status: 301}})
+	r := mux.NewRouter()
+	s.Register(r)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", "carol")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/api/v1/redirects/products/electronics/*",
+		`{"target":"products/gadgets/*","status":308,"deprecated":"2026-06-01T00:00:00Z"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", rec.Code, rec.Body)
+	}
+	if _, status, _, ok := s.Resolve("products/electronics/a.svg"); !ok || status != 308 {
+		t.Errorf("PUT should take effect immediately")
+	}
+	if rec := do(http.MethodPut, "/api/v1/redirects/a.svg", `{"target":"../etc"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad PUT = %d, want 400", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/redirects", "")
+	var listing struct {
+		Redirects     []db.Redirect `json:"redirects"`
+		FileRedirects []db.Redirect `json:"fileRedirects"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || len(listing.Redirects) != 1 || len(listing.FileRedirects) != 1 {
+		t.Fatalf("list = %+v, %v", listing, err)
+	}
+	if got := listing.Redirects[0]; got.UpdatedBy != "carol" || got.Deprecated == nil {
+		t.Errorf("stored rule = %+v", got)
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/redirects/products/electronics/*", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET = %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/v1/redirects/products/electronics/*", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE = %d", rec.Code)
+	}
+	if _, _, _, ok := s.Resolve("products/electronics/a.svg"); ok {
+		t.Errorf("deleted rule still resolves")
+	}
+	if rec := do(http.MethodDelete, "/api/v1/redirects/products/electronics/*", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", rec.Code)
+	}
+}
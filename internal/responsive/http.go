@@ -0,0 +1,49 @@
+package responsive
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// DefaultWidths are used when a request names none.
+const DefaultWidths = "320,640,1280"
+
+// Register mounts the responsive image endpoint:
+//
+//	GET /api/v1/assets/{key}/responsive?widths=320,640,1280&formats=png,jpeg
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/assets/{key:.+}/responsive", s.handleBuild).Methods(http.MethodGet)
+}
+
+func (s *Service) handleBuild(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	rawWidths := q.Get("widths")
+	if rawWidths == "" {
+		rawWidths = DefaultWidths
+	}
+	widths, err := ParseWidths(rawWidths)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	var formats []string
+	if f := q.Get("formats"); f != "" {
+		formats = strings.Split(f, ",")
+	}
+
+	set, err := s.Build(r.Context(), key, widths, formats)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, set)
+}
@@ -0,0 +1,79 @@
+// Package responsive names and discovers width variants of raster assets.
+//
+// A width variant sits next to its original with the width before the
+// extension: products/frozen/product-001@640w.jpg is the 640px-wide
+// rendition of products/frozen/product-001.jpg.
+package responsive
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codlocker-assets/internal/storage"
+)
+
+// Variant is one width rendition of an asset.
+type Variant struct {
+	Key   string `json:"key"`
+	Width int    `json:"width"`
+}
+
+// VariantKey returns the key of key's width-wide variant.
+func VariantKey(key string, width int) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "@" + strconv.Itoa(width) + "w" + ext
+}
+
+// ParseVariantKey splits a variant key into its original key and width.
+func ParseVariantKey(key string) (original string, width int, ok bool) {
+	ext := path.Ext(key)
+	stem := strings.TrimSuffix(key, ext)
+	at := strings.LastIndexByte(stem, '@')
+	if at < 0 || !strings.HasSuffix(stem, "w") {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(stem[at+1 : len(stem)-1])
+	if err != nil || n <= 0 {
+		return "", 0, false
+	}
+	return stem[:at] + ext, n, true
+}
+
+// Find lists the width variants of key present in store, narrowest first.
+func Find(store storage.Lister, key string) ([]Variant, error) {
+	ext := path.Ext(key)
+	keys, err := store.List(strings.TrimSuffix(key, ext) + "@")
+	if err != nil {
+		return nil, err
+	}
+	var out []Variant
+	for _, k := range keys {
+		if orig, w, ok := ParseVariantKey(k); ok && orig == key {
+			out = append(out, Variant{Key: k, Width: w})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Width < out[j].Width })
+	return out, nil
+}
+
+// Srcset renders variants as an HTML srcset attribute value, mapping each
+// key to a URL with urlFor.
+func Srcset(variants []Variant, urlFor func(key string) string) string {
+	parts := make([]string, len(variants))
+	for i, v := range variants {
+		parts[i] = urlFor(v.Key) + " " + strconv.Itoa(v.Width) + "w"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// URLFor returns a function mapping keys to asset URLs under base, e.g.
+// "https://cdn.example.com" or "" for root-relative URLs.
+func URLFor(base string) func(key string) string {
+	base = strings.TrimRight(base, "/") + "/assets/"
+	return func(key string) string {
+		return base + (&url.URL{Path: key}).EscapedPath()
+	}
+}
@@ -0,0 +1,58 @@
+package responsive
+
+import (
+	"reflect"
+	"testing"
+
+	"codlocker-assets/internal/storage"
+)
+
+func TestVariantKeys(t *testing.T) {
+	tests := []struct {
+		key      string
+		original string
+		width    int
+		ok       bool
+	}{
+		{"products/frozen/product-001@640w.jpg", "products/frozen/product-001.jpg", 640, true},
+		{"logo@2x@320w.png", "logo@2x.png", 320, true},
+		{"noext@100w", "noext", 100, true},
+		{"products/frozen/product-001.jpg", "", 0, false},
+		{"logo@2x.png", "", 0, false},
+		{"logo@0w.png", "", 0, false},
+		{"logo@w.png", "", 0, false},
+	}
+	for _, tt := range tests {
+		orig, w, ok := ParseVariantKey(tt.key)
+		if orig != tt.original || w != tt.width || ok != tt.ok {
+			t.Errorf("ParseVariantKey(%q) = %q, %d, %v; want %q, %d, %v", tt.key, orig, w, ok, tt.original, tt.width, tt.ok)
+		}
+		if tt.ok && VariantKey(tt.original, tt.width) != tt.key {
+			t.Errorf("VariantKey(%q, %d) = %q, want %q", tt.original, tt.width, VariantKey(tt.original, tt.width), tt.key)
+		}
+	}
+}
+
+func TestFindAndSrcset(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir())
+	for _, k := range []string{
+		"p/product-001.jpg", "p/product-001@1280w.jpg", "p/product-001@320w.jpg",
+		"p/product-001@320w.png", "p/product-0010@320w.jpg",
+	} {
+		if err := store.Put(k, []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Find(store, "p/product-001.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Variant{{"p/product-001@320w.jpg", 320}, {"p/product-001@1280w.jpg", 1280}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Find = %v, want %v", got, want)
+	}
+	srcset := Srcset(got, func(k string) string { return "/assets/" + k })
+	if srcset != "/assets/p/product-001@320w.jpg 320w, /assets/p/product-001@1280w.jpg 1280w" {
+		t.Errorf("Srcset = %q", srcset)
+	}
+}
@@ -0,0 +1,163 @@
+package responsive
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/http/api"
+	"codlocker-assets/internal/transform"
+)
+
+// MaxWidths caps how many widths one request may ask for.
+const MaxWidths = 12
+
+// MetaSource supplies intrinsic dimensions. *catalog.Service implements it.
+type MetaSource interface {
+	Get(ctx context.Context, key string) (db.AssetMeta, error)
+}
+
+// Service builds srcset and <picture> data for assets, so clients do not
+// need to know the transform URL format.
+type Service struct {
+	meta   MetaSource
+	urlFor func(key string) string
+}
+
+// New returns a Service. urlFor maps a key to its public URL.
+func New(meta MetaSource, urlFor func(key string) string) *Service {
+	return &Service{meta: meta, urlFor: urlFor}
+}
+
+// Source is one <source> element of a <picture>.
+type Source struct {
+	Type   string `json:"type"`
+	Srcset string `json:"srcset"`
+}
+
+// Set is everything needed to render a responsive image. Sources are in
+// preference order; Src and Srcset belong on the fallback <img>. Requested
+// formats the service cannot encode, such as avif and webp, get no source;
+// they are listed in UnsupportedFormats and Note says so.
+type Set struct {
+	Key                string   `json:"key"`
+	ContentType        string   `json:"contentType"`
+	Width              int      `json:"width,omitempty"`
+	Height             int      `json:"height,omitempty"`
+	Src                string   `json:"src"`
+	Srcset             string   `json:"srcset,omitempty"`
+	Sources            []Source `json:"sources"`
+	UnsupportedFormats []string `json:"unsupportedFormats,omitempty"`
+	Note               string   `json:"note,omitempty"`
+}
+
+// TransformURL returns the URL of key at width in format. A zero width or
+// empty format leaves that dimension unchanged.
+func TransformURL(urlFor func(key string) string, key string, width int, format string) string {
+	q := url.Values{}
+	if width > 0 {
+		q.Set("w", strconv.Itoa(width))
+	}
+	if format != "" {
+		q.Set("fm", format)
+	}
+	if len(q) == 0 {
+		return urlFor(key)
+	}
+	return urlFor(key) + "?" + q.Encode()
+}
+
+// Build returns the responsive set of key for the requested widths and
+// formats (in preference order, the last being the <img> fallback).
+// Widths snap to the renditions the asset handler generates and never
+// exceed the original; formats that cannot be produced are reported in
+// UnsupportedFormats. Non-raster assets get a plain Src.
+func (s *Service) Build(ctx context.Context, key string, widths []int, formats []string) (Set, error) {
+	m, err := s.meta.Get(ctx, key)
+	if err != nil {
+		return Set{}, err
+	}
+	set := Set{Key: m.Key, ContentType: m.ContentType, Width: m.Width, Height: m.Height,
+		Src: s.urlFor(m.Key), Sources: []Source{}}
+	original, raster := strings.CutPrefix(m.ContentType, "image/")
+	if !raster || !slices.Contains(transform.Formats, original) || m.Width == 0 {
+		return set, nil
+	}
+
+	var outFormats []string
+	for _, f := range formats {
+		f = transform.NormalizeFormat(f)
+		switch {
+		case slices.Contains(outFormats, f):
+		case slices.Contains(transform.Formats, f):
+			outFormats = append(outFormats, f)
+		case !slices.Contains(set.UnsupportedFormats, f):
+			set.UnsupportedFormats = append(set.UnsupportedFormats, f)
+		}
+	}
+	if len(set.UnsupportedFormats) > 0 {
+		set.Note = fmt.Sprintf("renditions are only encoded as %s; no source is listed for %s",
+			strings.Join(transform.Formats, " and "), strings.Join(set.UnsupportedFormats, ", "))
+	}
+	if len(outFormats) == 0 {
+		outFormats = []string{original}
+	}
+
+	var snapped []int
+	for _, w := range widths {
+		sw := min(transform.Snap(w), m.Width)
+		if !slices.Contains(snapped, sw) {
+			snapped = append(snapped, sw)
+		}
+	}
+	sort.Ints(snapped)
+
+	for i, f := range outFormats {
+		format := f
+		if f == original {
+			format = ""
+		}
+		parts := make([]string, len(snapped))
+		for j, w := range snapped {
+			width := w
+			if w == m.Width {
+				width = 0
+			}
+			parts[j] = TransformURL(s.urlFor, m.Key, width, format) + " " + strconv.Itoa(w) + "w"
+		}
+		srcset := strings.Join(parts, ", ")
+		if i < len(outFormats)-1 {
+			set.Sources = append(set.Sources, Source{Type: transform.ContentType(f), Srcset: srcset})
+			continue
+		}
+		set.ContentType = transform.ContentType(f)
+		set.Srcset = srcset
+		largest := snapped[len(snapped)-1]
+		if largest == m.Width {
+			largest = 0
+		}
+		set.Src = TransformURL(s.urlFor, m.Key, largest, format)
+	}
+	return set, nil
+}
+
+// ParseWidths parses a comma-separated width list such as "320,640,1280".
+func ParseWidths(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 || n > 10000 {
+			return nil, fmt.Errorf("%w: widths must be integers between 1 and 10000", api.ErrBadRequest)
+		}
+		out = append(out, n)
+	}
+	if len(out) > MaxWidths {
+		return nil, fmt.Errorf("%w: at most %d widths", api.ErrBadRequest, MaxWidths)
+	}
+	return out, nil
+}
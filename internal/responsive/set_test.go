@@ -0,0 +1,88 @@
+package responsive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+)
+
+// memMeta serves fixed catalog rows.
+type memMeta map[string]db.AssetMeta
+
+func (m memMeta) Get(_ context.Context, key string) (db.AssetMeta, error) {
+	meta, ok := m[key]
+	if !ok {
+		return db.AssetMeta{}, db.ErrNotFound
+	}
+	return meta, nil
+}
+
+func newTestService() *Service {
+	return New(memMeta{
+		"banners/hero.jpg": {Key: "banners/hero.jpg", ContentType: "image/jpeg", Width: 1000, Height: 500},
+		"logo.svg":         {Key: "logo.svg", ContentType: "image/svg+xml", Width: 120, Height: 40},
+	}, URLFor("https://cdn.example.com"))
+}
+
+func TestBuild(t *testing.T) {
+	s := newTestService()
+	set, err := s.Build(context.Background(), "banners/hero.jpg", []int{300, 640, 1280, 2000}, []string{"avif", "png", "jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "https://cdn.example.com/assets/banners/hero.jpg"
+	want := Set{
+		Key:         "banners/hero.jpg",
+		ContentType: "image/jpeg",
+		Width:       1000,
+		Height:      500,
+		Src:         base,
+		Srcset:      base + "?w=320 320w, " + base + "?w=640 640w, " + base + " 1000w",
+		Sources: []Source{{
+			Type:   "image/png",
+			Srcset: base + "?fm=png&w=320 320w, " + base + "?fm=png&w=640 640w, " + base + "?fm=png 1000w",
+		}},
+		UnsupportedFormats: []string{"avif"},
+		Note:               "renditions are only encoded as jpeg and png; no source is listed for avif",
+	}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("Build =\n%+v\nwant\n%+v", set, want)
+	}
+
+	set, _ = s.Build(context.Background(), "logo.svg", []int{320}, nil)
+	if set.Src != "https://cdn.example.com/assets/logo.svg" || set.Srcset != "" || len(set.Sources) != 0 {
+		t.Errorf("svg set = %+v", set)
+	}
+}
+
+func TestBuildHTTP(t *testing.T) {
+	r := mux.NewRouter()
+	newTestService().Register(r)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/assets/banners/hero.jpg/responsive")
+	var set Set
+	if err := json.NewDecoder(rec.Body).Decode(&set); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("default widths = %d, %v", rec.Code, err)
+	}
+	if set.Srcset == "" || set.Width != 1000 {
+		t.Errorf("default set = %+v", set)
+	}
+	if rec := get("/api/v1/assets/banners/hero.jpg/responsive?widths=320,abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad widths status = %d, want 400", rec.Code)
+	}
+	if rec := get("/api/v1/assets/missing.jpg/responsive"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing asset status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ArchiveStorage serves the entries of a .zip, .tar or .tar.gz file. The
+// archive is indexed once when loaded; Swap replaces it atomically with a
+// new version while in-flight reads finish against the old one.
+//
+// Uncompressed entries (stored zip entries, plain tar) are read in place
+// with seek support. Deflated zip entries are decompressed on open, and
+// .tar.gz archives are held in memory since gzip cannot seek.
+type ArchiveStorage struct {
+	cur atomic.Pointer[archive]
+}
+
+type archive struct {
+	path    string
+	file    *os.File // nil when all entries are held in memory
+	entries map[string]*archiveEntry
+	keys    []string
+
+	// refs counts the readers using file. After release no new reader
+	// pins the archive, and the last one to finish closes file, so a slow
+	// download never holds up a swap.
+	mu      sync.Mutex
+	refs    int
+	retired bool
+}
+
+type archiveEntry struct {
+	size    int64
+	modTime time.Time
+	offset  int64     // data offset in file for uncompressed entries, else -1
+	zf      *zip.File // compressed zip entry
+	data    []byte    // in-memory entry (tar.gz)
+}
+
+// OpenArchive indexes the archive at path.
+func OpenArchive(path string) (*ArchiveStorage, error) {
+	a, err := loadArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &ArchiveStorage{}
+	s.cur.Store(a)
+	return s, nil
+}
+
+// Swap indexes the archive at path and makes it current. On error the
+// previous archive stays in service.
+func (s *ArchiveStorage) Swap(path string) error {
+	a, err := loadArchive(path)
+	if err != nil {
+		return err
+	}
+	if old := s.cur.Swap(a); old != nil {
+		old.release()
+	}
+	return nil
+}
+
+// Path returns the file backing the current archive.
+func (s *ArchiveStorage) Path() string {
+	return s.cur.Load().path
+}
+
+// Close releases the current archive. The storage must not be used after.
+func (s *ArchiveStorage) Close() error {
+	if a := s.cur.Load(); a != nil {
+		a.release()
+	}
+	return nil
+}
+
+// acquire returns the current archive pinned; the caller unpins it.
+func (s *ArchiveStorage) acquire() *archive {
+	for {
+		a := s.cur.Load()
+		a.mu.Lock()
+		if !a.retired {
+			a.refs++
+			a.mu.Unlock()
+			return a
+		}
+		// Lost a race with Swap; the new archive is already published.
+		a.mu.Unlock()
+	}
+}
+
+// unpin drops a reference, closing the file if the archive was released
+// and this was the last one.
+func (a *archive) unpin() {
+	a.mu.Lock()
+	a.refs--
+	last := a.retired && a.refs == 0
+	a.mu.Unlock()
+	if last {
+		a.closeFile()
+	}
+}
+
+// release retires the archive. Its file is closed now if no reader holds
+// it, or else by the last reader to finish.
+func (a *archive) release() {
+	a.mu.Lock()
+	if a.retired {
+		a.mu.Unlock()
+		return
+	}
+	a.retired = true
+	idle := a.refs == 0
+	a.mu.Unlock()
+	if idle {
+		a.closeFile()
+	}
+}
+
+func (a *archive) closeFile() {
+	if a.file != nil {
+		a.file.Close()
+	}
+}
+
+func (s *ArchiveStorage) Get(key string) ([]byte, error) {
+	r, err := s.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *ArchiveStorage) Exists(key string) bool {
+	_, err := s.Stat(key)
+	return err == nil
+}
+
+func (s *ArchiveStorage) Stat(key string) (Info, error) {
+	clean, err := NormalizeKey(key)
+	if err != nil {
+		return Info{}, err
+	}
+	// The index stays valid after release; only the file needs a pin.
+	a := s.cur.Load()
+	e, ok := a.entries[clean]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return Info{Key: clean, Size: e.size, ModTime: e.modTime}, nil
+}
+
+func (s *ArchiveStorage) List(prefix string) ([]string, error) {
+	prefix = strings.TrimLeft(prefix, "/")
+	a := s.cur.Load()
+	i := sort.SearchStrings(a.keys, prefix)
+	var keys []string
+	for ; i < len(a.keys) && strings.HasPrefix(a.keys[i], prefix); i++ {
+		keys = append(keys, a.keys[i])
+	}
+	return keys, nil
+}
+
+// Open returns a reader for the entry. For entries read in place the
+// archive stays pinned until the reader is closed.
+func (s *ArchiveStorage) Open(key string) (io.ReadSeekCloser, error) {
+	clean, err := NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	a := s.acquire()
+	e, ok := a.entries[clean]
+	switch {
+	case !ok:
+		a.unpin()
+		return nil, ErrNotFound
+	case e.zf != nil:
+		defer a.unpin()
+		rc, err := e.zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s in %s: %w", clean, a.path, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("read %s in %s: %w", clean, a.path, err)
+		}
+		return NopCloser(bytes.NewReader(data)), nil
+	case e.offset < 0:
+		a.unpin()
+		return NopCloser(bytes.NewReader(e.data)), nil
+	default:
+		return &pinnedReader{
+			SectionReader: io.NewSectionReader(a.file, e.offset, e.size),
+			a:             a,
+		}, nil
+	}
+}
+
+// pinnedReader keeps the archive pinned until closed.
+type pinnedReader struct {
+	*io.SectionReader
+	a    *archive
+	once sync.Once
+}
+
+func (r *pinnedReader) Close() error {
+	r.once.Do(r.a.unpin)
+	return nil
+}
+
+func loadArchive(path string) (*archive, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return loadZip(path)
+	case strings.HasSuffix(lower, ".tar"):
+		return loadTar(path)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return loadTarGz(path)
+	default:
+		return nil, fmt.Errorf("unsupported archive type: %s", path)
+	}
+}
+
+func newArchive(path string, file *os.File) *archive {
+	return &archive{path: path, file: file, entries: make(map[string]*archiveEntry)}
+}
+
+// add indexes an entry under its normalised name. Entries whose names do
+// not normalise (absolute paths, "..", ...) are skipped.
+func (a *archive) add(name string, e *archiveEntry) {
+	clean, err := NormalizeKey(strings.TrimPrefix(name, "./"))
+	if err != nil {
+		return
+	}
+	a.entries[clean] = e
+}
+
+func (a *archive) finish() *archive {
+	a.keys = make([]string, 0, len(a.entries))
+	for k := range a.entries {
+		a.keys = append(a.keys, k)
+	}
+	sort.Strings(a.keys)
+	return a
+}
+
+func loadZip(path string) (*archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat archive: %w", err)
+	}
+	// Insecure names are skipped by add, so ErrInsecurePath is not fatal.
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		f.Close()
+		return nil, fmt.Errorf("read zip %s: %w", path, err)
+	}
+
+	a := newArchive(path, f)
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		e := &archiveEntry{size: int64(zf.UncompressedSize64), modTime: zf.Modified, offset: -1}
+		if zf.Method == zip.Store {
+			off, err := zf.DataOffset()
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("read zip %s: %w", path, err)
+			}
+			e.offset = off
+		} else {
+			e.zf = zf
+		}
+		a.add(zf.Name, e)
+	}
+	return a.finish(), nil
+}
+
+// countingReader tracks how far the tar reader has consumed the file, which
+// after Next() is the offset of the entry's data.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func loadTar(path string) (*archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	cr := &countingReader{r: f}
+	tr := tar.NewReader(cr)
+
+	a := newArchive(path, f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			f.Close()
+			return nil, fmt.Errorf("read tar %s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		a.add(hdr.Name, &archiveEntry{size: hdr.Size, modTime: hdr.ModTime, offset: cr.n})
+	}
+	return a.finish(), nil
+}
+
+func loadTarGz(path string) (*archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("read gzip %s: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+
+	a := newArchive(path, nil)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			return nil, fmt.Errorf("read tar %s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read tar %s: %w", path, err)
+		}
+		a.add(hdr.Name, &archiveEntry{size: int64(len(data)), modTime: hdr.ModTime, offset: -1, data: data})
+	}
+	return a.finish(), nil
+}
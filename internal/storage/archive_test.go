@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var archiveFiles = map[string]string{
+	"products/frozen/product-001.jpg":    "frozen one",
+	"products/shellfish/product-002.jpg": "shellfish two",
+}
+
+func writeZip(t *testing.T, path string, files map[string]string, method uint16) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(t *testing.T, path string, files map[string]string, gz bool) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if gz {
+		zw := gzip.NewWriter(f)
+		defer zw.Close()
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveStorageFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"./dot-prefix.txt": "x", "../escape.txt": "x", "/abs.txt": "x"}
+	for k, v := range archiveFiles {
+		files[k] = v
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		write func(path string)
+	}{
+		{"zip stored", "stored.zip", func(p string) { writeZip(t, p, files, zip.Store) }},
+		{"zip deflated", "deflated.zip", func(p string) { writeZip(t, p, files, zip.Deflate) }},
+		{"tar", "assets.tar", func(p string) { writeTar(t, p, files, false) }},
+		{"tar.gz", "assets.tar.gz", func(p string) { writeTar(t, p, files, true) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			tt.write(path)
+
+			s, err := OpenArchive(path)
+			if err != nil {
+				t.Fatalf("OpenArchive: %v", err)
+			}
+			defer s.Close()
+
+			for key, want := range archiveFiles {
+				data, err := s.Get(key)
+				if err != nil {
+					t.Fatalf("Get(%q): %v", key, err)
+				}
+				if string(data) != want {
+					t.Errorf("Get(%q) = %q, want %q", key, data, want)
+				}
+				info, err := s.Stat(key)
+				if err != nil || info.Size != int64(len(want)) {
+					t.Errorf("Stat(%q) = %+v, %v; want size %d", key, info, err, len(want))
+				}
+			}
+
+			// Entry names with ".." are never indexed; "./" and "/" are trimmed.
+			if s.Exists("escape.txt") {
+				t.Errorf("entry named ../escape.txt should be skipped")
+			}
+			if !s.Exists("abs.txt") || !s.Exists("dot-prefix.txt") {
+				t.Errorf("entries with ./ or / prefixes should be indexed")
+			}
+			if _, err := s.Get("../escape.txt"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Get(../escape.txt) err = %v, want ErrInvalidKey", err)
+			}
+			if _, err := s.Get("products/frozen/missing.jpg"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(missing) err = %v, want ErrNotFound", err)
+			}
+
+			// Seek within an entry.
+			r, err := s.Open("products/shellfish/product-002.jpg")
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if _, err := r.Seek(10, io.SeekStart); err != nil {
+				t.Fatalf("Seek: %v", err)
+			}
+			tail, _ := io.ReadAll(r)
+			r.Close()
+			if string(tail) != "two" {
+				t.Errorf("read after seek = %q, want %q", tail, "two")
+			}
+
+			keys, err := s.List("products/")
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			want := []string{"products/frozen/product-001.jpg", "products/shellfish/product-002.jpg"}
+			if !reflect.DeepEqual(keys, want) {
+				t.Errorf("List = %v, want %v", keys, want)
+			}
+		})
+	}
+}
+
+func TestArchiveStorageSwap(t *testing.T) {
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "v1.tar")
+	v2 := filepath.Join(dir, "v2.zip")
+	writeTar(t, v1, map[string]string{"logo.svg": "version one"}, false)
+	writeZip(t, v2, map[string]string{"logo.svg": "version two", "banner.svg": "new"}, zip.Store)
+
+	s, err := OpenArchive(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A reader opened before the swap keeps reading the old archive.
+	r, err := s.Open("logo.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := s.cur.Load()
+	if err := s.Swap(v2); err != nil {
+		t.Fatalf("Swap: %v", err)
+	}
+	if s.Path() != v2 {
+		t.Errorf("Path() = %q, want %q", s.Path(), v2)
+	}
+
+	old, err := io.ReadAll(r)
+	if err != nil || string(old) != "version one" {
+		t.Errorf("in-flight read = %q, %v; want %q", old, err, "version one")
+	}
+	// The old file is closed by the last reader, not by Swap.
+	if _, err := prev.file.Stat(); err != nil {
+		t.Errorf("old archive closed under a reader: %v", err)
+	}
+	r.Close()
+	if _, err := prev.file.Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("old archive still open after its last reader: %v", err)
+	}
+
+	data, err := s.Get("logo.svg")
+	if err != nil || string(data) != "version two" {
+		t.Errorf("Get after swap = %q, %v; want %q", data, err, "version two")
+	}
+	if !s.Exists("banner.svg") {
+		t.Errorf("banner.svg should exist after swap")
+	}
+
+	// A failed swap keeps the current archive in service.
+	if err := s.Swap(filepath.Join(dir, "missing.zip")); err == nil {
+		t.Errorf("Swap(missing) should fail")
+	}
+	if !s.Exists("banner.svg") {
+		t.Errorf("failed swap should keep the current archive")
+	}
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rescanWindow is how long after a change the batch directory is rescanned
+// on every lookup: a filesystem with coarse timestamps may not move its
+// mtime for a second change made within the same tick.
+const rescanWindow = 2 * time.Second
+
+// BatchOverlay serves base with the batches published under dir on top
+// of it. A batch is a directory of objects, staged elsewhere and renamed
+// into dir once complete, so all of it becomes visible at once; newer
+// batches (by name) take precedence. Writes and deletes go to base and
+// drop the key from every batch, so folding a batch into base one key at
+// a time changes nothing visible, and a later write is never shadowed by
+// a batch still being folded. dir is watched, so batches published by
+// another process show up too.
+type BatchOverlay struct {
+	base    Storage
+	dir     string
+	options []LocalOption
+	now     func() time.Time
+
+	mu      sync.Mutex
+	modTime time.Time       // of dir at the last scan
+	batches []*LocalStorage // newest first
+}
+
+// NewBatchOverlay serves base with the batches under dir on top. The
+// batches are opened with options.
+func NewBatchOverlay(base Storage, dir string, options ...LocalOption) *BatchOverlay {
+	return &BatchOverlay{base: base, dir: dir, options: options, now: time.Now}
+}
+
+// current returns the published batches, rescanning dir when it has
+// changed.
+func (o *BatchOverlay) current() []*LocalStorage {
+	fi, err := os.Stat(o.dir)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err != nil {
+		o.batches, o.modTime = nil, time.Time{}
+		return nil
+	}
+	if fi.ModTime().Equal(o.modTime) && o.now().Sub(o.modTime) > rescanWindow {
+		return o.batches
+	}
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return o.batches // keep serving the last scan
+	}
+	var batches []*LocalStorage
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].IsDir() {
+			batches = append(batches, NewLocalStorage(filepath.Join(o.dir, entries[i].Name()), o.options...))
+		}
+	}
+	o.batches, o.modTime = batches, fi.ModTime()
+	return batches
+}
+
+// A batch may be folded away between a lookup and a read, so a batch
+// that no longer has the key passes the lookup on.
+
+func (o *BatchOverlay) Get(key string) ([]byte, error) {
+	for _, b := range o.current() {
+		if data, err := b.Get(key); !errors.Is(err, ErrNotFound) {
+			return data, err
+		}
+	}
+	return o.base.Get(key)
+}
+
+func (o *BatchOverlay) Open(key string) (io.ReadSeekCloser, error) {
+	for _, b := range o.current() {
+		if r, err := b.Open(key); !errors.Is(err, ErrNotFound) {
+			return r, err
+		}
+	}
+	return Open(o.base, key)
+}
+
+func (o *BatchOverlay) Stat(key string) (Info, error) {
+	for _, b := range o.current() {
+		if info, err := b.Stat(key); !errors.Is(err, ErrNotFound) {
+			return info, err
+		}
+	}
+	return Stat(o.base, key)
+}
+
+func (o *BatchOverlay) Exists(key string) bool {
+	for _, b := range o.current() {
+		if b.Exists(key) {
+			return true
+		}
+	}
+	return o.base.Exists(key)
+}
+
+// List merges the keys of base and of every batch.
+func (o *BatchOverlay) List(prefix string) ([]string, error) {
+	l, ok := o.base.(Lister)
+	if !ok {
+		return nil, errors.New("base storage cannot be listed")
+	}
+	keys, err := l.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	batches := o.current()
+	if len(batches) == 0 {
+		return keys, nil
+	}
+	keys = slices.Clone(keys)
+	for _, b := range batches {
+		more, err := b.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, more...)
+	}
+	sort.Strings(keys)
+	return slices.Compact(keys), nil
+}
+
+// Put writes key to base, then drops it from the batches.
+func (o *BatchOverlay) Put(key string, data []byte) error {
+	w, ok := o.base.(Writer)
+	if !ok {
+		return errors.New("base storage is read-only")
+	}
+	if err := w.Put(key, data); err != nil {
+		return err
+	}
+	_, err := o.drop(key)
+	return err
+}
+
+// Delete removes key from the batches and from base.
+func (o *BatchOverlay) Delete(key string) error {
+	w, ok := o.base.(Writer)
+	if !ok {
+		return errors.New("base storage is read-only")
+	}
+	found, err := o.drop(key)
+	if err != nil {
+		return err
+	}
+	if err := w.Delete(key); err == nil {
+		found = true
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// drop removes key from every batch, reporting whether one had it.
+func (o *BatchOverlay) drop(key string) (bool, error) {
+	key = strings.TrimPrefix(key, "/")
+	found := false
+	for _, b := range o.current() {
+		switch err := b.Delete(key); {
+		case err == nil:
+			found = true
+		case !errors.Is(err, ErrNotFound):
+			return found, err
+		}
+	}
+	return found, nil
+}
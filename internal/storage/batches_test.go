@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBatchOverlay(t *testing.T) {
+	base := NewLocalStorage(writeTree(t, map[string]string{
+		"banners/spring.svg": "base",
+		"banners/summer.svg": "base",
+	}))
+	dir := filepath.Join(t.TempDir(), "published")
+	o := NewBatchOverlay(base, dir)
+
+	// No batches yet: base as is.
+	if data, err := o.Get("banners/spring.svg"); err != nil || string(data) != "base" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+
+	// A batch shows once it is renamed into dir, all of it at once.
+	staged := writeTree(t, map[string]string{
+		"banners/spring.svg": "batch",
+		"banners/autumn.svg": "batch",
+	})
+	_ = os.MkdirAll(dir, 0o755)
+	if err := os.Rename(staged, filepath.Join(dir, "0001")); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"banners/spring.svg", "banners/autumn.svg"} {
+		if data, err := o.Get(key); err != nil || string(data) != "batch" {
+			t.Errorf("Get(%s) = %q, %v; want the batch", key, data, err)
+		}
+	}
+	keys, err := o.List("banners/")
+	if want := []string{"banners/autumn.svg", "banners/spring.svg", "banners/summer.svg"}; err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("List = %v, %v; want %v", keys, err, want)
+	}
+
+	// Writing through the overlay folds a key into base; nothing visible
+	// changes, and the batch no longer shadows it.
+	if err := o.Put("banners/autumn.svg", []byte("batch")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := base.Get("banners/autumn.svg"); string(data) != "batch" || NewLocalStorage(filepath.Join(dir, "0001")).Exists("banners/autumn.svg") {
+		t.Errorf("Put did not fold the key: base has %q", data)
+	}
+	if err := o.Put("banners/spring.svg", []byte("upload")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := o.Get("banners/spring.svg"); string(data) != "upload" {
+		t.Errorf("a later write is shadowed: Get = %q", data)
+	}
+
+	// Deleting removes the key wherever it is.
+	if err := o.Delete("banners/spring.svg"); err != nil || o.Exists("banners/spring.svg") {
+		t.Errorf("Delete = %v; key still exists: %v", err, o.Exists("banners/spring.svg"))
+	}
+	if err := o.Delete("banners/missing.svg"); err != ErrNotFound {
+		t.Errorf("Delete(missing) = %v, want ErrNotFound", err)
+	}
+
+	// Removing the batch leaves base.
+	if err := os.RemoveAll(filepath.Join(dir, "0001")); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ = o.List("")
+	if want := []string{"banners/autumn.svg", "banners/summer.svg"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List after removing the batch = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"mime"
+	"path/filepath"
+)
+
+// DetectContentType picks a MIME type from the key's extension, overriding
+// it for SVG content (many bundled placeholders are SVGs named .jpg).
+func DetectContentType(key string, head []byte) string {
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+
+	// Detect SVG files by content (even if named .jpg)
+	if len(head) > 4 && (string(head[:4]) == "<svg" || string(head[:5]) == "<?xml") {
+		contentType = "image/svg+xml"
+	} else if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return contentType
+}
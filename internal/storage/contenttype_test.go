@@ -0,0 +1,22 @@
+package storage
+
+import "testing"
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		key  string
+		head string
+		want string
+	}{
+		{"a.jpg", "<svg>", "image/svg+xml"},
+		{"a.jpg", `<?xml version="1.0"?>`, "image/svg+xml"},
+		{"a.jpg", "\xff\xd8\xff", "image/jpeg"},
+		{"a.bin", "abc", "application/octet-stream"},
+		{"a", "", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := DetectContentType(tt.key, []byte(tt.head)); got != tt.want {
+			t.Errorf("DetectContentType(%q, %q) = %q, want %q", tt.key, tt.head, got, tt.want)
+		}
+	}
+}
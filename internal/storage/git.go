@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitStorage serves blobs from a local (typically bare) git repository at
+// a pinned branch, tag or commit. The tree of the pinned commit is indexed
+// on Checkout; switching refs swaps the index atomically, so a rollback is
+// just a ref change.
+//
+// Each blob's SHA is reported as its ETag, and the commit time as its
+// modification time.
+type GitStorage struct {
+	repoPath string
+	repo     *git.Repository
+
+	// go-git's object storage is not safe for concurrent reads.
+	mu  sync.Mutex
+	cur atomic.Pointer[gitSnapshot]
+}
+
+type gitSnapshot struct {
+	ref    string
+	commit plumbing.Hash
+	when   time.Time
+	blobs  map[string]gitBlob
+	keys   []string
+}
+
+type gitBlob struct {
+	hash plumbing.Hash
+	size int64
+}
+
+// OpenGit opens the repository at repoPath and checks out ref.
+func OpenGit(repoPath, ref string) (*GitStorage, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("open git repo %s: %w", repoPath, err)
+	}
+	s := &GitStorage{repoPath: repoPath, repo: repo}
+	if err := s.Checkout(ref); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Checkout resolves ref and, if it points at a different commit than the
+// one being served, indexes that commit's tree and makes it current. It is
+// cheap to call repeatedly, e.g. to pick up a branch that has moved.
+func (s *GitStorage) Checkout(ref string) error {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return fmt.Errorf("resolve %q in %s: %w", ref, s.repoPath, err)
+	}
+	if cur := s.cur.Load(); cur != nil && cur.commit == *hash {
+		if cur.ref != ref {
+			next := *cur
+			next.ref = ref
+			s.cur.Store(&next)
+		}
+		return nil
+	}
+
+	commit, err := s.repo.CommitObject(*hash)
+	if err != nil {
+		return fmt.Errorf("load commit %s: %w", hash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("load tree of %s: %w", hash, err)
+	}
+
+	snap := &gitSnapshot{
+		ref:    ref,
+		commit: *hash,
+		when:   commit.Committer.When,
+		blobs:  make(map[string]gitBlob),
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		// Symlinks and submodules are not served.
+		if f.Mode != filemode.Regular && f.Mode != filemode.Executable {
+			return nil
+		}
+		if key, err := NormalizeKey(f.Name); err == nil {
+			snap.blobs[key] = gitBlob{hash: f.Hash, size: f.Size}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("index tree of %s: %w", hash, err)
+	}
+	snap.keys = make([]string, 0, len(snap.blobs))
+	for k := range snap.blobs {
+		snap.keys = append(snap.keys, k)
+	}
+	sort.Strings(snap.keys)
+
+	s.cur.Store(snap)
+	return nil
+}
+
+// Ref returns the ref being served and the commit it resolved to.
+func (s *GitStorage) Ref() (ref, commit string) {
+	snap := s.cur.Load()
+	return snap.ref, snap.commit.String()
+}
+
+func (s *GitStorage) lookup(key string) (*gitSnapshot, gitBlob, error) {
+	clean, err := NormalizeKey(key)
+	if err != nil {
+		return nil, gitBlob{}, err
+	}
+	snap := s.cur.Load()
+	b, ok := snap.blobs[clean]
+	if !ok {
+		return nil, gitBlob{}, ErrNotFound
+	}
+	return snap, b, nil
+}
+
+func (s *GitStorage) Get(key string) ([]byte, error) {
+	_, b, err := s.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blob, err := s.repo.BlobObject(b.hash)
+	if err != nil {
+		return nil, fmt.Errorf("load blob %s: %w", b.hash, err)
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("read blob %s: %w", b.hash, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *GitStorage) Exists(key string) bool {
+	_, _, err := s.lookup(key)
+	return err == nil
+}
+
+func (s *GitStorage) Stat(key string) (Info, error) {
+	snap, b, err := s.lookup(key)
+	if err != nil {
+		return Info{}, err
+	}
+	clean, _ := NormalizeKey(key)
+	return Info{Key: clean, Size: b.size, ModTime: snap.when, ETag: b.hash.String()}, nil
+}
+
+func (s *GitStorage) List(prefix string) ([]string, error) {
+	prefix = strings.TrimLeft(prefix, "/")
+	snap := s.cur.Load()
+	i := sort.SearchStrings(snap.keys, prefix)
+	var keys []string
+	for ; i < len(snap.keys) && strings.HasPrefix(snap.keys[i], prefix); i++ {
+		keys = append(keys, snap.keys[i])
+	}
+	return keys, nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFiles writes files into the worktree of repo and commits them.
+func commitFiles(t *testing.T, repo *git.Repository, dir string, files map[string]string, msg string) string {
+	t.Helper()
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := wt.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{Name: "design", Email: "design@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+func TestGitStorage(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, repo, dir, map[string]string{
+		"ui/logo.svg":   "<svg>v1</svg>",
+		"ui/banner.svg": "<svg>banner</svg>",
+	}, "first")
+	if _, err := repo.CreateTag("v1", mustHash(t, repo, first), nil); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, repo, dir, map[string]string{"ui/logo.svg": "<svg>v2</svg>"}, "second")
+
+	s, err := OpenGit(dir, "")
+	if err != nil {
+		t.Fatalf("OpenGit: %v", err)
+	}
+
+	data, err := s.Get("ui/logo.svg")
+	if err != nil || string(data) != "<svg>v2</svg>" {
+		t.Fatalf("Get at HEAD = %q, %v", data, err)
+	}
+	headInfo, err := s.Stat("ui/logo.svg")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if len(headInfo.ETag) != 40 || headInfo.Size != int64(len("<svg>v2</svg>")) {
+		t.Errorf("Stat = %+v, want 40-char blob SHA and size", headInfo)
+	}
+
+	keys, _ := s.List("ui/")
+	if want := []string{"ui/banner.svg", "ui/logo.svg"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List = %v, want %v", keys, want)
+	}
+
+	// Rolling back is just a ref change.
+	for _, ref := range []string{"v1", first, first[:7]} {
+		if err := s.Checkout(ref); err != nil {
+			t.Fatalf("Checkout(%q): %v", ref, err)
+		}
+		data, _ := s.Get("ui/logo.svg")
+		if string(data) != "<svg>v1</svg>" {
+			t.Errorf("Get at %s = %q, want v1", ref, data)
+		}
+		info, _ := s.Stat("ui/logo.svg")
+		if info.ETag == headInfo.ETag {
+			t.Errorf("ETag at %s should differ from HEAD", ref)
+		}
+		if name, commit := s.Ref(); name != ref || commit != first {
+			t.Errorf("Ref() = %s, %s; want %s, %s", name, commit, ref, first)
+		}
+	}
+
+	if err := s.Checkout("no-such-branch"); err == nil {
+		t.Errorf("Checkout(no-such-branch) should fail")
+	}
+	if !s.Exists("ui/banner.svg") {
+		t.Errorf("failed checkout should keep serving the previous ref")
+	}
+	if _, err := s.Get("ui/missing.svg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get("../ui/logo.svg"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Get(traversal) err = %v, want ErrInvalidKey", err)
+	}
+}
+
+func mustHash(t *testing.T, repo *git.Repository, rev string) plumbing.Hash {
+	t.Helper()
+	h, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *h
+}
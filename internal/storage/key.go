@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// NormalizeKey turns a request path into a canonical storage key.
+// Every backend runs keys through it before touching the underlying store.
+//
+// A leading slash is dropped and redundant "." elements are removed. Keys
+// with ".." elements, backslashes, NUL or other control bytes, or that name
+// the root itself are rejected with ErrInvalidKey. Percent sequences are not
+// decoded here: the router has already decoded the URL once, so anything
+// left is a literal file name character.
+func NormalizeKey(key string) (string, error) {
+	key = strings.TrimLeft(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f || r == '\\' {
+			return "", fmt.Errorf("%w: disallowed character %q", ErrInvalidKey, r)
+		}
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%w: path traversal detected", ErrInvalidKey)
+		}
+	}
+	clean := path.Clean(key)
+	if clean == "." || !fs.ValidPath(clean) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return clean, nil
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyIndex keeps the keys of a Lister in memory, so prefix listings on
+// the request path do not walk the store each time (an OverlayStorage
+// walks every layer in full). It is an Observer: writes and deletions
+// through the services it observes show at once. Changes made behind
+// their back, such as files copied into a root, show once the index is
+// rebuilt, in the background, every refresh.
+type KeyIndex struct {
+	src     Lister
+	refresh time.Duration
+	now     func() time.Time
+
+	build sync.Mutex // one walk at a time
+
+	mu         sync.Mutex
+	keys       []string // sorted
+	built      time.Time
+	refreshing bool
+	pending    []keyChange // changes seen during a walk, nil when none runs
+}
+
+type keyChange struct {
+	key     string
+	removed bool
+}
+
+// NewKeyIndex returns an index of src's keys, built on first use and
+// rebuilt every refresh; a refresh of 0 never rebuilds it.
+func NewKeyIndex(src Lister, refresh time.Duration) *KeyIndex {
+	return &KeyIndex{src: src, refresh: refresh, now: time.Now}
+}
+
+// Covers reports whether the index lists the keys of s.
+func (x *KeyIndex) Covers(s Storage) bool {
+	return any(x.src) == any(s)
+}
+
+// List returns the indexed keys under prefix, sorted.
+func (x *KeyIndex) List(prefix string) ([]string, error) {
+	x.mu.Lock()
+	built := !x.built.IsZero()
+	if built && x.refresh > 0 && !x.refreshing && x.now().Sub(x.built) >= x.refresh {
+		x.refreshing = true
+		go func() { _ = x.Refresh() }()
+	}
+	x.mu.Unlock()
+	if !built {
+		if err := x.ensure(); err != nil {
+			return nil, err
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var out []string
+	for i := sort.SearchStrings(x.keys, prefix); i < len(x.keys) && strings.HasPrefix(x.keys[i], prefix); i++ {
+		out = append(out, x.keys[i])
+	}
+	return out, nil
+}
+
+// ensure builds the index unless a concurrent caller already has.
+func (x *KeyIndex) ensure() error {
+	x.build.Lock()
+	defer x.build.Unlock()
+	x.mu.Lock()
+	built := !x.built.IsZero()
+	x.mu.Unlock()
+	if built {
+		return nil
+	}
+	return x.walk()
+}
+
+// Refresh rebuilds the index from the store. On error the previous keys
+// are kept.
+func (x *KeyIndex) Refresh() error {
+	x.build.Lock()
+	defer x.build.Unlock()
+	return x.walk()
+}
+
+// walk lists the store and swaps the result in, replaying the changes
+// observed meanwhile. The caller holds x.build.
+func (x *KeyIndex) walk() error {
+	x.mu.Lock()
+	x.pending = []keyChange{}
+	x.mu.Unlock()
+
+	keys, err := x.src.List("")
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	pending := x.pending
+	x.pending = nil
+	x.refreshing = false
+	if err != nil {
+		if !x.built.IsZero() {
+			x.built = x.now() // retry after another refresh
+		}
+		return err
+	}
+	x.keys = slices.Clone(keys)
+	sort.Strings(x.keys)
+	for _, c := range pending {
+		x.apply(c)
+	}
+	x.built = x.now()
+	return nil
+}
+
+// apply records a change. The caller holds x.mu.
+func (x *KeyIndex) apply(c keyChange) {
+	i, found := slices.BinarySearch(x.keys, c.key)
+	switch {
+	case c.removed && found:
+		x.keys = slices.Delete(x.keys, i, i+1)
+	case !c.removed && !found:
+		x.keys = slices.Insert(x.keys, i, c.key)
+	}
+}
+
+func (x *KeyIndex) change(c keyChange) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.pending != nil {
+		x.pending = append(x.pending, c)
+	}
+	x.apply(c)
+}
+
+// Written adds key to the index.
+func (x *KeyIndex) Written(_ context.Context, key string, _ []byte, _ string) {
+	x.change(keyChange{key: key})
+}
+
+// Removed drops key from the index.
+func (x *KeyIndex) Removed(_ context.Context, key string) {
+	x.change(keyChange{key: key, removed: true})
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyIndex(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStorage(writeTree(t, map[string]string{
+		"banners/spring.svg":    "default",
+		"banners/spring.de.svg": "de",
+		"banners/summer.svg":    "summer",
+	}))
+	now := time.Unix(1700000000, 0)
+	x := NewKeyIndex(store, time.Minute)
+	x.now = func() time.Time { return now }
+
+	if !x.Covers(store) || x.Covers(NewLocalStorage(t.TempDir())) {
+		t.Error("Covers does not match the indexed store")
+	}
+	got, err := x.List("banners/spring.")
+	if want := []string{"banners/spring.de.svg", "banners/spring.svg"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("List = %v, %v; want %v", got, err, want)
+	}
+
+	// Observed changes show at once, unobserved ones after a rebuild.
+	x.Written(ctx, "banners/spring.fr.svg", nil, "alice")
+	x.Removed(ctx, "banners/spring.de.svg")
+	_ = store.Put("banners/spring.it.svg", []byte("it"))
+	got, _ = x.List("banners/spring.")
+	if want := []string{"banners/spring.fr.svg", "banners/spring.svg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after observed changes List = %v, want %v", got, want)
+	}
+	_ = store.Put("banners/spring.fr.svg", []byte("fr"))
+	_ = store.Delete("banners/spring.de.svg")
+	if err := x.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = x.List("banners/spring.")
+	if want := []string{"banners/spring.fr.svg", "banners/spring.it.svg", "banners/spring.svg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after Refresh List = %v, want %v", got, want)
+	}
+	if got, _ := x.List("banners/x"); len(got) != 0 {
+		t.Errorf("List(banners/x) = %v", got)
+	}
+}
@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SymlinkPolicy controls how LocalStorage treats symbolic links.
+// Links that leave the base directory are refused under every policy.
+type SymlinkPolicy int
+
+const (
+	// SymlinksWithinRoot follows links as long as they resolve inside the
+	// base directory (e.g. the ..data links of a Kubernetes volume).
+	SymlinksWithinRoot SymlinkPolicy = iota
+	// SymlinksDeny refuses any key whose path contains a symlink.
+	SymlinksDeny
+)
+
+// ParseSymlinkPolicy maps "deny" / "within-root" to a policy.
+// Anything else falls back to SymlinksWithinRoot.
+func ParseSymlinkPolicy(s string) SymlinkPolicy {
+	if strings.EqualFold(strings.TrimSpace(s), "deny") {
+		return SymlinksDeny
+	}
+	return SymlinksWithinRoot
+}
+
+func (p SymlinkPolicy) String() string {
+	if p == SymlinksDeny {
+		return "deny"
+	}
+	return "within-root"
+}
+
+// ErrSymlink is returned when a key crosses a symlink under SymlinksDeny.
+var ErrSymlink = errors.New("symlinks not allowed")
+
+// LocalStorage serves files from local filesystem. All access goes through
+// an os.Root opened on basePath, so lookups cannot escape it via "..",
+// absolute paths or symlinks.
+type LocalStorage struct {
+	basePath string
+	symlinks SymlinkPolicy
+}
+
+// LocalOption configures a LocalStorage.
+type LocalOption func(*LocalStorage)
+
+// WithSymlinkPolicy sets the symlink policy (default SymlinksWithinRoot).
+func WithSymlinkPolicy(p SymlinkPolicy) LocalOption {
+	return func(s *LocalStorage) { s.symlinks = p }
+}
+
+func NewLocalStorage(basePath string, options ...LocalOption) *LocalStorage {
+	s := &LocalStorage{basePath: basePath}
+	for _, fn := range options {
+		fn(s)
+	}
+	return s
+}
+
+// open normalises key, opens the root and applies the symlink policy.
+// The caller must close the returned root.
+func (s *LocalStorage) open(key string) (*os.Root, string, error) {
+	clean, err := NormalizeKey(key)
+	if err != nil {
+		return nil, "", err
+	}
+	root, err := os.OpenRoot(s.basePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("failed to open base path: %w", err)
+	}
+	if s.symlinks == SymlinksDeny {
+		if err := checkNoSymlinks(root, clean); err != nil {
+			root.Close()
+			return nil, "", err
+		}
+	}
+	return root, clean, nil
+}
+
+// checkNoSymlinks walks every element of key and fails on the first link.
+func checkNoSymlinks(root *os.Root, key string) error {
+	elems := strings.Split(key, "/")
+	for i := range elems {
+		p := strings.Join(elems[:i+1], "/")
+		fi, err := root.Lstat(p)
+		if err != nil {
+			return mapFSError(err)
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s", ErrSymlink, p)
+		}
+	}
+	return nil
+}
+
+func mapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return fmt.Errorf("failed to open file: %w", err)
+}
+
+func (s *LocalStorage) Get(key string) ([]byte, error) {
+	root, clean, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	file, err := root.Open(clean)
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, ErrNotFound
+	}
+	return io.ReadAll(file)
+}
+
+// Open returns the file itself; the caller must close it.
+func (s *LocalStorage) Open(key string) (io.ReadSeekCloser, error) {
+	root, clean, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	// The open file stays valid after the root is closed.
+	defer root.Close()
+
+	file, err := root.Open(clean)
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+	fi, err := file.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		file.Close()
+		return nil, ErrNotFound
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) Stat(key string) (Info, error) {
+	root, clean, err := s.open(key)
+	if err != nil {
+		return Info{}, err
+	}
+	defer root.Close()
+
+	fi, err := root.Stat(clean)
+	if err != nil {
+		return Info{}, mapFSError(err)
+	}
+	if !fi.Mode().IsRegular() {
+		return Info{}, ErrNotFound
+	}
+	return Info{Key: clean, Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (s *LocalStorage) Exists(key string) bool {
+	root, clean, err := s.open(key)
+	if err != nil {
+		return false
+	}
+	defer root.Close()
+
+	fi, err := root.Stat(clean)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// List walks the base directory and returns every file key under prefix.
+// A missing base directory is treated as empty so optional roots can be
+// configured before they are mounted.
+func (s *LocalStorage) List(prefix string) ([]string, error) {
+	root, err := os.OpenRoot(s.basePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list %s: %w", s.basePath, err)
+	}
+	defer root.Close()
+
+	prefix = strings.TrimLeft(prefix, "/")
+	// Start the walk at the deepest directory named by prefix.
+	start := "."
+	if dir := path.Dir(prefix + "x"); dir != "." {
+		if clean, err := NormalizeKey(dir); err == nil {
+			start = clean
+		}
+	}
+	if s.symlinks == SymlinksDeny && start != "." {
+		if err := checkNoSymlinks(root, start); err != nil {
+			return nil, nil
+		}
+	}
+
+	var keys []string
+	err = fs.WalkDir(root.FS(), start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == start && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(p, prefix) {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if s.symlinks == SymlinksDeny {
+				return nil
+			}
+			// Only report links that resolve to a regular file in the root.
+			if fi, err := root.Stat(p); err != nil || !fi.Mode().IsRegular() {
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+		keys = append(keys, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", s.basePath, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Put writes data to key, creating parent directories as needed. The file
+// is written under a temporary name and renamed into place so readers
+// never see a partial object.
+func (s *LocalStorage) Put(key string, data []byte) error {
+	clean, err := NormalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.basePath, 0o755); err != nil {
+		return fmt.Errorf("create base path: %w", err)
+	}
+	root, err := os.OpenRoot(s.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to open base path: %w", err)
+	}
+	defer root.Close()
+
+	dir := path.Dir(clean)
+	if err := mkdirAll(root, dir); err != nil {
+		return err
+	}
+	if s.symlinks == SymlinksDeny && dir != "." {
+		if err := checkNoSymlinks(root, dir); err != nil {
+			return err
+		}
+	}
+
+	tmp := path.Join(dir, ".tmp-"+path.Base(clean)+"-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	f, err := root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", clean, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		root.Remove(tmp)
+		return fmt.Errorf("write %s: %w", clean, err)
+	}
+	if err := f.Close(); err != nil {
+		root.Remove(tmp)
+		return fmt.Errorf("write %s: %w", clean, err)
+	}
+	// os.Root has no Rename before Go 1.25; both names were just resolved
+	// inside the root above.
+	from := filepath.Join(s.basePath, filepath.FromSlash(tmp))
+	to := filepath.Join(s.basePath, filepath.FromSlash(clean))
+	if err := os.Rename(from, to); err != nil {
+		root.Remove(tmp)
+		return fmt.Errorf("rename %s: %w", clean, err)
+	}
+	return nil
+}
+
+// Delete removes key. Empty parent directories are left in place.
+func (s *LocalStorage) Delete(key string) error {
+	root, clean, err := s.open(key)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	fi, err := root.Lstat(clean)
+	if err != nil {
+		return mapFSError(err)
+	}
+	if fi.IsDir() {
+		return ErrNotFound
+	}
+	if err := root.Remove(clean); err != nil {
+		return mapFSError(err)
+	}
+	return nil
+}
+
+// mkdirAll creates dir and its parents inside root.
+func mkdirAll(root *os.Root, dir string) error {
+	if dir == "." {
+		return nil
+	}
+	elems := strings.Split(dir, "/")
+	for i := range elems {
+		p := strings.Join(elems[:i+1], "/")
+		if err := root.Mkdir(p, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("create directory %s: %w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sandbox lays out:
+//
+//	<tmp>/secret.txt
+//	<tmp>/assets-private/secret.txt
+//	<tmp>/assets/products/frozen/product-001.jpg
+//	<tmp>/assets/products/.wh..wh..opq
+//	<tmp>/assets/link-inside   -> products/frozen/product-001.jpg
+//	<tmp>/assets/link-outside  -> ../secret.txt
+//	<tmp>/assets/linkdir       -> products
+//	<tmp>/assets/escape-dir    -> ..
+//
+// and returns the assets directory.
+func sandbox(t *testing.T) string {
+	t.Helper()
+	tmp := t.TempDir()
+	base := filepath.Join(tmp, "assets")
+	for p, content := range map[string]string{
+		filepath.Join(tmp, "secret.txt"):                             "secret",
+		filepath.Join(tmp, "assets-private", "secret.txt"):           "secret",
+		filepath.Join(base, "products", "frozen", "product-001.jpg"): "photo",
+		filepath.Join(base, "products", ".wh..wh..opq"):              "opaque",
+	} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"link-inside":  "products/frozen/product-001.jpg",
+		"link-outside": "../secret.txt",
+		"linkdir":      "products",
+		"escape-dir":   "..",
+	} {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+	return base
+}
+
+func TestLocalStorageGet(t *testing.T) {
+	base := sandbox(t)
+
+	tests := []struct {
+		name   string
+		key    string
+		policy SymlinkPolicy
+		want   string // "" means an error is expected
+	}{
+		{"plain file", "products/frozen/product-001.jpg", SymlinksWithinRoot, "photo"},
+		{"leading slash", "/products/frozen/product-001.jpg", SymlinksWithinRoot, "photo"},
+		{"dot elements", "products/./frozen/product-001.jpg", SymlinksWithinRoot, "photo"},
+		{"double-dot file name", "products/.wh..wh..opq", SymlinksWithinRoot, "opaque"},
+		{"missing", "products/frozen/product-404.jpg", SymlinksWithinRoot, ""},
+		{"directory", "products/frozen", SymlinksWithinRoot, ""},
+		{"root", "/", SymlinksWithinRoot, ""},
+
+		// traversal
+		{"parent", "../secret.txt", SymlinksWithinRoot, ""},
+		{"nested parent", "products/../../secret.txt", SymlinksWithinRoot, ""},
+		{"parent then back in", "products/../products/frozen/product-001.jpg", SymlinksWithinRoot, ""},
+		{"sibling prefix dir", "../assets-private/secret.txt", SymlinksWithinRoot, ""},
+		{"absolute", "/etc/passwd", SymlinksWithinRoot, ""},
+
+		// encoding tricks
+		{"percent-encoded dots", "%2e%2e/secret.txt", SymlinksWithinRoot, ""},
+		{"encoded slash", "..%2fsecret.txt", SymlinksWithinRoot, ""},
+		{"backslash", `..\secret.txt`, SymlinksWithinRoot, ""},
+		{"nul byte", "products/frozen/product-001.jpg\x00.png", SymlinksWithinRoot, ""},
+
+		// symlinks
+		{"link inside root", "link-inside", SymlinksWithinRoot, "photo"},
+		{"link inside root denied", "link-inside", SymlinksDeny, ""},
+		{"dir link inside root", "linkdir/frozen/product-001.jpg", SymlinksWithinRoot, "photo"},
+		{"dir link inside root denied", "linkdir/frozen/product-001.jpg", SymlinksDeny, ""},
+		{"link outside root", "link-outside", SymlinksWithinRoot, ""},
+		{"link outside root denied", "link-outside", SymlinksDeny, ""},
+		{"dir link escaping root", "escape-dir/secret.txt", SymlinksWithinRoot, ""},
+		{"dir link to sibling", "escape-dir/assets-private/secret.txt", SymlinksWithinRoot, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewLocalStorage(base, WithSymlinkPolicy(tt.policy))
+			data, err := s.Get(tt.key)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("Get(%q) = %q, want error", tt.key, data)
+				}
+				if s.Exists(tt.key) {
+					t.Errorf("Exists(%q) = true, want false", tt.key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get(%q) error: %v", tt.key, err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.key, data, tt.want)
+			}
+			if !s.Exists(tt.key) {
+				t.Errorf("Exists(%q) = false, want true", tt.key)
+			}
+		})
+	}
+}
+
+func TestLocalStorageErrors(t *testing.T) {
+	base := sandbox(t)
+
+	tests := []struct {
+		name   string
+		key    string
+		policy SymlinkPolicy
+		want   error
+	}{
+		{"missing", "products/frozen/product-404.jpg", SymlinksWithinRoot, ErrNotFound},
+		{"traversal", "../secret.txt", SymlinksWithinRoot, ErrInvalidKey},
+		{"backslash", `products\frozen`, SymlinksWithinRoot, ErrInvalidKey},
+		{"denied link", "link-inside", SymlinksDeny, ErrSymlink},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLocalStorage(base, WithSymlinkPolicy(tt.policy)).Get(tt.key)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Get(%q) err = %v, want %v", tt.key, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalStorageList(t *testing.T) {
+	base := sandbox(t)
+
+	tests := []struct {
+		name   string
+		prefix string
+		policy SymlinkPolicy
+		want   []string
+	}{
+		{"all within root", "", SymlinksWithinRoot, []string{
+			"link-inside",
+			"products/.wh..wh..opq",
+			"products/frozen/product-001.jpg",
+		}},
+		{"all deny", "", SymlinksDeny, []string{
+			"products/.wh..wh..opq",
+			"products/frozen/product-001.jpg",
+		}},
+		{"prefix", "products/frozen/", SymlinksWithinRoot, []string{
+			"products/frozen/product-001.jpg",
+		}},
+		{"partial name prefix", "products/fro", SymlinksWithinRoot, []string{
+			"products/frozen/product-001.jpg",
+		}},
+		{"through dir link denied", "linkdir/", SymlinksDeny, nil},
+		{"traversal prefix", "../", SymlinksWithinRoot, nil},
+		{"missing prefix", "nope/", SymlinksWithinRoot, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLocalStorage(base, WithSymlinkPolicy(tt.policy)).List(tt.prefix)
+			if err != nil {
+				t.Fatalf("List(%q): %v", tt.prefix, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing base", func(t *testing.T) {
+		got, err := NewLocalStorage(filepath.Join(base, "nope")).List("")
+		if err != nil || got != nil {
+			t.Errorf("List on missing base = %v, %v; want nil, nil", got, err)
+		}
+	})
+}
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"products/frozen/product-001.jpg", "products/frozen/product-001.jpg", false},
+		{"/products//frozen/./product-001.jpg", "products/frozen/product-001.jpg", false},
+		{"products/..data/x.jpg", "products/..data/x.jpg", false},
+		{"", "", true},
+		{"/", "", true},
+		{".", "", true},
+		{"..", "", true},
+		{"a/../b", "", true},
+		{"a/b/..", "", true},
+		{`a\b`, "", true},
+		{"a\x00b", "", true},
+		{"a\nb", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeKey(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeKey(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
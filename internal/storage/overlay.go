@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Whiteout markers follow the OCI image layer convention: a file named
+// ".wh.<name>" in an upper layer hides <name> (file or directory) in every
+// lower layer, and a ".wh..wh..opq" file makes its directory opaque so that
+// nothing below it in lower layers is visible.
+const (
+	WhiteoutPrefix = ".wh."
+	WhiteoutOpaque = ".wh..wh..opq"
+)
+
+// OverlayStorage stacks several storages. Lookups go to the first layer that
+// has the key; listings merge all layers. Layers are ordered highest
+// precedence first.
+type OverlayStorage struct {
+	layers []Storage
+}
+
+func NewOverlayStorage(layers ...Storage) *OverlayStorage {
+	return &OverlayStorage{layers: layers}
+}
+
+// NewLocalOverlay builds a storage from an ordered list of directories.
+// A single root yields a plain LocalStorage.
+func NewLocalOverlay(roots []string, options ...LocalOption) Storage {
+	if len(roots) == 1 {
+		return NewLocalStorage(roots[0], options...)
+	}
+	layers := make([]Storage, 0, len(roots))
+	for _, root := range roots {
+		layers = append(layers, NewLocalStorage(root, options...))
+	}
+	return NewOverlayStorage(layers...)
+}
+
+// ParseRoots splits an ASSETS_BASE_PATH style value into its roots.
+// Roots are separated by commas or the OS list separator; blanks are dropped.
+func ParseRoots(value string) []string {
+	var roots []string
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == os.PathListSeparator
+	}) {
+		if part = strings.TrimSpace(part); part != "" {
+			roots = append(roots, part)
+		}
+	}
+	return roots
+}
+
+func (s *OverlayStorage) Get(key string) ([]byte, error) {
+	layer := s.resolve(key)
+	if layer == nil {
+		return nil, ErrNotFound
+	}
+	return layer.Get(key)
+}
+
+func (s *OverlayStorage) Open(key string) (io.ReadSeekCloser, error) {
+	layer := s.resolve(key)
+	if layer == nil {
+		return nil, ErrNotFound
+	}
+	return Open(layer, key)
+}
+
+func (s *OverlayStorage) Stat(key string) (Info, error) {
+	layer := s.resolve(key)
+	if layer == nil {
+		return Info{}, ErrNotFound
+	}
+	return Stat(layer, key)
+}
+
+func (s *OverlayStorage) Exists(key string) bool {
+	return s.resolve(key) != nil
+}
+
+// resolve returns the layer that serves key, or nil when the key is missing
+// or hidden by a whiteout.
+func (s *OverlayStorage) resolve(key string) Storage {
+	key = strings.TrimPrefix(key, "/")
+	if IsWhiteout(key) {
+		return nil
+	}
+	for _, layer := range s.layers {
+		if layer.Exists(key) {
+			return layer
+		}
+		if hidesBelow(layer, key) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// hidesBelow reports whether layer carries a whiteout for key or for one of
+// its parent directories, or marks one of those directories opaque.
+func hidesBelow(layer Storage, key string) bool {
+	for p := key; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		dir, name := path.Split(p)
+		if layer.Exists(dir + WhiteoutPrefix + name) {
+			return true
+		}
+		if p != key && layer.Exists(p+"/"+WhiteoutOpaque) {
+			return true
+		}
+	}
+	return layer.Exists(WhiteoutOpaque)
+}
+
+// Put writes to the top layer and clears any whiteout there for key, so
+// the new object is visible again.
+func (s *OverlayStorage) Put(key string, data []byte) error {
+	top, err := s.top()
+	if err != nil {
+		return err
+	}
+	if IsWhiteout(key) {
+		return fmt.Errorf("%w: reserved name", ErrInvalidKey)
+	}
+	if err := top.Put(key, data); err != nil {
+		return err
+	}
+	if err := top.Delete(whiteoutFor(key)); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Delete removes key from the top layer and, if a lower layer still has
+// it, leaves a whiteout so it stays hidden.
+func (s *OverlayStorage) Delete(key string) error {
+	top, err := s.top()
+	if err != nil {
+		return err
+	}
+	if !s.Exists(key) {
+		return ErrNotFound
+	}
+	if err := top.Delete(key); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if s.Exists(key) {
+		return top.Put(whiteoutFor(key), nil)
+	}
+	return nil
+}
+
+func (s *OverlayStorage) top() (Writer, error) {
+	if len(s.layers) == 0 {
+		return nil, errors.New("overlay has no layers")
+	}
+	w, ok := s.layers[0].(Writer)
+	if !ok {
+		return nil, errors.New("top overlay layer is read-only")
+	}
+	return w, nil
+}
+
+func whiteoutFor(key string) string {
+	dir, name := path.Split(strings.TrimPrefix(key, "/"))
+	return dir + WhiteoutPrefix + name
+}
+
+// List merges the keys of every layer that implements Lister. Whiteout
+// markers are applied to lower layers and never returned themselves.
+func (s *OverlayStorage) List(prefix string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var hidden, opaque []string
+	var keys []string
+
+	for _, layer := range s.layers {
+		l, ok := layer.(Lister)
+		if !ok {
+			continue
+		}
+		// Markers may sit above the prefix, so list the whole layer.
+		all, err := l.List("")
+		if err != nil {
+			return nil, err
+		}
+		var layerHidden, layerOpaque []string
+		for _, key := range all {
+			dir, name := path.Split(key)
+			switch {
+			case name == WhiteoutOpaque:
+				layerOpaque = append(layerOpaque, dir)
+				continue
+			case strings.HasPrefix(name, WhiteoutPrefix):
+				layerHidden = append(layerHidden, dir+strings.TrimPrefix(name, WhiteoutPrefix))
+				continue
+			}
+			if _, dup := seen[key]; dup || coveredBy(key, hidden, opaque) {
+				continue
+			}
+			seen[key] = struct{}{}
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		hidden = append(hidden, layerHidden...)
+		opaque = append(opaque, layerOpaque...)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func coveredBy(key string, hidden, opaque []string) bool {
+	for _, h := range hidden {
+		if key == h || strings.HasPrefix(key, h+"/") {
+			return true
+		}
+	}
+	for _, dir := range opaque {
+		if strings.HasPrefix(key, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWhiteout reports whether key names an overlay whiteout marker.
+func IsWhiteout(key string) bool {
+	return strings.HasPrefix(path.Base(key), WhiteoutPrefix)
+}
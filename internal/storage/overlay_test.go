@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree creates files (key -> content) under a fresh temp directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for key, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestOverlayGet(t *testing.T) {
+	upper := writeTree(t, map[string]string{
+		"products/frozen/product-001.jpg":     "photo",
+		"products/frozen/.wh.product-002.jpg": "",
+		"products/.wh.smoked":                 "",
+		"products/shellfish/.wh..wh..opq":     "",
+		"products/shellfish/product-009.jpg":  "photo",
+	})
+	lower := writeTree(t, map[string]string{
+		"products/frozen/product-001.jpg":    "placeholder",
+		"products/frozen/product-002.jpg":    "placeholder",
+		"products/frozen/product-003.jpg":    "placeholder",
+		"products/smoked/product-001.jpg":    "placeholder",
+		"products/shellfish/product-001.jpg": "placeholder",
+	})
+	s := NewLocalOverlay([]string{upper, lower})
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"upper wins", "products/frozen/product-001.jpg", "photo", false},
+		{"falls through to lower", "products/frozen/product-003.jpg", "placeholder", false},
+		{"file whiteout hides lower", "products/frozen/product-002.jpg", "", true},
+		{"directory whiteout hides lower", "products/smoked/product-001.jpg", "", true},
+		{"opaque dir hides lower", "products/shellfish/product-001.jpg", "", true},
+		{"opaque dir keeps own files", "products/shellfish/product-009.jpg", "photo", false},
+		{"marker is not served", "products/frozen/.wh.product-002.jpg", "", true},
+		{"missing everywhere", "products/frozen/product-404.jpg", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := s.Get(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get(%q) err = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.key, data, tt.want)
+			}
+			if got := s.Exists(tt.key); got == tt.wantErr {
+				t.Errorf("Exists(%q) = %v, want %v", tt.key, got, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOverlayList(t *testing.T) {
+	upper := writeTree(t, map[string]string{
+		"products/frozen/product-001.jpg":     "photo",
+		"products/frozen/.wh.product-002.jpg": "",
+		"products/.wh.smoked":                 "",
+	})
+	lower := writeTree(t, map[string]string{
+		"products/frozen/product-001.jpg": "placeholder",
+		"products/frozen/product-002.jpg": "placeholder",
+		"products/frozen/product-003.jpg": "placeholder",
+		"products/smoked/product-001.jpg": "placeholder",
+	})
+	s := NewLocalOverlay([]string{upper, lower}).(*OverlayStorage)
+
+	got, err := s.List("products/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []string{
+		"products/frozen/product-001.jpg",
+		"products/frozen/product-003.jpg",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}
+
+func TestParseRoots(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"./assets", []string{"./assets"}},
+		{"/mnt/photos:./assets", []string{"/mnt/photos", "./assets"}},
+		{"/mnt/photos, ./assets", []string{"/mnt/photos", "./assets"}},
+		{" : ,", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseRoots(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRoots(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOverlayWrite(t *testing.T) {
+	upper := t.TempDir()
+	lower := writeTree(t, map[string]string{
+		"products/frozen/product-001.jpg": "placeholder",
+	})
+	s := NewLocalOverlay([]string{upper, lower}).(*OverlayStorage)
+	key := "products/frozen/product-001.jpg"
+
+	// Deleting a lower-layer file leaves a whiteout in the top layer.
+	if err := s.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if s.Exists(key) {
+		t.Fatalf("%s should be hidden after Delete", key)
+	}
+	if !NewLocalStorage(upper).Exists("products/frozen/.wh.product-001.jpg") {
+		t.Errorf("Delete should write a whiteout marker")
+	}
+	if err := s.Delete(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete err = %v, want ErrNotFound", err)
+	}
+
+	// Writing it again clears the whiteout.
+	if err := s.Put(key, []byte("photo")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if data, _ := s.Get(key); string(data) != "photo" {
+		t.Errorf("Get after Put = %q, want %q", data, "photo")
+	}
+	if NewLocalStorage(upper).Exists("products/frozen/.wh.product-001.jpg") {
+		t.Errorf("Put should remove the whiteout marker")
+	}
+	if data, _ := NewLocalStorage(lower).Get(key); string(data) != "placeholder" {
+		t.Errorf("lower layer must not be modified, got %q", data)
+	}
+
+	if err := s.Put("products/.wh.frozen", nil); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put(whiteout) err = %v, want ErrInvalidKey", err)
+	}
+}
@@ -1,11 +1,19 @@
 package storage
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"errors"
 	"io"
-	"os"
-	"path/filepath"
-	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by Get when the key does not resolve to a file.
+	ErrNotFound = errors.New("file not found")
+
+	// ErrInvalidKey is returned when a key fails normalisation.
+	ErrInvalidKey = errors.New("invalid path")
 )
 
 // Storage interface allows swapping between local and cloud storage
@@ -14,78 +22,88 @@ type Storage interface {
 	Exists(path string) bool
 }
 
-// LocalStorage serves files from local filesystem
-type LocalStorage struct {
-	basePath string
+// Lister is implemented by backends that can enumerate their keys.
+// Keys are slash-separated, relative to the storage root, and sorted.
+type Lister interface {
+	List(prefix string) ([]string, error)
 }
 
-func NewLocalStorage(basePath string) *LocalStorage {
-	return &LocalStorage{basePath: basePath}
+// Writer is implemented by backends that accept uploads and deletes.
+type Writer interface {
+	Put(path string, data []byte) error
+	Delete(path string) error
 }
 
-func (s *LocalStorage) Get(path string) ([]byte, error) {
-	// Security: prevent path traversal
-	cleanPath := filepath.Clean(path)
-	if strings.Contains(cleanPath, "..") {
-		return nil, fmt.Errorf("invalid path: path traversal detected")
-	}
-
-	fullPath := filepath.Join(s.basePath, cleanPath)
+// ReadWriter is a Storage that also accepts writes.
+type ReadWriter interface {
+	Storage
+	Writer
+}
 
-	// Security: ensure path is within basePath
-	absBase, err := filepath.Abs(s.basePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve base path: %w", err)
-	}
+// Observer is notified after a service changes an object in live storage,
+// e.g. to keep a metadata catalog in sync.
+type Observer interface {
+	Written(ctx context.Context, key string, data []byte, actor string)
+	Removed(ctx context.Context, key string)
+}
 
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve file path: %w", err)
-	}
+// Opener is implemented by backends that can hand out a seekable reader,
+// so the HTTP layer can answer Range requests without buffering the file.
+type Opener interface {
+	Open(path string) (io.ReadSeekCloser, error)
+}
 
-	if !strings.HasPrefix(absPath, absBase) {
-		return nil, fmt.Errorf("invalid path: outside base directory")
-	}
+// Info describes a stored object.
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
 
-	file, err := os.Open(fullPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found")
-		}
-		return nil, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
+	// ETag is a strong validator for the content, when the backend has one.
+	ETag string
+}
 
-	return io.ReadAll(file)
+// Stater is implemented by backends that can describe an object without
+// reading it.
+type Stater interface {
+	Stat(path string) (Info, error)
 }
 
-func (s *LocalStorage) Exists(path string) bool {
-	cleanPath := filepath.Clean(path)
-	if strings.Contains(cleanPath, "..") {
-		return false
+// Stat describes key using the backend's Stater when it has one, and by
+// reading the object otherwise.
+func Stat(s Storage, key string) (Info, error) {
+	if st, ok := s.(Stater); ok {
+		return st.Stat(key)
 	}
-
-	fullPath := filepath.Join(s.basePath, cleanPath)
-
-	// Security check
-	absBase, err := filepath.Abs(s.basePath)
+	data, err := s.Get(key)
 	if err != nil {
-		return false
+		return Info{}, err
 	}
+	return Info{Key: key, Size: int64(len(data))}, nil
+}
 
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil {
-		return false
+// Open returns a seekable reader for key using the backend's Opener when it
+// has one, and an in-memory reader over Get otherwise.
+func Open(s Storage, key string) (io.ReadSeekCloser, error) {
+	if o, ok := s.(Opener); ok {
+		return o.Open(key)
 	}
-
-	if !strings.HasPrefix(absPath, absBase) {
-		return false
+	data, err := s.Get(key)
+	if err != nil {
+		return nil, err
 	}
+	return NopCloser(bytes.NewReader(data)), nil
+}
 
-	_, err = os.Stat(fullPath)
-	return err == nil
+// NopCloser adds a no-op Close to a ReadSeeker.
+func NopCloser(r io.ReadSeeker) io.ReadSeekCloser {
+	return nopCloser{r}
 }
 
+type nopCloser struct{ io.ReadSeeker }
+
+func (nopCloser) Close() error { return nil }
+
 // Future: BucketStorage implementation for S3/GCS
 // type BucketStorage struct {
 //     bucketName string
@@ -0,0 +1,166 @@
+// Package transform generates resized renditions of raster images and
+// caches them, so clients can be sent an image no wider than they need.
+package transform
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF for Decode
+	"image/jpeg"
+	"image/png"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"codlocker-assets/internal/imageinfo"
+	"codlocker-assets/internal/storage"
+)
+
+// Widths are the renditions that may be generated. Requested widths are
+// rounded up to one of them so the cache stays bounded.
+var Widths = []int{160, 320, 480, 640, 800, 1024, 1280, 1600, 1920, 2560}
+
+// JPEGQuality is used when re-encoding JPEG renditions.
+const JPEGQuality = 82
+
+// ErrUnsupported is returned for images that are not transformed:
+// non-raster sources, images over the pixel limit, unknown output
+// formats, or requests that would not change the image (widths at or
+// above the source width in the same format).
+var ErrUnsupported = errors.New("transform: not supported")
+
+// Formats are the output formats renditions can be encoded in.
+var Formats = []string{"jpeg", "png"}
+
+// ContentType returns the media type of an output format.
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// NormalizeFormat maps format names such as "jpg" to their canonical form.
+func NormalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+// Snap rounds width up to the nearest entry of Widths, capping at the
+// largest.
+func Snap(width int) int {
+	for _, w := range Widths {
+		if width <= w {
+			return w
+		}
+	}
+	return Widths[len(Widths)-1]
+}
+
+// Scale resizes a PNG, JPEG or GIF to width pixels, keeping the aspect
+// ratio, and encodes it as format. A width of 0 (or at least the source
+// width) keeps the size; an empty format keeps JPEGs as JPEG and encodes
+// everything else as PNG. It returns the encoded image and its format.
+func Scale(data []byte, width int, format string) ([]byte, string, error) {
+	format = NormalizeFormat(format)
+	if format != "" && !slices.Contains(Formats, format) {
+		return nil, "", fmt.Errorf("%w: format %q", ErrUnsupported, format)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width*cfg.Height > imageinfo.MaxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d exceeds the %d pixel limit", ErrUnsupported, cfg.Width, cfg.Height, imageinfo.MaxPixels)
+	}
+	src, srcFormat, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	b := src.Bounds()
+	resize := width > 0 && width < b.Dx()
+	if !resize && (format == "" || format == srcFormat) {
+		return nil, "", ErrUnsupported
+	}
+	if format == "" {
+		format = "png"
+		if srcFormat == "jpeg" {
+			format = "jpeg"
+		}
+	}
+
+	var dst image.Image = src
+	if resize {
+		height := max(1, (b.Dy()*width+b.Dx()/2)/b.Dx())
+		scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), src, b, draw.Src, nil)
+		dst = scaled
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: JPEGQuality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("encode %s: %w", format, err)
+	}
+	return buf.Bytes(), format, nil
+}
+
+// Resizer generates renditions and keeps them in a cache store. Cache
+// entries are keyed by a fingerprint of the source, so a changed source
+// never serves a stale rendition.
+type Resizer struct {
+	cache storage.ReadWriter
+}
+
+func New(cache storage.ReadWriter) *Resizer {
+	return &Resizer{cache: cache}
+}
+
+// cacheKey names the rendition of a source at width in format.
+func cacheKey(src storage.Info, width int, format string) string {
+	sum := sha256.Sum256([]byte(src.Key + "\x00" + src.ETag + "\x00" +
+		strconv.FormatInt(src.Size, 10) + "\x00" + src.ModTime.UTC().Format(time.RFC3339Nano)))
+	fp := hex.EncodeToString(sum[:12])
+	return path.Join(fp[:2], fp, strconv.Itoa(width)+"."+format)
+}
+
+// Resize returns the rendition of data, whose metadata is src, at width
+// in format (see Scale for the zero values). The returned Info describes
+// the rendition.
+func (rz *Resizer) Resize(data []byte, src storage.Info, width int, format string) ([]byte, storage.Info, error) {
+	format = NormalizeFormat(format)
+	key := cacheKey(src, width, format)
+	info := storage.Info{Key: src.Key, ModTime: src.ModTime}
+	if src.ETag != "" {
+		info.ETag = src.ETag + "-w" + strconv.Itoa(width)
+		if format != "" {
+			info.ETag += "-" + format
+		}
+	}
+
+	if out, err := rz.cache.Get(key); err == nil {
+		info.Size = int64(len(out))
+		return out, info, nil
+	}
+	out, _, err := Scale(data, width, format)
+	if err != nil {
+		return nil, storage.Info{}, err
+	}
+	if err := rz.cache.Put(key, out); err != nil {
+		return nil, storage.Info{}, fmt.Errorf("cache rendition: %w", err)
+	}
+	info.Size = int64(len(out))
+	return out, info, nil
+}
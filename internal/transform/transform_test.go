@@ -0,0 +1,96 @@
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"codlocker-assets/internal/imageinfo"
+	"codlocker-assets/internal/imagetest"
+	"codlocker-assets/internal/storage"
+)
+
+func TestSnap(t *testing.T) {
+	for in, want := range map[int]int{1: 160, 160: 160, 161: 320, 700: 800, 2000: 2560, 9000: 2560} {
+		if got := Snap(in); got != want {
+			t.Errorf("Snap(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	for _, format := range []string{"png", "jpeg"} {
+		out, got, err := Scale(imagetest.Encode(t, format, 400, 300), 160, "")
+		if err != nil {
+			t.Fatalf("Scale(%s): %v", format, err)
+		}
+		info := imageinfo.Inspect(out)
+		if got != format || info.Format != format || info.Width != 160 || info.Height != 120 {
+			t.Errorf("Scale(%s) = %s %+v", format, got, info)
+		}
+	}
+
+	// Format conversion at the original size.
+	out, got, err := Scale(imagetest.Encode(t, "png", 100, 50), 0, "jpg")
+	if info := imageinfo.Inspect(out); err != nil || got != "jpeg" || info.Format != "jpeg" || info.Width != 100 {
+		t.Errorf("convert = %s %+v, %v", got, info, err)
+	}
+
+	unsupported := []struct {
+		name   string
+		data   []byte
+		width  int
+		format string
+	}{
+		{"upscale", imagetest.Encode(t, "png", 100, 100), 160, ""},
+		{"no change", imagetest.Encode(t, "png", 100, 100), 0, "png"},
+		{"svg", []byte("<svg/>"), 160, ""},
+		{"webp", imagetest.Encode(t, "png", 400, 300), 160, "webp"},
+	}
+	for _, tt := range unsupported {
+		if _, _, err := Scale(tt.data, tt.width, tt.format); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: err = %v, want ErrUnsupported", tt.name, err)
+		}
+	}
+
+	// Images over the pixel limit are refused before decoding.
+	if _, _, err := Scale(imagetest.Oversized(60000, 60000), 160, ""); !errors.Is(err, ErrUnsupported) || !strings.Contains(err.Error(), "pixel limit") {
+		t.Errorf("oversized: err = %v, want the pixel limit", err)
+	}
+}
+
+func TestResizerCache(t *testing.T) {
+	cache := storage.NewLocalStorage(t.TempDir())
+	rz := New(cache)
+	src := imagetest.Encode(t, "png", 800, 400)
+	info := storage.Info{Key: "banners/spring.png", Size: int64(len(src)), ModTime: time.Unix(1700000000, 0), ETag: "abc"}
+
+	out, got, err := rz.Resize(src, info, 320, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ETag != "abc-w320" || got.Size != int64(len(out)) || !got.ModTime.Equal(info.ModTime) {
+		t.Errorf("rendition info = %+v", got)
+	}
+	keys, _ := cache.List("")
+	if len(keys) != 1 {
+		t.Fatalf("cache holds %v", keys)
+	}
+
+	// A cached rendition is served without decoding the source again.
+	again, _, err := rz.Resize(nil, info, 320, "")
+	if err != nil || !bytes.Equal(again, out) {
+		t.Errorf("cached Resize = %d bytes, %v", len(again), err)
+	}
+
+	// A changed source gets a new rendition.
+	info.ModTime = info.ModTime.Add(time.Second)
+	if _, _, err := rz.Resize(src, info, 320, ""); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := cache.List(""); len(keys) != 2 {
+		t.Errorf("cache after source change holds %v", keys)
+	}
+}
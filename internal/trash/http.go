@@ -0,0 +1,68 @@
+package trash
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the delete and trash endpoints:
+//
+//	DELETE /api/v1/assets/{key}              move an object to the trash
+//	GET    /api/v1/trash?prefix=&limit=      list trash entries
+//	POST   /api/v1/trash/{id}/restore        restore (?overwrite=true to replace)
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/trash", s.handleList).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/trash/{id}/restore", s.handleRestore).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/assets/{key:.+}", s.handleDelete).Methods(http.MethodDelete)
+}
+
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	e, err := s.Delete(r.Context(), key, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, e)
+}
+
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			api.Error(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	entries, err := s.List(r.Context(), r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+func (s *Service) handleRestore(w http.ResponseWriter, r *http.Request) {
+	overwrite, _ := strconv.ParseBool(r.URL.Query().Get("overwrite"))
+	e, err := s.Restore(r.Context(), mux.Vars(r)["id"], api.Actor(r), overwrite)
+	if err != nil {
+		status := api.StatusFor(err)
+		if errors.Is(err, ErrConflict) {
+			status = http.StatusConflict
+		}
+		api.Error(w, status, err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, e)
+}
@@ -0,0 +1,185 @@
+// Package trash implements soft delete: deleted objects move to a trash
+// namespace for a retention period, can be restored, and are purged once
+// they expire.
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// ErrConflict is returned when restoring over an object that exists again.
+var ErrConflict = errors.New("object already exists")
+
+// Index stores trash metadata. *db.TrashRepo implements it.
+type Index interface {
+	Add(ctx context.Context, e db.TrashEntry) error
+	List(ctx context.Context, prefix string, limit int) ([]db.TrashEntry, error)
+	Expired(ctx context.Context, now time.Time, limit int) ([]db.TrashEntry, error)
+	Get(ctx context.Context, id string) (db.TrashEntry, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// RestoreFunc writes a restored object back to live storage. It lets the
+// caller route restores through versioning.
+type RestoreFunc func(ctx context.Context, key string, data []byte, actor string) error
+
+// Service moves deleted objects between live storage and the trash.
+type Service struct {
+	live      storage.ReadWriter
+	bin       storage.ReadWriter
+	index     Index
+	retention time.Duration
+	restore   RestoreFunc
+
+	observers []storage.Observer
+	now       func() time.Time
+}
+
+// New returns a Service keeping deleted objects for retention. If restore
+// is nil, restored objects are written straight to live and observers are
+// told; otherwise restore is responsible for both.
+func New(live, bin storage.ReadWriter, index Index, retention time.Duration, restore RestoreFunc) *Service {
+	s := &Service{live: live, bin: bin, index: index, retention: retention, restore: restore, now: time.Now}
+	if s.restore == nil {
+		s.restore = func(ctx context.Context, key string, data []byte, actor string) error {
+			if err := live.Put(key, data); err != nil {
+				return err
+			}
+			for _, o := range s.observers {
+				o.Written(ctx, key, data, actor)
+			}
+			return nil
+		}
+	}
+	return s
+}
+
+// Observe registers o to be told when objects leave live storage.
+func (s *Service) Observe(o storage.Observer) {
+	s.observers = append(s.observers, o)
+}
+
+// Delete moves key to the trash.
+func (s *Service) Delete(ctx context.Context, key, actor string) (db.TrashEntry, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return db.TrashEntry{}, err
+	}
+	data, err := s.live.Get(key)
+	if err != nil {
+		return db.TrashEntry{}, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return db.TrashEntry{}, fmt.Errorf("new trash id: %w", err)
+	}
+	now := s.now().UTC()
+	e := db.TrashEntry{
+		ID:          id.String(),
+		Key:         key,
+		Size:        int64(len(data)),
+		ContentType: storage.DetectContentType(key, data),
+		DeletedAt:   now,
+		DeletedBy:   actor,
+		ExpiresAt:   now.Add(s.retention),
+	}
+
+	if err := s.bin.Put(e.ID, data); err != nil {
+		return db.TrashEntry{}, fmt.Errorf("store trash blob: %w", err)
+	}
+	if err := s.index.Add(ctx, e); err != nil {
+		_ = s.bin.Delete(e.ID)
+		return db.TrashEntry{}, err
+	}
+	if err := s.live.Delete(key); err != nil {
+		// Keep the object live rather than leave a trash entry for it.
+		_ = s.index.Delete(ctx, e.ID)
+		_ = s.bin.Delete(e.ID)
+		return db.TrashEntry{}, fmt.Errorf("remove live object: %w", err)
+	}
+	for _, o := range s.observers {
+		o.Removed(ctx, key)
+	}
+	return e, nil
+}
+
+// List returns trash entries under prefix, newest first.
+func (s *Service) List(ctx context.Context, prefix string, limit int) ([]db.TrashEntry, error) {
+	return s.index.List(ctx, prefix, limit)
+}
+
+// Restore puts a trashed object back at its original key. Unless
+// overwrite is set, it refuses if that key has been reused meanwhile.
+func (s *Service) Restore(ctx context.Context, id, actor string, overwrite bool) (db.TrashEntry, error) {
+	e, err := s.index.Get(ctx, id)
+	if err != nil {
+		return db.TrashEntry{}, err
+	}
+	if !overwrite && s.live.Exists(e.Key) {
+		return db.TrashEntry{}, fmt.Errorf("%w: %s", ErrConflict, e.Key)
+	}
+	data, err := s.bin.Get(e.ID)
+	if err != nil {
+		return db.TrashEntry{}, fmt.Errorf("read trash blob: %w", err)
+	}
+	if err := s.restore(ctx, e.Key, data, actor); err != nil {
+		return db.TrashEntry{}, err
+	}
+	if err := s.index.Delete(ctx, e.ID); err != nil {
+		return db.TrashEntry{}, err
+	}
+	_ = s.bin.Delete(e.ID)
+	return e, nil
+}
+
+// Purge permanently removes entries that expired before now.
+func (s *Service) Purge(ctx context.Context) (int, error) {
+	const batch = 500
+	purged := 0
+	for {
+		expired, err := s.index.Expired(ctx, s.now().UTC(), batch)
+		if err != nil {
+			return purged, err
+		}
+		for _, e := range expired {
+			if err := s.bin.Delete(e.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return purged, fmt.Errorf("delete trash blob %s: %w", e.ID, err)
+			}
+			if err := s.index.Delete(ctx, e.ID); err != nil && !errors.Is(err, db.ErrNotFound) {
+				return purged, err
+			}
+			purged++
+		}
+		if len(expired) < batch {
+			return purged, nil
+		}
+	}
+}
+
+// Run purges expired trash every interval until ctx is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.Purge(ctx)
+			if err != nil {
+				logger.Errorf("[trash] purge failed after %d entries: %v", n, err)
+			} else if n > 0 {
+				logger.Infof("[trash] purged %d expired entries", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/storage"
+)
+
+// memIndex is an in-memory Index.
+type memIndex struct {
+	mu      sync.Mutex
+	entries map[string]db.TrashEntry
+}
+
+func newMemIndex() *memIndex { return &memIndex{entries: make(map[string]db.TrashEntry)} }
+
+func (m *memIndex) Add(_ context.Context, e db.TrashEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[e.ID] = e
+	return nil
+}
+
+func (m *memIndex) List(_ context.Context, prefix string, limit int) ([]db.TrashEntry, error) {
+	return m.filter(func(e db.TrashEntry) bool { return strings.HasPrefix(e.Key, prefix) }, limit), nil
+}
+
+func (m *memIndex) Expired(_ context.Context, now time.Time, limit int) ([]db.TrashEntry, error) {
+	return m.filter(func(e db.TrashEntry) bool { return !e.ExpiresAt.After(now) }, limit), nil
+}
+
+func (m *memIndex) filter(keep func(db.TrashEntry) bool, limit int) []db.TrashEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.TrashEntry
+	for _, e := range m.entries {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (m *memIndex) Get(_ context.Context, id string) (db.TrashEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[id]
+	if !ok {
+		return db.TrashEntry{}, db.ErrNotFound
+	}
+	return e, nil
+}
+
+func (m *memIndex) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[id]; !ok {
+		return db.ErrNotFound
+	}
+	delete(m.entries, id)
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *storage.LocalStorage, *storage.LocalStorage) {
+	t.Helper()
+	live := storage.NewLocalStorage(t.TempDir())
+	bin := storage.NewLocalStorage(t.TempDir())
+	return New(live, bin, newMemIndex(), 24*time.Hour, nil), live, bin
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	s, live, bin := newTestService(t)
+	key := "products/shellfish/product-004.jpg"
+	if err := live.Put(key, []byte("lobster")); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := s.Delete(ctx, key, "alice")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if live.Exists(key) {
+		t.Errorf("%s should be gone from live storage", key)
+	}
+	if !bin.Exists(e.ID) {
+		t.Errorf("trash blob %s should exist", e.ID)
+	}
+	if e.DeletedBy != "alice" || e.ExpiresAt.Sub(e.DeletedAt) != 24*time.Hour {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	if _, err := s.Delete(ctx, key, "alice"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Delete of missing key err = %v, want ErrNotFound", err)
+	}
+
+	// A reused key blocks restore unless overwrite is set.
+	if err := live.Put(key, []byte("crab")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Restore(ctx, e.ID, "bob", false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Restore over existing key err = %v, want ErrConflict", err)
+	}
+	if _, err := s.Restore(ctx, e.ID, "bob", true); err != nil {
+		t.Fatalf("Restore with overwrite: %v", err)
+	}
+	if data, _ := live.Get(key); string(data) != "lobster" {
+		t.Errorf("restored object = %q, want %q", data, "lobster")
+	}
+	if bin.Exists(e.ID) {
+		t.Errorf("trash blob should be removed after restore")
+	}
+	if _, err := s.Restore(ctx, e.ID, "bob", false); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("second Restore err = %v, want db.ErrNotFound", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	s, live, bin := newTestService(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"a.svg", "b.svg"} {
+		if err := live.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, _ := s.Delete(ctx, "a.svg", "alice")
+	now = now.Add(12 * time.Hour)
+	fresh, _ := s.Delete(ctx, "b.svg", "alice")
+
+	now = now.Add(13 * time.Hour) // a.svg is 25h old, b.svg 13h
+	n, err := s.Purge(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Purge = %d, %v; want 1, nil", n, err)
+	}
+	if bin.Exists(old.ID) {
+		t.Errorf("expired blob should be purged")
+	}
+	if !bin.Exists(fresh.ID) {
+		t.Errorf("unexpired blob should remain")
+	}
+	list, _ := s.List(ctx, "", 10)
+	if len(list) != 1 || list[0].ID != fresh.ID {
+		t.Errorf("List after purge = %+v", list)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	s, live, _ := newTestService(t)
+	_ = live.Put("products/smoked/product-001.jpg", []byte("salmon"))
+	r := mux.NewRouter()
+	s.Register(r)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := do(http.MethodDelete, "/api/v1/assets/products/smoked/product-001.jpg")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE status = %d: %s", rec.Code, rec.Body)
+	}
+	var e db.TrashEntry
+	_ = json.NewDecoder(rec.Body).Decode(&e)
+
+	rec = do(http.MethodGet, "/api/v1/trash?prefix=products/smoked/")
+	var listing struct {
+		Entries []db.TrashEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || len(listing.Entries) != 1 {
+		t.Fatalf("trash listing = %+v, %v", listing, err)
+	}
+	if rec := do(http.MethodGet, "/api/v1/trash?limit=0"); rec.Code != http.StatusBadRequest {
+		t.Errorf("limit=0 status = %d, want 400", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "/api/v1/assets/products/smoked/product-001.jpg"); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE status = %d, want 404", rec.Code)
+	}
+
+	_ = live.Put("products/smoked/product-001.jpg", []byte("trout"))
+	if rec := do(http.MethodPost, "/api/v1/trash/"+e.ID+"/restore"); rec.Code != http.StatusConflict {
+		t.Errorf("restore over existing key status = %d, want 409", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/trash/"+e.ID+"/restore?overwrite=true"); rec.Code != http.StatusOK {
+		t.Errorf("restore with overwrite status = %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,84 @@
+package versions
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/http/api"
+)
+
+// Register mounts the upload and version endpoints:
+//
+//	PUT  /api/v1/assets/{key}                         upload a new version (202 when held)
+//	GET  /api/v1/assets/{key}/versions                list versions
+//	POST /api/v1/assets/{key}/versions/{id}/restore   promote a version
+func (s *Service) Register(r *mux.Router) {
+	r.HandleFunc("/api/v1/assets/{key:.+}/versions", s.handleList).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/assets/{key:.+}/versions/{id}/restore", s.handleRestore).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/assets/{key:.+}", s.handlePut).Methods(http.MethodPut)
+}
+
+func (s *Service) handlePut(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, err := api.ReadBody(w, r)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	if s.holder != nil && s.holder.Holds(key) {
+		s.hold(w, r, key, data)
+		return
+	}
+	v, err := s.Put(r.Context(), key, data, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusCreated, v)
+}
+
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	list, err := s.Versions(r.Context(), key)
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]any{"key": key, "versions": list})
+}
+
+func (s *Service) handleRestore(w http.ResponseWriter, r *http.Request) {
+	key, err := api.Key(r)
+	if err != nil {
+		api.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v, err := s.Restore(r.Context(), key, mux.Vars(r)["id"], api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, v)
+}
+
+func (s *Service) hold(w http.ResponseWriter, r *http.Request, key string, data []byte) {
+	if err := s.vet(key, data); err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	held, err := s.holder.Hold(r.Context(), key, data, api.Actor(r))
+	if err != nil {
+		api.Error(w, api.StatusFor(err), err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusAccepted, held)
+}
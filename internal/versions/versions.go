@@ -0,0 +1,294 @@
+// Package versions keeps every write to an asset as an immutable version,
+// so an overwrite can be rolled back.
+package versions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/storage"
+)
+
+// Index stores version metadata. *db.VersionRepo implements it.
+type Index interface {
+	Add(ctx context.Context, v db.AssetVersion) error
+	List(ctx context.Context, key string) ([]db.AssetVersion, error)
+	Get(ctx context.Context, key, id string) (db.AssetVersion, error)
+	SetCurrent(ctx context.Context, key, id string) error
+	Delete(ctx context.Context, key, id string) error
+}
+
+// Service writes assets through to live storage while keeping a copy of
+// every version in a separate blob store.
+type Service struct {
+	live   storage.ReadWriter
+	blobs  storage.ReadWriter
+	index  Index
+	retain int
+
+	observers []storage.Observer
+	checkers  []Checker
+	holder    Holder
+}
+
+// Checker vets uploads before they are stored. A non-nil error rejects
+// the upload and is returned from Put.
+type Checker interface {
+	Check(key string, data []byte) error
+}
+
+// Holder keeps uploads out of live storage until they are approved, e.g.
+// for moderation. Holds reports whether uploads to key are held; Hold
+// takes one and returns the response body for the uploader.
+type Holder interface {
+	Holds(key string) bool
+	Hold(ctx context.Context, key string, data []byte, actor string) (any, error)
+}
+
+// New returns a Service. retain is the number of versions kept per key
+// (the current one always survives); 0 keeps everything.
+func New(live, blobs storage.ReadWriter, index Index, retain int) *Service {
+	return &Service{live: live, blobs: blobs, index: index, retain: retain}
+}
+
+// Observe registers o to be told about every write to live storage.
+func (s *Service) Observe(o storage.Observer) {
+	s.observers = append(s.observers, o)
+}
+
+// Require registers c to vet every Put. Restoring an older version is not
+// re-checked: it rolls back to content that was accepted before.
+func (s *Service) Require(c Checker) {
+	s.checkers = append(s.checkers, c)
+}
+
+// HoldWith passes uploads made through the API to h when it holds their
+// key, after the checkers pass. Put itself is not held: restores and
+// approved uploads go live directly, and the importer refuses held keys
+// (see importer.Service.RefuseHeld).
+func (s *Service) HoldWith(h Holder) {
+	s.holder = h
+}
+
+func (s *Service) written(ctx context.Context, key string, data []byte, actor string) {
+	for _, o := range s.observers {
+		o.Written(ctx, key, data, actor)
+	}
+}
+
+// vet refuses reserved keys and content a checker rejects.
+func (s *Service) vet(key string, data []byte) error {
+	if storage.IsWhiteout(key) {
+		return fmt.Errorf("%w: reserved name", storage.ErrInvalidKey)
+	}
+	for _, c := range s.checkers {
+		if err := c.Check(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobKey is where a version's bytes live in the blob store.
+func blobKey(key, id string) string {
+	return path.Join(key, id)
+}
+
+// Put stores data as a new current version of key. The version is
+// indexed before live storage is written; if that write fails, the
+// version is dropped again and the previous one is current.
+func (s *Service) Put(ctx context.Context, key string, data []byte, actor string) (db.AssetVersion, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return db.AssetVersion{}, err
+	}
+	if err := s.vet(key, data); err != nil {
+		return db.AssetVersion{}, err
+	}
+	prev, err := s.currentID(ctx, key)
+	if err != nil {
+		return db.AssetVersion{}, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return db.AssetVersion{}, fmt.Errorf("new version id: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	v := db.AssetVersion{
+		Key:         key,
+		ID:          id.String(),
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		ContentType: storage.DetectContentType(key, data),
+		CreatedAt:   time.Now().UTC(),
+		CreatedBy:   actor,
+		Current:     true,
+	}
+
+	if err := s.blobs.Put(blobKey(key, v.ID), data); err != nil {
+		return db.AssetVersion{}, fmt.Errorf("store version blob: %w", err)
+	}
+	if err := s.index.Add(ctx, v); err != nil {
+		_ = s.blobs.Delete(blobKey(key, v.ID))
+		return db.AssetVersion{}, err
+	}
+	if err := s.live.Put(key, data); err != nil {
+		ctx := context.WithoutCancel(ctx)
+		s.resetCurrent(ctx, key, prev)
+		if derr := s.drop(ctx, v); derr != nil {
+			logger.Errorf("[versions] drop %s@%s after failed live write: %v", key, v.ID, derr)
+		}
+		return db.AssetVersion{}, fmt.Errorf("write live object: %w", err)
+	}
+	s.written(ctx, key, data, actor)
+	s.prune(ctx, key)
+	return v, nil
+}
+
+// Versions lists the versions of key, newest first.
+func (s *Service) Versions(ctx context.Context, key string) ([]db.AssetVersion, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.index.List(ctx, key)
+}
+
+// OpenVersion returns the bytes of a historical version.
+func (s *Service) OpenVersion(ctx context.Context, key, id string) (io.ReadSeekCloser, storage.Info, error) {
+	v, err := s.lookup(ctx, key, id)
+	if err != nil {
+		return nil, storage.Info{}, err
+	}
+	r, err := storage.Open(s.blobs, blobKey(v.Key, v.ID))
+	if err != nil {
+		return nil, storage.Info{}, err
+	}
+	return r, storage.Info{Key: v.Key, Size: v.Size, ModTime: v.CreatedAt, ETag: v.SHA256}, nil
+}
+
+// Restore promotes an old version back to current. If writing live
+// storage fails, the previous version stays current.
+func (s *Service) Restore(ctx context.Context, key, id, actor string) (db.AssetVersion, error) {
+	v, err := s.lookup(ctx, key, id)
+	if err != nil {
+		return db.AssetVersion{}, err
+	}
+	data, err := s.blobs.Get(blobKey(v.Key, v.ID))
+	if err != nil {
+		return db.AssetVersion{}, fmt.Errorf("read version blob: %w", err)
+	}
+	prev, err := s.currentID(ctx, v.Key)
+	if err != nil {
+		return db.AssetVersion{}, err
+	}
+	if err := s.index.SetCurrent(ctx, v.Key, v.ID); err != nil {
+		return db.AssetVersion{}, err
+	}
+	if err := s.live.Put(v.Key, data); err != nil {
+		s.resetCurrent(context.WithoutCancel(ctx), v.Key, prev)
+		return db.AssetVersion{}, fmt.Errorf("write live object: %w", err)
+	}
+	s.written(ctx, v.Key, data, actor)
+	v.Current = true
+	return v, nil
+}
+
+// currentID returns the ID of the current version of key, or "".
+func (s *Service) currentID(ctx context.Context, key string) (string, error) {
+	list, err := s.index.List(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range list {
+		if v.Current {
+			return v.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// resetCurrent makes prev the current version of key again after a
+// failed live write. Without a previous version there is nothing to go
+// back to.
+func (s *Service) resetCurrent(ctx context.Context, key, prev string) {
+	if prev == "" {
+		return
+	}
+	if err := s.index.SetCurrent(ctx, key, prev); err != nil {
+		logger.Errorf("[versions] reset current version of %s to %s after failed live write: %v", key, prev, err)
+	}
+}
+
+func (s *Service) lookup(ctx context.Context, key, id string) (db.AssetVersion, error) {
+	key, err := storage.NormalizeKey(key)
+	if err != nil {
+		return db.AssetVersion{}, err
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return db.AssetVersion{}, db.ErrNotFound
+	}
+	return s.index.Get(ctx, key, id)
+}
+
+// ErrCurrent is returned when deleting the current version of an asset.
+var ErrCurrent = errors.New("cannot delete the current version")
+
+// DeleteVersion removes a non-current version and its blob.
+func (s *Service) DeleteVersion(ctx context.Context, key, id string) error {
+	v, err := s.lookup(ctx, key, id)
+	if err != nil {
+		return err
+	}
+	if v.Current {
+		return fmt.Errorf("%w: %s@%s", ErrCurrent, v.Key, v.ID)
+	}
+	return s.drop(ctx, v)
+}
+
+// drop deletes the index row of v, then its blob. A missing blob is not
+// an error.
+func (s *Service) drop(ctx context.Context, v db.AssetVersion) error {
+	if err := s.index.Delete(ctx, v.Key, v.ID); err != nil {
+		return err
+	}
+	if err := s.blobs.Delete(blobKey(v.Key, v.ID)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("delete version blob: %w", err)
+	}
+	return nil
+}
+
+// prune drops the oldest non-current versions beyond the retention limit.
+// Failures are logged; the write that triggered pruning has succeeded.
+func (s *Service) prune(ctx context.Context, key string) {
+	if s.retain <= 0 {
+		return
+	}
+	list, err := s.index.List(ctx, key)
+	if err != nil {
+		logger.Warnf("[versions] prune %s: %v", key, err)
+		return
+	}
+	kept := 0
+	for _, v := range list {
+		if v.Current || kept < s.retain-1 {
+			if !v.Current {
+				kept++
+			}
+			continue
+		}
+		if err := s.drop(ctx, v); err != nil {
+			logger.Warnf("[versions] prune %s@%s: %v", v.Key, v.ID, err)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package versions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"codlocker-assets/internal/db"
+	"codlocker-assets/internal/storage"
+)
+
+// memIndex is an in-memory Index.
+type memIndex struct {
+	mu   sync.Mutex
+	rows []db.AssetVersion
+}
+
+func (m *memIndex) Add(_ context.Context, v db.AssetVersion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.rows {
+		if m.rows[i].Key == v.Key {
+			m.rows[i].Current = false
+		}
+	}
+	v.Current = true
+	m.rows = append(m.rows, v)
+	return nil
+}
+
+func (m *memIndex) List(_ context.Context, key string) ([]db.AssetVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.AssetVersion
+	for _, v := range m.rows {
+		if v.Key == key {
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (m *memIndex) Get(_ context.Context, key, id string) (db.AssetVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.rows {
+		if v.Key == key && v.ID == id {
+			return v, nil
+		}
+	}
+	return db.AssetVersion{}, db.ErrNotFound
+}
+
+func (m *memIndex) SetCurrent(_ context.Context, key, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := false
+	for i := range m.rows {
+		if m.rows[i].Key == key {
+			m.rows[i].Current = m.rows[i].ID == id
+			found = found || m.rows[i].ID == id
+		}
+	}
+	if !found {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (m *memIndex) Delete(_ context.Context, key, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, v := range m.rows {
+		if v.Key == key && v.ID == id {
+			m.rows = append(m.rows[:i], m.rows[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func newTestService(t *testing.T, retain int) (*Service, *storage.LocalStorage, *storage.LocalStorage) {
+	t.Helper()
+	live := storage.NewLocalStorage(t.TempDir())
+	blobs := storage.NewLocalStorage(t.TempDir())
+	return New(live, blobs, &memIndex{}, retain), live, blobs
+}
+
+func TestPutAndRestore(t *testing.T) {
+	ctx := context.Background()
+	s, live, _ := newTestService(t, 0)
+	key := "products/frozen/product-003.jpg"
+
+	v1, err := s.Put(ctx, key, []byte("original"), "alice")
+	if err != nil {
+		t.Fatalf("Put v1: %v", err)
+	}
+	v2, err := s.Put(ctx, key, []byte("oops"), "bob")
+	if err != nil {
+		t.Fatalf("Put v2: %v", err)
+	}
+	if v1.ID == v2.ID || v2.CreatedBy != "bob" || v2.Size != 4 {
+		t.Errorf("unexpected version metadata: %+v", v2)
+	}
+
+	if data, _ := live.Get(key); string(data) != "oops" {
+		t.Errorf("live object = %q, want %q", data, "oops")
+	}
+
+	// Historical read.
+	r, info, err := s.OpenVersion(ctx, key, v1.ID)
+	if err != nil {
+		t.Fatalf("OpenVersion: %v", err)
+	}
+	old, _ := io.ReadAll(r)
+	r.Close()
+	if string(old) != "original" || info.ETag != v1.SHA256 {
+		t.Errorf("OpenVersion = %q (etag %s), want original (etag %s)", old, info.ETag, v1.SHA256)
+	}
+
+	// Restore promotes v1 back.
+	if _, err := s.Restore(ctx, key, v1.ID, "alice"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if data, _ := live.Get(key); string(data) != "original" {
+		t.Errorf("live object after restore = %q, want %q", data, "original")
+	}
+	list, _ := s.Versions(ctx, key)
+	for _, v := range list {
+		if v.Current != (v.ID == v1.ID) {
+			t.Errorf("version %s current = %v", v.ID, v.Current)
+		}
+	}
+
+	if _, err := s.Restore(ctx, key, "not-a-version", "alice"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("Restore(bogus) err = %v, want db.ErrNotFound", err)
+	}
+	if _, err := s.Put(ctx, "products/.wh.frozen", []byte("x"), "mallory"); !errors.Is(err, storage.ErrInvalidKey) {
+		t.Errorf("Put(whiteout) err = %v, want ErrInvalidKey", err)
+	}
+}
+
+// failingLive fails writes while fail is set.
+type failingLive struct {
+	*storage.LocalStorage
+	fail bool
+}
+
+func (f *failingLive) Put(key string, data []byte) error {
+	if f.fail {
+		return errors.New("disk full")
+	}
+	return f.LocalStorage.Put(key, data)
+}
+
+func TestLiveWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	live := &failingLive{LocalStorage: storage.NewLocalStorage(t.TempDir())}
+	blobs := storage.NewLocalStorage(t.TempDir())
+	s := New(live, blobs, &memIndex{}, 0)
+	key := "banners/spring.svg"
+
+	v1, err := s.Put(ctx, key, []byte("one"), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := s.Put(ctx, key, []byte("two"), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live.fail = true
+	if _, err := s.Put(ctx, key, []byte("three"), "bob"); err == nil {
+		t.Fatal("Put succeeded although the live write failed")
+	}
+	if _, err := s.Restore(ctx, key, v1.ID, "bob"); err == nil {
+		t.Fatal("Restore succeeded although the live write failed")
+	}
+	list, _ := s.Versions(ctx, key)
+	if len(list) != 2 || list[0].ID != v2.ID || !list[0].Current || list[1].Current {
+		t.Errorf("versions after failed writes = %+v, want v2 current", list)
+	}
+	if keys, _ := blobs.List(key); len(keys) != 2 {
+		t.Errorf("blobs after failed writes = %v", keys)
+	}
+	if data, _ := live.Get(key); string(data) != "two" {
+		t.Errorf("live object = %q, want %q", data, "two")
+	}
+
+	// With no earlier version there is nothing left behind.
+	if _, err := s.Put(ctx, "banners/summer.svg", []byte("new"), "bob"); err == nil {
+		t.Fatal("Put succeeded although the live write failed")
+	}
+	if list, _ := s.Versions(ctx, "banners/summer.svg"); len(list) != 0 {
+		t.Errorf("versions of a never-written key = %+v", list)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	s, _, blobs := newTestService(t, 3)
+	key := "banners/spring.svg"
+
+	var ids []string
+	for _, body := range []string{"a", "b", "c", "d", "e"} {
+		v, err := s.Put(ctx, key, []byte(body), "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, v.ID)
+	}
+
+	list, _ := s.Versions(ctx, key)
+	if len(list) != 3 {
+		t.Fatalf("kept %d versions, want 3", len(list))
+	}
+	if list[0].ID != ids[4] || !list[0].Current {
+		t.Errorf("newest version should be current: %+v", list[0])
+	}
+	for _, pruned := range ids[:2] {
+		if blobs.Exists(blobKey(key, pruned)) {
+			t.Errorf("blob for pruned version %s still exists", pruned)
+		}
+	}
+
+	// A restored old version survives pruning because it is current.
+	if _, err := s.Restore(ctx, key, ids[2], "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, key, []byte("f"), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = s.Versions(ctx, key)
+	if len(list) != 3 {
+		t.Errorf("kept %d versions after restore+put, want 3", len(list))
+	}
+}
+
+// checkFunc adapts a function to Checker.
+type checkFunc func(key string, data []byte) error
+
+func (f checkFunc) Check(key string, data []byte) error { return f(key, data) }
+
+func TestRequire(t *testing.T) {
+	ctx := context.Background()
+	s, live, _ := newTestService(t, 0)
+	errTooShort := errors.New("too short")
+	s.Require(checkFunc(func(_ string, data []byte) error {
+		if len(data) < 3 {
+			return errTooShort
+		}
+		return nil
+	}))
+
+	if _, err := s.Put(ctx, "ui/logo.svg", []byte("ab"), "alice"); !errors.Is(err, errTooShort) {
+		t.Fatalf("Put err = %v, want the checker's error", err)
+	}
+	if live.Exists("ui/logo.svg") {
+		t.Errorf("rejected upload reached live storage")
+	}
+	if list, _ := s.Versions(ctx, "ui/logo.svg"); len(list) != 0 {
+		t.Errorf("rejected upload was versioned: %+v", list)
+	}
+	if _, err := s.Put(ctx, "ui/logo.svg", []byte("abc"), "alice"); err != nil {
+		t.Errorf("Put(valid) = %v", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	s, _, _ := newTestService(t, 0)
+	r := mux.NewRouter()
+	s.Register(r)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", "carol")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPut, "/api/v1/assets/products/frozen/product-003.jpg", "one")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	var v1 db.AssetVersion
+	_ = json.NewDecoder(rec.Body).Decode(&v1)
+	if v1.CreatedBy != "carol" {
+		t.Errorf("createdBy = %q, want carol", v1.CreatedBy)
+	}
+	do(http.MethodPut, "/api/v1/assets/products/frozen/product-003.jpg", "two")
+
+	rec = do(http.MethodGet, "/api/v1/assets/products/frozen/product-003.jpg/versions", "")
+	var listing struct {
+		Key      string            `json:"key"`
+		Versions []db.AssetVersion `json:"versions"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || len(listing.Versions) != 2 {
+		t.Fatalf("list = %+v, %v", listing, err)
+	}
+
+	rec = do(http.MethodPost, "/api/v1/assets/products/frozen/product-003.jpg/versions/"+v1.ID+"/restore", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("restore status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodPost, "/api/v1/assets/products/frozen/product-003.jpg/versions/nope/restore", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("restore of unknown version status = %d, want 404", rec.Code)
+	}
+}
+
+// prefixHolder holds uploads under prefix.
+type prefixHolder struct {
+	prefix string
+	held   []string
+}
+
+func (h *prefixHolder) Holds(key string) bool { return strings.HasPrefix(key, h.prefix) }
+
+func (h *prefixHolder) Hold(_ context.Context, key string, _ []byte, actor string) (any, error) {
+	h.held = append(h.held, key)
+	return map[string]string{"key": key, "by": actor}, nil
+}
+
+func TestHoldWith(t *testing.T) {
+	s, live, _ := newTestService(t, 0)
+	h := &prefixHolder{prefix: "reviews/"}
+	s.HoldWith(h)
+	s.Require(checkFunc(func(_ string, data []byte) error {
+		if len(data) == 0 {
+			return errors.New("empty")
+		}
+		return nil
+	}))
+	r := mux.NewRouter()
+	s.Register(r)
+	put := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := put("/api/v1/assets/reviews/r-1.jpg", "jpeg"); rec.Code != http.StatusAccepted {
+		t.Fatalf("held PUT status = %d: %s", rec.Code, rec.Body)
+	}
+	if live.Exists("reviews/r-1.jpg") || len(h.held) != 1 {
+		t.Errorf("held upload went live or was not held: %q", h.held)
+	}
+	if rec := put("/api/v1/assets/reviews/r-2.jpg", ""); rec.Code == http.StatusAccepted || len(h.held) != 1 {
+		t.Errorf("upload failing a checker was held: %d", rec.Code)
+	}
+	if rec := put("/api/v1/assets/ui/logo.svg", "<svg/>"); rec.Code != http.StatusCreated || !live.Exists("ui/logo.svg") {
+		t.Errorf("unheld PUT status = %d", rec.Code)
+	}
+	if _, err := s.Put(context.Background(), "reviews/r-1.jpg", []byte("jpeg"), "moderator"); err != nil || !live.Exists("reviews/r-1.jpg") {
+		t.Errorf("Put of an approved upload = %v", err)
+	}
+}
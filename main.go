@@ -2,25 +2,56 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"mime"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"codlocker-assets/internal/audit"
+	"codlocker-assets/internal/bundle"
+	"codlocker-assets/internal/catalog"
 	"codlocker-assets/internal/db"
 	"codlocker-assets/internal/featureflags"
+	"codlocker-assets/internal/gallery"
+	"codlocker-assets/internal/http/assets"
 	mw "codlocker-assets/internal/http/middleware"
+	"codlocker-assets/internal/importer"
+	"codlocker-assets/internal/lifecycle"
+	"codlocker-assets/internal/lint"
 	"codlocker-assets/internal/logger"
+	"codlocker-assets/internal/moderation"
+	"codlocker-assets/internal/orphans"
+	"codlocker-assets/internal/placeholder"
+	"codlocker-assets/internal/redirects"
+	"codlocker-assets/internal/responsive"
 	"codlocker-assets/internal/storage"
+	"codlocker-assets/internal/transform"
+	"codlocker-assets/internal/trash"
+	"codlocker-assets/internal/versions"
 )
 
 func main() {
+	// Subcommands run without feature flags; import needs the database,
+	// orphans uses it when it can.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "audit":
+			os.Exit(auditCommand(os.Args[2:]))
+		case "import":
+			os.Exit(importCommand(os.Args[2:]))
+		case "orphans":
+			os.Exit(orphansCommand(os.Args[2:]))
+		}
+	}
+
 	// 1) DB init
 	sqlDB, err := db.Init()
 	if err != nil {
@@ -102,57 +133,324 @@ func main() {
 			"offline":              featureflags.Values().Offline.IsEnabled(nil),
 			"logLevel":             featureflags.Values().LogLevel.GetValue(nil),
 			"imageStorageLocation": featureflags.Values().ImageStorageLocation.GetValue(nil),
+			"assetsGitRef":         featureflags.Values().AssetsGitRef.GetValue(nil),
 		}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(resp)
 	}).Methods(http.MethodGet)
 
 	// 7) Asset serving endpoints
-	assetsBasePath := os.Getenv("ASSETS_BASE_PATH")
-	if assetsBasePath == "" {
-		assetsBasePath = "./assets" // Default to bundled assets
+	localStore, assetRoots, symlinks := localAssets()
+	logger.Infof("asset roots: %s (symlinks=%s)", strings.Join(assetRoots, ", "), symlinks)
+
+	// ASSETS_ARCHIVE_PATH: optional .zip/.tar/.tar.gz served when the
+	// imageStorageLocation flag is "archive". Replacing the file (e.g. by
+	// re-pointing a symlink) hot-swaps the archive.
+	var archiveStore *storage.ArchiveStorage
+	if archivePath := os.Getenv("ASSETS_ARCHIVE_PATH"); archivePath != "" {
+		archiveStore, err = storage.OpenArchive(archivePath)
+		if err != nil {
+			logger.Errorf("asset archive disabled: %v", err)
+		} else {
+			logger.Infof("asset archive: %s", archivePath)
+			defer archiveStore.Close()
+			go watchArchive(archiveStore, archivePath)
+		}
 	}
 
-	r.PathPrefix("/assets/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract path after /assets/
-		assetPath := strings.TrimPrefix(r.URL.Path, "/assets/")
+	// ASSETS_GIT_REPO: optional git repository served when the
+	// imageStorageLocation flag is "git", at ASSETS_GIT_REF (default HEAD)
+	// unless the assetsGitRef flag names another branch, tag or commit.
+	var gitStore *storage.GitStorage
+	if gitRepo := os.Getenv("ASSETS_GIT_REPO"); gitRepo != "" {
+		gitRef := os.Getenv("ASSETS_GIT_REF")
+		if flagRef := featureflags.Values().AssetsGitRef.GetValue(nil); flagRef != "" {
+			gitRef = flagRef
+		}
+		gitStore, err = storage.OpenGit(gitRepo, gitRef)
+		if err != nil {
+			logger.Errorf("git asset storage disabled: %v", err)
+		} else {
+			ref, commit := gitStore.Ref()
+			logger.Infof("git assets: %s at %s (%s)", gitRepo, ref, commit)
+			go watchGitRef(gitStore, os.Getenv("ASSETS_GIT_REF"))
+		}
+	}
 
+	selectStore := func(_ *http.Request) storage.Storage {
 		// Determine storage backend based on feature flag
-		storageLocation := featureflags.Values().ImageStorageLocation.GetValue(nil)
-		var store storage.Storage
-
-		switch storageLocation {
+		switch featureflags.Values().ImageStorageLocation.GetValue(nil) {
+		case "archive":
+			if archiveStore != nil {
+				return archiveStore
+			}
+			logger.Warnf("archive storage not configured, falling back to local")
+			return localStore
+		case "git":
+			if gitStore != nil {
+				return gitStore
+			}
+			logger.Warnf("git storage not configured, falling back to local")
+			return localStore
 		case "bucket":
 			// TODO: Implement bucket storage when ready
 			logger.Warnf("bucket storage not yet implemented, falling back to local")
-			store = storage.NewLocalStorage(assetsBasePath)
-		case "local":
-			fallthrough
+			return localStore
 		default:
-			store = storage.NewLocalStorage(assetsBasePath)
+			return localStore
+		}
+	}
+
+	// 8) Uploads and versioning, to the first asset root.
+	writable, ok := localStore.(storage.ReadWriter)
+	if !ok {
+		log.Fatalf("asset storage is not writable")
+	}
+	versionSvc, policy := newVersions(sqlDB, writable)
+	versionSvc.Register(r)
+
+	// 8a) Moderation. Uploads to ASSETS_MODERATION_PREFIXES are held in
+	// ASSETS_QUARANTINE_PATH, out of live storage, until approved; the
+	// classifier at ASSETS_MODERATION_CLASSIFIER_URL may decide first.
+	if prefixes := moderationPrefixes(); len(prefixes) > 0 {
+		quarantinePath := os.Getenv("ASSETS_QUARANTINE_PATH")
+		if quarantinePath == "" {
+			quarantinePath = "./data/quarantine"
+		}
+		moderationSvc := moderation.New(
+			prefixes,
+			storage.NewLocalStorage(quarantinePath, storage.WithSymlinkPolicy(storage.SymlinksDeny)),
+			db.NewModerationRepo(sqlDB),
+			func(ctx context.Context, key string, data []byte, actor string) error {
+				_, err := versionSvc.Put(ctx, key, data, actor)
+				return err
+			},
+		)
+		if url := os.Getenv("ASSETS_MODERATION_CLASSIFIER_URL"); url != "" {
+			moderationSvc.UseClassifier(moderation.NewHTTPClassifier(url, envDuration("ASSETS_MODERATION_CLASSIFIER_TIMEOUT", 10*time.Second)))
+		}
+		versionSvc.HoldWith(moderationSvc)
+		moderationSvc.Register(r)
+		logger.Infof("moderated prefixes: %s", strings.Join(prefixes, ", "))
+	}
+
+	// 9) Soft delete. Deleted objects move to ASSETS_TRASH_PATH for
+	// ASSETS_TRASH_RETENTION (default 30 days) before being purged;
+	// restores are recorded as new versions.
+	trashPath := os.Getenv("ASSETS_TRASH_PATH")
+	if trashPath == "" {
+		trashPath = "./data/trash"
+	}
+	trashSvc := trash.New(
+		writable,
+		storage.NewLocalStorage(trashPath, storage.WithSymlinkPolicy(storage.SymlinksDeny)),
+		db.NewTrashRepo(sqlDB),
+		envDuration("ASSETS_TRASH_RETENTION", 30*24*time.Hour),
+		func(ctx context.Context, key string, data []byte, actor string) error {
+			_, err := versionSvc.Put(ctx, key, data, actor)
+			return err
+		},
+	)
+	trashSvc.Register(r)
+	go trashSvc.Run(context.Background(), envDuration("ASSETS_TRASH_PURGE_INTERVAL", time.Hour))
+
+	// 10) Metadata catalog, kept in sync with every upload, restore and
+	// delete. Assets already on disk are catalogued in the background.
+	catalogSvc := newCatalog(sqlDB)
+	versionSvc.Observe(catalogSvc)
+	trashSvc.Observe(catalogSvc)
+	catalogSvc.Register(r)
+	if src, ok := localStore.(catalog.Source); ok {
+		go func() {
+			n, err := catalogSvc.Backfill(context.Background(), src)
+			if err != nil {
+				logger.Errorf("[catalog] backfill failed after %d assets: %v", n, err)
+				return
+			}
+			logger.Infof("[catalog] backfilled %d assets", n)
+		}()
+	}
+
+	// 10a) Bulk imports of archive deliveries, vetted like uploads. A
+	// published batch a crash left unfolded is folded at start-up.
+	importSvc := newImporter(writable, versionSvc, policy)
+	if err := importSvc.Recover(context.Background()); err != nil {
+		logger.Errorf("import recovery: %v", err)
+	}
+	importSvc.Register(r)
+
+	// 11) Product galleries and responsive image sets. ASSETS_PUBLIC_URL
+	// (e.g. a CDN origin) prefixes the image URLs handed to clients; unset
+	// means root-relative URLs.
+	// Variant negotiation, sizing and gallery srcsets look sibling keys up
+	// in an index of the local roots. The write paths keep it current; it
+	// is rebuilt every ASSETS_KEY_INDEX_REFRESH to pick up files changed on
+	// disk.
+	var keyIndex *storage.KeyIndex
+	if lister, ok := localStore.(storage.Lister); ok {
+		keyIndex = storage.NewKeyIndex(lister, envDuration("ASSETS_KEY_INDEX_REFRESH", time.Minute))
+		versionSvc.Observe(keyIndex)
+		trashSvc.Observe(keyIndex)
+		importSvc.Observe(keyIndex)
+	}
+	assetURL := responsive.URLFor(os.Getenv("ASSETS_PUBLIC_URL"))
+	if keyIndex != nil {
+		gallerySvc := gallery.New(db.NewGalleryRepo(sqlDB), localStore, keyIndex, catalogSvc, assetURL)
+		gallerySvc.Register(r)
+	}
+	responsive.New(catalogSvc, assetURL).Register(r)
+
+	// 12) Generated placeholder images (/placeholder/640x480.svg), for
+	// dev and staging. ASSETS_PLACEHOLDERS=false turns them off.
+	if os.Getenv("ASSETS_PLACEHOLDERS") != "false" {
+		placeholder.Register(r)
+	}
+
+	// 13) Zip downloads of a prefix or a key list, streamed from the
+	// selected store and capped at ASSETS_BUNDLE_MAX_FILES files and
+	// ASSETS_BUNDLE_MAX_BYTES bytes.
+	bundle.New(selectStore, bundle.Limits{
+		MaxFiles: envInt("ASSETS_BUNDLE_MAX_FILES", bundle.DefaultMaxFiles),
+		MaxBytes: int64(envInt("ASSETS_BUNDLE_MAX_BYTES", bundle.DefaultMaxBytes)),
+	}).Register(r)
+
+	// 14) Batch existence checks (POST /api/v1/assets/_batch), looking at
+	// ASSETS_BATCH_WORKERS keys at a time.
+	assets.NewBatch(selectStore, envInt("ASSETS_BATCH_WORKERS", assets.DefaultBatchWorkers)).Register(r)
+
+	// ASSETS_VARIANT_PREFIXES: comma-separated key prefixes whose assets
+	// have locale/theme variants (banners/spring.fr-FR.dark.svg) that are
+	// negotiated from Accept-Language and the color-scheme client hint.
+	variantPrefixes := os.Getenv("ASSETS_VARIANT_PREFIXES")
+	if variantPrefixes == "" {
+		variantPrefixes = "banners/,ui/"
+	}
+	var negotiated []string
+	for _, p := range strings.Split(variantPrefixes, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			negotiated = append(negotiated, p)
 		}
+	}
 
-		data, err := store.Get(assetPath)
+	// Downscaled renditions for Client Hints and ?w= are cached under
+	// ASSETS_CACHE_PATH; the directory can be wiped at any time.
+	cachePath := os.Getenv("ASSETS_CACHE_PATH")
+	if cachePath == "" {
+		cachePath = "./data/cache"
+	}
+	resizer := transform.New(storage.NewLocalStorage(cachePath, storage.WithSymlinkPolicy(storage.SymlinksDeny)))
+
+	assetHandler := &assets.Handler{
+		Prefix:          "/assets/",
+		Store:           selectStore,
+		Versions:        versionSvc,
+		VariantPrefixes: negotiated,
+		Resizer:         resizer,
+		Keys:            keyIndex,
+	}
+	// ASSETS_BLURHASH_HEADER=true adds the catalogued BlurHash to asset
+	// responses as X-Blurhash, at the cost of a catalog lookup per request.
+	if os.Getenv("ASSETS_BLURHASH_HEADER") == "true" {
+		assetHandler.BlurHash = catalogSvc.BlurHash
+	}
+	// ASSETS_FALLBACKS maps missing keys to stand-in images, e.g.
+	// "products/shellfish/*=placeholders/shellfish.svg". They are served
+	// with ASSETS_FALLBACK_STATUS (404 or 200) and X-Asset-Fallback: true.
+	if spec := os.Getenv("ASSETS_FALLBACKS"); spec != "" {
+		rules, err := assets.ParseFallbackRules(spec)
 		if err != nil {
-			logger.Debugf("asset not found: %s (%v)", assetPath, err)
-			http.Error(w, "not found", http.StatusNotFound)
-			return
+			log.Fatalf("ASSETS_FALLBACKS: %v", err)
 		}
+		status := envInt("ASSETS_FALLBACK_STATUS", http.StatusNotFound)
+		if status != http.StatusOK && status != http.StatusNotFound {
+			logger.Warnf("ASSETS_FALLBACK_STATUS=%d is not 200 or 404, using 404", status)
+			status = http.StatusNotFound
+		}
+		assetHandler.Fallbacks = assets.NewFallbacks(rules, status)
+		assetHandler.Fallbacks.Register(r)
+	}
+	// Redirects and aliases for moved assets live in Postgres; rules in
+	// ASSETS_REDIRECTS_FILE (JSON) sit beneath them and keep working when
+	// the database is unreachable. Edits made through other instances are
+	// picked up every ASSETS_REDIRECTS_RELOAD.
+	redirectFile, err := redirectRules()
+	if err != nil {
+		log.Fatalf("ASSETS_REDIRECTS_FILE: %v", err)
+	}
+	redirectSvc := redirects.New(db.NewRedirectRepo(sqlDB), redirectFile)
+	if err := redirectSvc.Reload(context.Background()); err != nil {
+		logger.Errorf("[redirects] initial load failed, using file rules only: %v", err)
+	}
+	go redirectSvc.Run(context.Background(), envDuration("ASSETS_REDIRECTS_RELOAD", time.Minute))
+	redirectSvc.Register(r)
+	assetHandler.Redirects = redirectSvc
 
-		// Detect content type - check if it's SVG regardless of extension
-		contentType := mime.TypeByExtension(filepath.Ext(assetPath))
+	// 15) Orphan and dangling-reference reports against the product
+	// catalog, from an uploaded export or ASSETS_CATALOG_QUERY run on
+	// ASSETS_CATALOG_DB_URL (read-only) or the assets database.
+	var orphanSvc *orphans.Service
+	if src, ok := localStore.(orphans.Source); ok {
+		var query func(ctx context.Context) ([]db.ImageRef, error)
+		if catalogDB, err := openCatalogDB(sqlDB); err != nil {
+			logger.Errorf("[orphans] catalog database unavailable, reports need an export: %v", err)
+		} else {
+			q := catalogQuery()
+			query = func(ctx context.Context) ([]db.ImageRef, error) {
+				return db.QueryImageRefs(ctx, catalogDB, q)
+			}
+		}
+		prefix := os.Getenv("ASSETS_ORPHAN_PREFIX")
+		if prefix == "" {
+			prefix = orphans.DefaultPrefix
+		}
+		orphanSvc = orphans.New(src, redirectSvc, query, prefix)
+		orphanSvc.Register(r)
+	}
 
-		// Detect SVG files by content (even if named .jpg)
-		if len(data) > 4 && (string(data[:4]) == "<svg" || string(data[:5]) == "<?xml") {
-			contentType = "image/svg+xml"
-		} else if contentType == "" {
-			contentType = "application/octet-stream"
+	// 16) Lifecycle rules from ASSETS_LIFECYCLE_POLICY: expiry to the
+	// trash, moves to the ASSETS_COLD_PATH tier and version trimming,
+	// applied every ASSETS_LIFECYCLE_INTERVAL, or only reported with
+	// ASSETS_LIFECYCLE_DRY_RUN=true. Reads are counted for the rules and
+	// flushed to Postgres every ASSETS_ACCESS_FLUSH.
+	if policyFile := os.Getenv("ASSETS_LIFECYCLE_POLICY"); policyFile != "" {
+		lifecyclePolicy, err := lifecycle.Load(policyFile)
+		if err != nil {
+			log.Fatalf("ASSETS_LIFECYCLE_POLICY: %v", err)
+		}
+		src, ok := localStore.(lifecycle.Source)
+		if !ok {
+			log.Fatalf("asset storage cannot be listed for lifecycle rules")
+		}
+		accessRepo := db.NewAccessRepo(sqlDB)
+		lifecycleSvc := lifecycle.New(lifecyclePolicy, src, accessRepo, func(ctx context.Context, key, actor string) error {
+			_, err := trashSvc.Delete(ctx, key, actor)
+			return err
+		})
+		lifecycleSvc.UseVersions(versionSvc)
+		if coldPath := os.Getenv("ASSETS_COLD_PATH"); coldPath != "" {
+			lifecycleSvc.UseColdTier(
+				storage.NewLocalStorage(assetRoots[0], storage.WithSymlinkPolicy(symlinks)),
+				storage.NewLocalStorage(coldPath, storage.WithSymlinkPolicy(storage.SymlinksDeny)),
+			)
+		} else if lifecyclePolicy.UsesCold() {
+			log.Fatalf("ASSETS_LIFECYCLE_POLICY: coldAfterDays needs ASSETS_COLD_PATH")
+		}
+		interval := envDuration("ASSETS_LIFECYCLE_INTERVAL", 24*time.Hour)
+		if orphanSvc != nil {
+			lifecycleSvc.UseOrphans(orphanSvc.Last, interval)
 		}
+		versionSvc.Observe(lifecycleSvc)
+		trashSvc.Observe(lifecycleSvc)
+		lifecycleSvc.Register(r)
+		go lifecycleSvc.Run(context.Background(), interval, os.Getenv("ASSETS_LIFECYCLE_DRY_RUN") == "true")
+		logger.Infof("lifecycle policy: %s (%d rules)", policyFile, len(lifecyclePolicy.Rules))
 
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Cache-Control", "public, max-age=31536000") // 1 year cache
-		w.Write(data)
-	}).Methods(http.MethodGet)
+		tracker := lifecycle.NewTracker(accessRepo)
+		go tracker.Run(context.Background(), envDuration("ASSETS_ACCESS_FLUSH", time.Minute))
+		assetHandler.Accessed = tracker.Touch
+	}
+
+	r.PathPrefix("/assets/").Handler(assetHandler).Methods(http.MethodGet, http.MethodHead)
 
 	s := &http.Server{
 		Addr:              ":8080",
@@ -162,3 +460,290 @@ func main() {
 	logger.Infof("codlocker-assets listening on %s", s.Addr)
 	log.Fatal(s.ListenAndServe())
 }
+
+// localAssets opens the local asset roots. ASSETS_BASE_PATH may list
+// several roots (e.g. "/mnt/photos:./assets"); earlier roots take
+// precedence over later ones. ASSETS_SYMLINKS is "within-root" (default)
+// or "deny".
+func localAssets() (storage.Storage, []string, storage.SymlinkPolicy) {
+	assetsBasePath := os.Getenv("ASSETS_BASE_PATH")
+	if assetsBasePath == "" {
+		assetsBasePath = "./assets" // Default to bundled assets
+	}
+	roots := storage.ParseRoots(assetsBasePath)
+	// The cold tier of lifecycle rules is the lowest layer, so assets
+	// moved there are still served.
+	if coldPath := os.Getenv("ASSETS_COLD_PATH"); coldPath != "" {
+		roots = append(roots, coldPath)
+	}
+	symlinks := storage.ParseSymlinkPolicy(os.Getenv("ASSETS_SYMLINKS"))
+	// Published import batches are served on top, each going live whole.
+	live := storage.NewBatchOverlay(
+		storage.NewLocalOverlay(roots, storage.WithSymlinkPolicy(symlinks)),
+		importer.PublishedDir(importPath()),
+		storage.WithSymlinkPolicy(storage.SymlinksDeny),
+	)
+	return live, roots, symlinks
+}
+
+// newVersions builds the versioned write path to live: every version is
+// also kept under ASSETS_VERSIONS_PATH, and the newest
+// ASSETS_VERSION_RETENTION versions per key survive (0 = keep all).
+// ASSETS_LINT_POLICY, when set, holds YAML/JSON rules per prefix that
+// writes must pass (the audit command applies the same file); it is
+// returned so other write paths can apply it too.
+func newVersions(sqlDB *sql.DB, live storage.ReadWriter) (*versions.Service, *lint.Policy) {
+	versionsPath := os.Getenv("ASSETS_VERSIONS_PATH")
+	if versionsPath == "" {
+		versionsPath = "./data/versions"
+	}
+	svc := versions.New(
+		live,
+		storage.NewLocalStorage(versionsPath, storage.WithSymlinkPolicy(storage.SymlinksDeny)),
+		db.NewVersionRepo(sqlDB),
+		envInt("ASSETS_VERSION_RETENTION", 10),
+	)
+	policyFile := os.Getenv("ASSETS_LINT_POLICY")
+	if policyFile == "" {
+		return svc, nil
+	}
+	policy, err := lint.Load(policyFile)
+	if err != nil {
+		log.Fatalf("ASSETS_LINT_POLICY: %v", err)
+	}
+	svc.Require(policy)
+	logger.Infof("lint policy: %s (%d rules)", policyFile, len(policy.Rules))
+	return svc, policy
+}
+
+// newCatalog builds the metadata catalog. Localised text falls back to
+// ASSETS_DEFAULT_LOCALE (default "en").
+func newCatalog(sqlDB *sql.DB) *catalog.Service {
+	defaultLocale := os.Getenv("ASSETS_DEFAULT_LOCALE")
+	if defaultLocale == "" {
+		defaultLocale = "en"
+	}
+	return catalog.New(db.NewMetaRepo(sqlDB), db.NewTextRepo(sqlDB), defaultLocale)
+}
+
+// importPath is ASSETS_IMPORT_PATH, where import batches are staged and
+// published.
+func importPath() string {
+	if p := os.Getenv("ASSETS_IMPORT_PATH"); p != "" {
+		return p
+	}
+	return "./data/imports"
+}
+
+// newImporter builds the archive importer. Batches are staged under
+// ASSETS_IMPORT_PATH and published there, where localAssets serves them;
+// they are then written as new versions, so they are catalogued like
+// uploads. Entries mapped into moderated prefixes are refused.
+func newImporter(live storage.ReadWriter, versionSvc *versions.Service, policy *lint.Policy) *importer.Service {
+	svc := importer.New(live, importPath(), func(ctx context.Context, key string, data []byte, actor string) error {
+		_, err := versionSvc.Put(ctx, key, data, actor)
+		return err
+	})
+	if policy != nil {
+		svc.Require(policy)
+	}
+	if prefixes := moderationPrefixes(); len(prefixes) > 0 {
+		svc.RefuseHeld(prefixes)
+	}
+	return svc
+}
+
+// moderationPrefixes parses ASSETS_MODERATION_PREFIXES, a comma-separated
+// list of key prefixes whose uploads are held for review.
+func moderationPrefixes() moderation.Prefixes {
+	var prefixes moderation.Prefixes
+	for _, p := range strings.Split(os.Getenv("ASSETS_MODERATION_PREFIXES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// auditCommand runs "codlocker-assets audit" against the local asset
+// roots.
+func auditCommand(args []string) int {
+	local, _, _ := localAssets()
+	src, ok := local.(audit.Source)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "audit: asset storage cannot be listed")
+		return audit.ExitError
+	}
+	return audit.Command(args, src, os.Stdout, os.Stderr)
+}
+
+// importCommand runs "codlocker-assets import" against the local asset
+// roots and the database.
+func importCommand(args []string) int {
+	sqlDB, err := db.Init()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: database init failed: %v\n", err)
+		return importer.ExitError
+	}
+	defer sqlDB.Close()
+	local, _, _ := localAssets()
+	writable, ok := local.(storage.ReadWriter)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "import: asset storage is not writable")
+		return importer.ExitError
+	}
+	versionSvc, policy := newVersions(sqlDB, writable)
+	catalogSvc := newCatalog(sqlDB)
+	versionSvc.Observe(catalogSvc)
+	return importer.Command(args, newImporter(writable, versionSvc, policy), os.Stdout, os.Stderr)
+}
+
+// orphansCommand runs the orphan report from the command line. Without a
+// database it still works from a catalog export, following only the
+// redirects in ASSETS_REDIRECTS_FILE.
+func orphansCommand(args []string) int {
+	local, _, _ := localAssets()
+	src, ok := local.(orphans.Source)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "orphans: asset storage cannot be listed")
+		return orphans.ExitError
+	}
+	redirectFile, err := redirectRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "orphans: ASSETS_REDIRECTS_FILE: %v\n", err)
+		return orphans.ExitError
+	}
+	sqlDB, err := db.Init()
+	var redirectSvc *redirects.Service
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "orphans: database unavailable, using file redirects only: %v\n", err)
+		sqlDB = nil
+		redirectSvc = redirects.New(nil, redirectFile)
+	} else {
+		defer sqlDB.Close()
+		redirectSvc = redirects.New(db.NewRedirectRepo(sqlDB), redirectFile)
+		if err := redirectSvc.Reload(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "orphans: loading redirects failed, using file rules only: %v\n", err)
+		}
+	}
+	env := orphans.Env{
+		Source:    src,
+		Redirects: redirectSvc,
+		Query: func(ctx context.Context, query string) ([]db.ImageRef, error) {
+			catalogDB, err := openCatalogDB(sqlDB)
+			if err != nil {
+				return nil, err
+			}
+			if catalogDB != sqlDB {
+				defer catalogDB.Close()
+			}
+			return db.QueryImageRefs(ctx, catalogDB, query)
+		},
+	}
+	return orphans.Command(args, env, os.Stdout, os.Stderr)
+}
+
+// redirectRules loads the fallback redirect rules in ASSETS_REDIRECTS_FILE,
+// if set.
+func redirectRules() ([]db.Redirect, error) {
+	path := os.Getenv("ASSETS_REDIRECTS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return redirects.LoadFile(path)
+}
+
+// openCatalogDB returns the database holding the product catalog:
+// ASSETS_CATALOG_DB_URL opened read-only, or else sqlDB.
+func openCatalogDB(sqlDB *sql.DB) (*sql.DB, error) {
+	raw := os.Getenv("ASSETS_CATALOG_DB_URL")
+	if raw == "" {
+		if sqlDB == nil {
+			return nil, errors.New("no catalog database: set ASSETS_CATALOG_DB_URL or AUTH_DB_URL")
+		}
+		return sqlDB, nil
+	}
+	return db.OpenReadOnly(context.Background(), raw)
+}
+
+// catalogQuery is ASSETS_CATALOG_QUERY, by default the product galleries.
+func catalogQuery() string {
+	if q := os.Getenv("ASSETS_CATALOG_QUERY"); q != "" {
+		return q
+	}
+	return db.GalleryRefsQuery
+}
+
+// watchArchive polls the archive file and swaps it in when it changes.
+func watchArchive(store *storage.ArchiveStorage, path string) {
+	stamp := func() time.Time {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+	prev := stamp()
+	for {
+		time.Sleep(30 * time.Second)
+		cur := stamp()
+		if cur.IsZero() || cur.Equal(prev) {
+			continue
+		}
+		if err := store.Swap(path); err != nil {
+			logger.Errorf("asset archive reload failed: %v", err)
+			continue
+		}
+		logger.Infof("asset archive reloaded: %s", path)
+		prev = cur
+	}
+}
+
+// watchGitRef re-resolves the served ref so flag flips and moved branches
+// take effect; the assetsGitRef flag wins over the ASSETS_GIT_REF default.
+func watchGitRef(store *storage.GitStorage, defaultRef string) {
+	for {
+		time.Sleep(30 * time.Second)
+		ref := featureflags.Values().AssetsGitRef.GetValue(nil)
+		if ref == "" {
+			ref = defaultRef
+		}
+		_, prev := store.Ref()
+		if err := store.Checkout(ref); err != nil {
+			logger.Errorf("git assets checkout %q failed: %v", ref, err)
+			continue
+		}
+		if name, commit := store.Ref(); commit != prev {
+			logger.Infof("git assets now at %s (%s)", name, commit)
+		}
+	}
+}
+
+// envInt reads an integer env var, returning def when unset or invalid.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Warnf("%s=%q is not an integer, using %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a duration env var (e.g. "72h"), returning def when
+// unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		logger.Warnf("%s=%q is not a positive duration, using %s", name, v, def)
+		return def
+	}
+	return d
+}